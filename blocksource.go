@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"xmr_scanner/levin"
+)
+
+// BlockSource отдаёт блоки по порядку, начиная со следующего после стартового
+type BlockSource interface {
+	// Next: не больше max блоков, разобранных FullfillBlockHeader. Пустой срез — блоков больше нет.
+	Next(max int) ([]*levin.Block, error)
+	Close() error
+}
+
+/*--- levin ---*/
+
+// levinBlockSource качает историю отдельным соединением, не мешая основному
+type levinBlockSource struct {
+	conn   *levin.Client
+	peerID uint64
+	prune  bool
+
+	height uint64 // последний отданный блок
+	hash   string
+	ids    []string // известные хэши после hash
+}
+
+func newLevinBlockSource(node string, peerID uint64, height uint64, hash string, prune bool) (*levinBlockSource, error) {
+	conn, err := levin.NewClient(node)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Handshake(height, hash, peerID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	return &levinBlockSource{
+		conn:   conn,
+		peerID: peerID,
+		prune:  prune,
+		height: height,
+		hash:   hash,
+	}, nil
+}
+
+func (s *levinBlockSource) Close() error {
+	return s.conn.Close()
+}
+
+func (s *levinBlockSource) Next(max int) ([]*levin.Block, error) {
+	if len(s.ids) == 0 {
+		if err := s.requestChain(); err != nil {
+			return nil, err
+		}
+		if len(s.ids) == 0 {
+			return nil, nil
+		}
+	}
+
+	ids := s.ids[:min(max, len(s.ids))]
+	payload := (&levin.PortableStorage{
+		Entries: []levin.Entry{
+			{
+				Name:         "blocks",
+				Serializable: levin.BoostBlock(ids),
+			},
+			{
+				Name:         "prune",
+				Serializable: levin.BoostBool(s.prune),
+			},
+		},
+	}).Bytes()
+	if err := s.conn.SendRequest(levin.NotifyRequestGetObjects, payload); err != nil {
+		return nil, err
+	}
+	raw, err := s.wait(levin.NotifyResponseGetObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*levin.Block
+	for _, entry := range raw.Entries {
+		if entry.Name != "blocks" {
+			continue
+		}
+		for _, blk := range entry.Entries() {
+			block := levin.NewBlockFromEntries(blk.Entries())
+			if err := block.FullfillBlockHeader(); err != nil {
+				return nil, fmt.Errorf("block parse: %w", err)
+			}
+			for _, tx := range block.TXs {
+				tx.ParseTx()
+				tx.ParseRctSig()
+			}
+			blocks = append(blocks, block)
+		}
+	}
+
+	// нода отдаёт блоки в порядке запроса, но лучше проверить
+	if len(blocks) != len(ids) {
+		return nil, fmt.Errorf("requested %d blocks, got %d", len(ids), len(blocks))
+	}
+	for i, block := range blocks {
+		if block.GetBlockId() != ids[i] || block.BlockHeight != s.height+uint64(i)+1 {
+			return nil, fmt.Errorf("unexpected block %s at height %d", block.GetBlockId(), block.BlockHeight)
+		}
+	}
+
+	s.ids = s.ids[len(ids):]
+	s.height += uint64(len(ids))
+	s.hash = ids[len(ids)-1]
+	return blocks, nil
+}
+
+// requestChain: хэши после s.hash. Первый в ответе — сам s.hash.
+func (s *levinBlockSource) requestChain() error {
+	payload := (&levin.PortableStorage{
+		Entries: []levin.Entry{
+			{
+				Name:         "block_ids",
+				Serializable: levin.BoostBlockIds([]string{s.hash, levin.MainnetGenesisTx}),
+			},
+		},
+	}).Bytes()
+	if err := s.conn.SendRequest(levin.NotifyRequestChain, payload); err != nil {
+		return err
+	}
+	raw, err := s.wait(levin.NotifyResponseChainEntry)
+	if err != nil {
+		return err
+	}
+
+	var (
+		start uint64
+		ids   []string
+	)
+	for _, entry := range raw.Entries {
+		switch entry.Name {
+		case "start_height":
+			start = entry.Uint64()
+		case "m_block_ids":
+			if ids, err = ProcessBlockIds(entry.Value); err != nil {
+				return err
+			}
+		}
+	}
+	if len(ids) == 0 || ids[0] != s.hash || start != s.height {
+		return fmt.Errorf("chain entry does not continue block %s at height %d", s.hash, s.height)
+	}
+	s.ids = ids[1:]
+	return nil
+}
+
+// wait читает сообщения до ответа command, на ping и timed sync отвечает сам
+func (s *levinBlockSource) wait(command uint32) (*levin.PortableStorage, error) {
+	for {
+		header, raw, err := s.conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case header.Command == command:
+			return raw, nil
+		case header.Command == levin.CommandPing && header.ExpectsResponse:
+			payload := (&levin.PortableStorage{
+				Entries: []levin.Entry{
+					{
+						Name:         "status",
+						Serializable: levin.BoostString("OK"),
+					},
+					{
+						Name:         "peer_id",
+						Serializable: levin.BoostUint64(s.peerID),
+					},
+				},
+			}).Bytes()
+			s.conn.SendResponse(levin.CommandPing, payload)
+		case header.Command == levin.CommandTimedSync && header.ExpectsResponse:
+			s.conn.SendResponse(levin.CommandTimedSync, levin.NewRequestTimedSync(s.height, s.hash).Bytes())
+		}
+	}
+}
+
+/*--- локальное хранилище ---*/
+
+// dumpBlockSource: levin дампы NotifyResponseGetObjects (*.bin) из каталога, как в blocks/
+type dumpBlockSource struct {
+	blocks []*levin.Block
+}
+
+var errNoDumps = errors.New("no block dumps")
+
+func newDumpBlockSource(dir string, height uint64, hash string) (*dumpBlockSource, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.bin"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errNoDumps
+	}
+
+	byHeight := make(map[uint64]*levin.Block)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) >= levin.LevinHeaderSizeBytes && binary.LittleEndian.Uint64(data) == levin.LevinSignature {
+			data = data[levin.LevinHeaderSizeBytes:]
+		}
+		storage, err := levin.NewPortableStorageFromBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, entry := range storage.Entries {
+			if entry.Name != "blocks" {
+				continue
+			}
+			for _, blk := range entry.Entries() {
+				block := levin.NewBlockFromEntries(blk.Entries())
+				if err := block.FullfillBlockHeader(); err != nil {
+					return nil, fmt.Errorf("%s: %w", file, err)
+				}
+				if block.BlockHeight > height {
+					byHeight[block.BlockHeight] = block
+				}
+			}
+		}
+	}
+
+	// только непрерывный участок сразу после height, продолжающий hash
+	var blocks []*levin.Block
+	prev := hash
+	for h := height + 1; byHeight[h] != nil; h++ {
+		block := byHeight[h]
+		if hex.EncodeToString(block.PreviousBlockHash[:]) != prev {
+			break
+		}
+		blocks = append(blocks, block)
+		prev = block.GetBlockId()
+	}
+	return &dumpBlockSource{blocks: blocks}, nil
+}
+
+func (s *dumpBlockSource) Next(max int) ([]*levin.Block, error) {
+	n := min(max, len(s.blocks))
+	blocks := s.blocks[:n]
+	s.blocks = s.blocks[n:]
+	for _, block := range blocks {
+		for _, tx := range block.TXs {
+			tx.ParseTx()
+			tx.ParseRctSig()
+		}
+	}
+	return blocks, nil
+}
+
+func (s *dumpBlockSource) Close() error {
+	return nil
+}
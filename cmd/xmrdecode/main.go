@@ -0,0 +1,263 @@
+// xmrdecode печатает содержимое levin дампа, blob'а блока или транзакции в JSON.
+//
+//	xmrdecode -in blocks/dump_985.bin
+//	xmrdecode -type tx < tx.hex
+//	xmrdecode -in dump.bin -address 4... -viewkey <hex> [-spendkey <hex>]
+//
+// Вход читается из файла или stdin, бинарный или hex. С -address/-viewkey
+// для каждой транзакции выводятся найденные выходы кошелька (levin.Watcher).
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"xmr_scanner/levin"
+)
+
+type output struct {
+	Blocks []*blockJSON `json:"blocks,omitempty"`
+	Txs    []*txJSON    `json:"txs,omitempty"`
+}
+
+type blockJSON struct {
+	Hash string `json:"hash"`
+	*levin.Block
+	MinerTxHash  string              `json:"miner_tx_hash"`
+	MinerExtra   *levin.TxExtra      `json:"miner_extra_fields,omitempty"`
+	MinerOutputs []levin.OwnedOutput `json:"miner_owned_outputs,omitempty"`
+	Txs          []*txJSON           `json:"txs"`
+}
+
+type txJSON struct {
+	Hash         string `json:"hash"`
+	PrunableHash string `json:"prunable_hash,omitempty"`
+	Pruned       bool   `json:"pruned,omitempty"`
+	*levin.Transaction
+	ExtraFields  *levin.TxExtra      `json:"extra_fields,omitempty"`
+	ExtraError   string              `json:"extra_error,omitempty"`
+	OwnedOutputs []levin.OwnedOutput `json:"owned_outputs,omitempty"`
+	SpentOutputs []levin.SpentOutput `json:"spent_outputs,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+func main() {
+	in := flag.String("in", "-", "input file, - for stdin")
+	typ := flag.String("type", "auto", "input type: auto, levin, block, tx")
+	address := flag.String("address", "", "wallet address for owned output detection")
+	viewKey := flag.String("viewkey", "", "private view key (hex) for owned output detection")
+	spendKey := flag.String("spendkey", "", "private spend key (hex), adds key images and spends")
+	flag.Parse()
+
+	data, err := readInput(*in)
+	if err != nil {
+		log.Fatalf("read input: %v", err)
+	}
+
+	var w *levin.Watcher
+	if *address != "" || *viewKey != "" {
+		account, err := levin.NewAccount(*address, *viewKey)
+		if err != nil {
+			log.Fatalf("account: %v", err)
+		}
+		if *spendKey != "" {
+			if err := account.SetSpendKey(*spendKey); err != nil {
+				log.Fatalf("account: %v", err)
+			}
+		}
+		w = levin.NewWatcher()
+		w.AddAccount(account)
+	}
+
+	if *typ == "auto" {
+		*typ = detectType(data)
+	}
+
+	var out output
+	switch *typ {
+	case "levin":
+		blocks, err := decodeLevin(data)
+		if err != nil {
+			log.Fatalf("levin: %v", err)
+		}
+		for _, block := range blocks {
+			out.Blocks = append(out.Blocks, decodeBlock(block, w, false))
+		}
+	case "block":
+		block := levin.NewBlock()
+		block.SetBlockData(data)
+		out.Blocks = append(out.Blocks, decodeBlock(block, w, true))
+	case "tx":
+		tx := &levin.Transaction{Raw: data}
+		tx.ParseTx()
+		tx.ParseRctSig()
+		tx.CalcHash()
+		t := decodeTx(tx)
+		if w != nil {
+			t.OwnedOutputs, t.SpentOutputs = w.ScanTx(tx, 0)
+		}
+		out.Txs = append(out.Txs, t)
+	default:
+		log.Fatalf("unknown input type %q", *typ)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		log.Fatalf("encode: %v", err)
+	}
+}
+
+// readInput читает файл или stdin; hex текст декодируется
+func readInput(path string) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(string(data))
+	if len(text) > 0 && len(text)%2 == 0 {
+		if decoded, err := hex.DecodeString(text); err == nil {
+			return decoded, nil
+		}
+	}
+	return data, nil
+}
+
+func detectType(data []byte) string {
+	if len(data) >= 8 {
+		sig := binary.LittleEndian.Uint64(data)
+		if sig == levin.LevinSignature ||
+			(uint32(sig) == levin.PortableStorageSignatureA && uint32(sig>>32) == levin.PortableStorageSignatureB) {
+			return "levin"
+		}
+	}
+	// версия транзакции 1 или 2, major_version блока давно больше
+	if len(data) > 0 && data[0] <= 2 {
+		return "tx"
+	}
+	return "block"
+}
+
+// decodeLevin принимает payload NotifyResponseGetObjects, с levin заголовком или без
+func decodeLevin(data []byte) ([]*levin.Block, error) {
+	if len(data) >= levin.LevinHeaderSizeBytes && binary.LittleEndian.Uint64(data) == levin.LevinSignature {
+		if _, err := levin.NewHeaderFromBytesBytes(data[:levin.LevinHeaderSizeBytes]); err != nil {
+			return nil, fmt.Errorf("header: %w", err)
+		}
+		data = data[levin.LevinHeaderSizeBytes:]
+	}
+
+	storage, err := levin.NewPortableStorageFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*levin.Block
+	for _, entry := range storage.Entries {
+		if entry.Name != "blocks" {
+			continue
+		}
+		for _, blk := range entry.Entries() {
+			blocks = append(blocks, levin.NewBlockFromEntries(blk.Entries()))
+		}
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no blocks in storage")
+	}
+	return blocks, nil
+}
+
+// decodeBlock: headerOnly — голый blob блока, тел транзакций нет
+func decodeBlock(block *levin.Block, w *levin.Watcher, headerOnly bool) *blockJSON {
+	parse := block.FullfillBlockHeader
+	if headerOnly {
+		parse = block.FullfillHeaderOnly
+	}
+	if err := parse(); err != nil {
+		log.Fatalf("block header: %v", err)
+	}
+
+	res := &blockJSON{
+		Hash:        block.GetBlockId(),
+		Block:       block,
+		MinerTxHash: hex.EncodeToString(block.CalculateMinerTxHash()),
+		Txs:         []*txJSON{},
+	}
+	res.MinerExtra, _ = levin.ParseTxExtra(block.MinerTx.Extra)
+
+	for _, tx := range block.TXs {
+		// голый blob блока: известны только хэши
+		if tx.Raw == nil {
+			res.Txs = append(res.Txs, &txJSON{Hash: hex.EncodeToString(tx.Hash[:])})
+			continue
+		}
+		expected := tx.Hash
+		tx.ParseTx()
+		tx.ParseRctSig()
+		tx.CalcHash()
+
+		t := decodeTx(tx)
+		if tx.Hash != expected {
+			t.Error = fmt.Sprintf("calculated hash does not match block: %x", expected)
+		}
+		res.Txs = append(res.Txs, t)
+	}
+
+	if w != nil {
+		byTx := make(map[levin.Hash][]levin.OwnedOutput)
+		received, spent := w.ScanBlock(block)
+		for _, o := range received {
+			if o.Coinbase {
+				res.MinerOutputs = append(res.MinerOutputs, o)
+			} else {
+				byTx[o.TxHash] = append(byTx[o.TxHash], o)
+			}
+		}
+		spentByTx := make(map[levin.Hash][]levin.SpentOutput)
+		for _, s := range spent {
+			spentByTx[s.TxHash] = append(spentByTx[s.TxHash], s)
+		}
+		for _, t := range res.Txs {
+			if t.Transaction != nil {
+				t.OwnedOutputs = byTx[t.Transaction.Hash]
+				t.SpentOutputs = spentByTx[t.Transaction.Hash]
+			}
+		}
+	}
+	return res
+}
+
+func decodeTx(tx *levin.Transaction) *txJSON {
+	res := &txJSON{
+		Hash:        hex.EncodeToString(tx.Hash[:]),
+		Pruned:      tx.Pruned,
+		Transaction: tx,
+	}
+	if tx.Pruned {
+		res.PrunableHash = hex.EncodeToString(tx.PrunableHash[:])
+	}
+
+	extra, err := tx.ParseExtra()
+	res.ExtraFields = extra
+	if err != nil {
+		res.ExtraError = err.Error()
+	}
+
+	return res
+}
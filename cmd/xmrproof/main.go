@@ -0,0 +1,175 @@
+// xmrproof строит и проверяет доказательства платежа, совместимые с wallet2
+// (get_tx_proof / check_tx_proof / check_tx_key).
+//
+//	xmrproof get-out   -txid <hex> -address 4... -txkey <hex> [-message m]
+//	xmrproof get-in    -address 4... -viewkey <hex> [-message m] (-tx tx.hex | -daemon URL -txid <hex>)
+//	xmrproof check     -address 4... -proof OutProofV2... [-message m] (-tx tx.hex | -daemon URL -txid <hex>)
+//	xmrproof check-key -address 4... -txkey <hex> (-tx tx.hex | -daemon URL -txid <hex>)
+//
+// -txkey — строка tx_key из SignedTx или get_tx_key wallet2: r и дополнительные
+// ключи подряд. Транзакция берётся из файла (бинарный или hex) или у демона;
+// -login user:pass для демона с --rpc-login.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"xmr_scanner/levin"
+)
+
+type txFlags struct {
+	file   *string
+	daemon *string
+	login  *string
+	txid   *string
+}
+
+func addTxFlags(fs *flag.FlagSet) txFlags {
+	return txFlags{
+		file:   fs.String("tx", "", "transaction file, binary or hex"),
+		daemon: fs.String("daemon", "", "monerod RPC URL, e.g. http://127.0.0.1:18081"),
+		login:  fs.String("login", "", "daemon RPC login user:pass"),
+		txid:   fs.String("txid", "", "transaction hash (hex)"),
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	address := fs.String("address", "", "recipient address")
+	message := fs.String("message", "", "message signed together with the proof")
+	tf := addTxFlags(fs)
+
+	switch cmd {
+	case "get-out":
+		txKey := fs.String("txkey", "", "tx secret key(s), hex")
+		fs.Parse(args)
+		txHash := parseHash(*tf.txid)
+		r, additional := parseTxKey(*txKey)
+		proof, err := levin.GetOutProof(txHash, *address, *message, r, additional)
+		if err != nil {
+			log.Fatalf("get-out: %v", err)
+		}
+		fmt.Println(proof)
+
+	case "get-in":
+		viewKey := fs.String("viewkey", "", "recipient private view key (hex)")
+		fs.Parse(args)
+		a, err := levin.ParseKeyFromHex(*viewKey)
+		if err != nil {
+			log.Fatalf("view key: %v", err)
+		}
+		tx, _ := loadTx(tf)
+		proof, err := levin.GetInProof(tx, *address, *message, a)
+		if err != nil {
+			log.Fatalf("get-in: %v", err)
+		}
+		fmt.Println(proof)
+
+	case "check":
+		proof := fs.String("proof", "", "OutProofV2/InProofV2 signature")
+		fs.Parse(args)
+		tx, where := loadTx(tf)
+		res, err := levin.CheckTxProof(tx, *address, *message, *proof)
+		if err != nil {
+			log.Fatalf("INVALID: %v", err)
+		}
+		kind := "InProof (signed by the recipient)"
+		if res.Outbound {
+			kind = "OutProof (signed by the sender)"
+		}
+		fmt.Printf("VALID %s\n", kind)
+		report(tx, *address, res, where)
+
+	case "check-key":
+		txKey := fs.String("txkey", "", "tx secret key(s), hex")
+		fs.Parse(args)
+		tx, where := loadTx(tf)
+		r, additional := parseTxKey(*txKey)
+		res, err := levin.CheckTxKey(tx, *address, r, additional)
+		if err != nil {
+			log.Fatalf("check-key: %v", err)
+		}
+		report(tx, *address, res, where)
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	log.Fatalf("usage: xmrproof get-out|get-in|check|check-key [flags], see -h of a command")
+}
+
+func report(tx *levin.Transaction, address string, res *levin.TxProofResult, where string) {
+	fmt.Printf("tx %x: %s received %s XMR", tx.Hash, address, res.Received)
+	if len(res.Outputs) > 0 {
+		fmt.Printf(" in outputs %v", res.Outputs)
+	}
+	fmt.Println()
+	if where != "" {
+		fmt.Println(where)
+	}
+}
+
+// loadTx: из файла или у демона; второе значение — где транзакция по мнению демона
+func loadTx(tf txFlags) (*levin.Transaction, string) {
+	if *tf.file != "" {
+		data, err := os.ReadFile(*tf.file)
+		if err != nil {
+			log.Fatalf("read tx: %v", err)
+		}
+		if decoded, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil {
+			data = decoded
+		}
+		tx := &levin.Transaction{Raw: data}
+		tx.ParseTx()
+		tx.ParseRctSig()
+		tx.CalcHash()
+		if *tf.txid != "" && tx.Hash != parseHash(*tf.txid) {
+			log.Fatalf("tx file hash %x does not match -txid", tx.Hash)
+		}
+		return tx, ""
+	}
+
+	if *tf.daemon == "" || *tf.txid == "" {
+		log.Fatalf("either -tx or -daemon with -txid is required")
+	}
+	rpc := levin.NewDaemonRPC(*tf.daemon)
+	if *tf.login != "" {
+		rpc.Username, rpc.Password, _ = strings.Cut(*tf.login, ":")
+	}
+	tx, status, err := rpc.GetTransaction(parseHash(*tf.txid))
+	if err != nil {
+		log.Fatalf("daemon: %v", err)
+	}
+	if status.InPool {
+		return tx, "status: in pool, not confirmed yet"
+	}
+	return tx, fmt.Sprintf("status: in block %d", status.BlockHeight)
+}
+
+func parseHash(s string) levin.Hash {
+	k, err := levin.ParseKeyFromHex(strings.TrimSpace(s))
+	if err != nil {
+		log.Fatalf("txid: %v", err)
+	}
+	return levin.Hash(k)
+}
+
+func parseTxKey(s string) (levin.Key, []levin.Key) {
+	r, additional, err := levin.ParseTxKey(s)
+	if err != nil {
+		log.Fatalf("tx key: %v", err)
+	}
+	return r, additional
+}
@@ -0,0 +1,119 @@
+// xmrsign подписывает выгруженную онлайн транзакцию на машине без сети.
+//
+//	xmrsign -in unsigned.json -out signed.json -address 4... -viewkey <hex> -spendkey-file spend.key
+//
+// В выходном файле кроме blob — tx_key и key images входов: онлайн view-only
+// кошелёк импортирует их (SignedTx.ImportKeyImages), иначе не увидит трату.
+//
+// На stderr печатается, что подписывается: входы, получатели, сдача и комиссия.
+// Без -yes подпись ждёт подтверждения с терминала. Spend key лучше передавать
+// файлом: аргументы командной строки видны в списке процессов.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"xmr_scanner/levin"
+)
+
+func main() {
+	in := flag.String("in", "-", "unsigned tx file, - for stdin")
+	out := flag.String("out", "-", "signed tx file, - for stdout")
+	address := flag.String("address", "", "wallet address")
+	viewKey := flag.String("viewkey", "", "private view key (hex)")
+	spendKey := flag.String("spendkey", "", "private spend key (hex)")
+	spendKeyFile := flag.String("spendkey-file", "", "file with the private spend key (hex)")
+	yes := flag.Bool("yes", false, "sign without confirmation")
+	flag.Parse()
+
+	if *spendKeyFile != "" {
+		data, err := os.ReadFile(*spendKeyFile)
+		if err != nil {
+			log.Fatalf("spend key: %v", err)
+		}
+		*spendKey = strings.TrimSpace(string(data))
+	}
+	if *spendKey == "" {
+		log.Fatalf("spend key is required: -spendkey or -spendkey-file")
+	}
+	account, err := levin.NewAccount(*address, *viewKey)
+	if err != nil {
+		log.Fatalf("account: %v", err)
+	}
+	if err := account.SetSpendKey(*spendKey); err != nil {
+		log.Fatalf("account: %v", err)
+	}
+
+	var data []byte
+	if *in == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		log.Fatalf("read input: %v", err)
+	}
+	u, err := levin.ParseUnsignedTx(data)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	summary(u)
+	if !*yes && !confirm() {
+		log.Fatalf("not signed")
+	}
+
+	tx, err := u.Sign(account)
+	if err != nil {
+		log.Fatalf("sign: %v", err)
+	}
+	signed, err := json.MarshalIndent(levin.NewSignedTx(tx), "", "  ")
+	if err != nil {
+		log.Fatalf("encode: %v", err)
+	}
+	if *out == "-" {
+		_, err = os.Stdout.Write(append(signed, '\n'))
+	} else {
+		err = os.WriteFile(*out, signed, 0600)
+	}
+	if err != nil {
+		log.Fatalf("write output: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "signed tx %x, weight %d\n", tx.Hash, tx.Weight())
+	fmt.Fprintf(os.Stderr, "key images of %d inputs are in the output: import them into the view-only wallet\n", len(u.Inputs))
+}
+
+func summary(u *levin.UnsignedTx) {
+	var total levin.Amount
+	for _, ui := range u.Inputs {
+		total += ui.Output.Amount
+		fmt.Fprintf(os.Stderr, "input  %x:%d  %s XMR, ring %d\n", ui.Output.TxHash, ui.Output.OutputIndex, ui.Output.Amount, len(ui.Ring))
+	}
+	for _, d := range u.Destinations {
+		kind := "send  "
+		if d.Change {
+			kind = "change"
+		}
+		fmt.Fprintf(os.Stderr, "%s %s  %s XMR\n", kind, d.Address, d.Amount)
+	}
+	fmt.Fprintf(os.Stderr, "total in %s XMR, sent %s XMR, fee %s XMR\n", total, u.Sent(), u.Fee)
+}
+
+// confirm читает ответ с терминала: stdin может быть занят входным файлом
+func confirm() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		log.Fatalf("no terminal to confirm, use -yes: %v", err)
+	}
+	defer tty.Close()
+	fmt.Fprint(os.Stderr, "sign? [y/N] ")
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "y")
+}
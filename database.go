@@ -6,6 +6,8 @@ import (
 	"math/rand"
 	"net"
 	"time"
+
+	"xmr_scanner/levin"
 )
 
 type DatabaseMock struct{}
@@ -231,6 +233,83 @@ func (d *DatabaseMock) ProcessBlock(chainName string, block interface{}) error {
 	return nil
 }
 
+func (d *DatabaseMock) GetAccounts(coin string) ([]*levin.Account, error) {
+	account, err := levin.NewAccount(
+		"42LPBD4x3hv2fy2CeYPhyjUjTYSNnkvg1a2zj2F6YuSsSQCWac6Pp22RAfCG7djHbM3imHtizTwwoZW4TwFEdY97BRAyDxq",
+		"98540b36f09f5e5439f98f048e81e32fbbf19f836c962fef1510d3af605f0102",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []*levin.Account{account}, nil
+}
+
+func (d *DatabaseMock) GetUnspentOutputs(coin string) ([]levin.OwnedOutput, error) {
+	return nil, nil
+}
+
+func (d *DatabaseMock) ProcessSpentOutputs(chainName string, spent []levin.SpentOutput) error {
+	for _, s := range spent {
+		log.Printf("[*] Spent output %s: tx %x:%d spent in %x, amount %d, height %d", chainName, s.Output.TxHash, s.Output.OutputIndex, s.TxHash, s.Output.Amount, s.BlockHeight)
+	}
+	return nil
+}
+
+func (d *DatabaseMock) ProcessOutputTransitions(chainName string, transitions []levin.OutputTransition) error {
+	for _, t := range transitions {
+		log.Printf("[*] Output %s: tx %x:%d %s -> %s, confirmations %d", chainName, t.Output.TxHash, t.Output.OutputIndex, t.From, t.To, t.Confirmations)
+	}
+	return nil
+}
+
+func (d *DatabaseMock) ProcessBroadcastUpdates(chainName string, updates []levin.BroadcastUpdate) error {
+	for _, u := range updates {
+		log.Printf("[*] Broadcast %s: tx %x %s -> %s, attempts %d", chainName, u.Tx.Hash, u.From, u.To, u.Tx.Attempts)
+	}
+	return nil
+}
+
+func (d *DatabaseMock) GetRescanCheckpoints(coin string) ([]RescanCheckpoint, error) {
+	return nil, nil
+}
+
+func (d *DatabaseMock) SaveRescanCheckpoint(chainName string, cp RescanCheckpoint) error {
+	log.Printf("[*] Rescan checkpoint %s: %s at %d (%s), done %t", chainName, cp.Address, cp.Height, cp.Hash, cp.Done)
+	return nil
+}
+
+// GetDaemonURL: локальный monerod, счётчики выходов на старте берутся у него
+func (d *DatabaseMock) GetDaemonURL(coin string) (string, error) {
+	return "http://127.0.0.1:18081", nil
+}
+
+func (d *DatabaseMock) GetOutputCounters(coin string, height uint64) (*levin.OutputCounters, error) {
+	return nil, nil
+}
+
+func (d *DatabaseMock) SaveOutputCounters(chainName string, c levin.OutputCounters) error {
+	log.Printf("[*] Output counters %s: height %d, rct outputs %d", chainName, c.Height, c.Counts[0])
+	return nil
+}
+
+func (d *DatabaseMock) GetOpenInvoices(coin string) ([]levin.Invoice, error) {
+	return nil, nil
+}
+
+func (d *DatabaseMock) ProcessInvoiceUpdates(chainName string, updates []levin.InvoiceUpdate) error {
+	for _, u := range updates {
+		log.Printf("[*] Invoice %s %s: %s, received %d of %d, pending %d", chainName, u.Invoice.ID, u.Invoice.Status, u.Invoice.Received, u.Invoice.Amount, u.Invoice.Pending)
+	}
+	return nil
+}
+
+func (d *DatabaseMock) ProcessOwnedOutputs(chainName string, outputs []levin.OwnedOutput) error {
+	for _, out := range outputs {
+		log.Printf("[*] Owned output %s: tx %x:%d, amount %d, height %d", chainName, out.TxHash, out.OutputIndex, out.Amount, out.BlockHeight)
+	}
+	return nil
+}
+
 func (p Nodelist) GetRandomNode() string {
 	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 	randomIndex := rng.Intn(len(p))
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"fmt"
+
+	"xmr_scanner/levin"
+)
+
 type bScanner struct {
 	Scanner
 }
@@ -18,6 +24,21 @@ type DBWrapper interface {
 	GetNodeAddrs(coin string) (*Nodelist, error)
 	GetChainHeight(coin string) (int32, string, error)
 	ProcessBlock(chainName string, block interface{}) error
+	GetAccounts(coin string) ([]*levin.Account, error)
+	GetUnspentOutputs(coin string) ([]levin.OwnedOutput, error)
+	ProcessOwnedOutputs(chainName string, outputs []levin.OwnedOutput) error
+	ProcessSpentOutputs(chainName string, spent []levin.SpentOutput) error
+	ProcessOutputTransitions(chainName string, transitions []levin.OutputTransition) error
+	ProcessBroadcastUpdates(chainName string, updates []levin.BroadcastUpdate) error
+	GetOpenInvoices(coin string) ([]levin.Invoice, error)
+	ProcessInvoiceUpdates(chainName string, updates []levin.InvoiceUpdate) error
+	GetRescanCheckpoints(coin string) ([]RescanCheckpoint, error)
+	SaveRescanCheckpoint(chainName string, cp RescanCheckpoint) error
+	// счётчики хранятся по высотам: rescan берёт их на своей стартовой высоте
+	GetOutputCounters(coin string, height uint64) (*levin.OutputCounters, error)
+	SaveOutputCounters(chainName string, c levin.OutputCounters) error
+	// monerod RPC для счётчиков выходов, когда их нет в базе; пусто — не используется
+	GetDaemonURL(coin string) (string, error)
 }
 
 func New(coin string, n Notifier, d DBWrapper) (*bScanner, error) {
@@ -32,9 +53,73 @@ func New(coin string, n Notifier, d DBWrapper) (*bScanner, error) {
 		return nil, err
 	}
 
+	accounts, err := d.GetAccounts(coin)
+	if err != nil {
+		return nil, err
+	}
+
+	unspent, err := d.GetUnspentOutputs(coin)
+	if err != nil {
+		return nil, err
+	}
+
+	invoices, err := d.GetOpenInvoices(coin)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := d.GetRescanCheckpoints(coin)
+	if err != nil {
+		return nil, err
+	}
+
+	counters, err := d.GetOutputCounters(coin, uint64(height))
+	if err != nil {
+		return nil, err
+	}
+
+	daemonURL, err := d.GetDaemonURL(coin)
+	if err != nil {
+		return nil, err
+	}
+
 	switch coin {
 	case "XMR":
-		scn.Scanner = NewScannerXMR(nodes, height, hash, n, d, coin)
+		scanner := NewScannerXMR(nodes, height, hash, n, d, coin)
+		if daemonURL != "" {
+			scanner.SetDaemon(levin.NewDaemonRPC(daemonURL))
+		}
+		if counters == nil && daemonURL != "" {
+			// не вышло — счётчики возьмутся перед первой пачкой блоков
+			if counters, err = scanner.outputCounters(uint64(height), hash); err != nil {
+				n.NotifyWithLevel(fmt.Sprintf("Output counters at %d: %s", height, err), LevelError)
+			}
+		}
+		if counters != nil {
+			scanner.SetOutputCounters(*counters)
+		}
+		rescans := make(map[string]RescanCheckpoint)
+		for _, cp := range checkpoints {
+			if !cp.Done {
+				rescans[cp.Address] = cp
+			}
+		}
+		for _, a := range accounts {
+			if cp, ok := rescans[a.Address]; ok {
+				scanner.ResumeRescan(a, cp) // кошелёк попадёт в watcher, когда rescan догонит скан
+				continue
+			}
+			scanner.watcher.AddAccount(a)
+		}
+		for _, o := range unspent {
+			scanner.watcher.TrackOutput(o)
+		}
+		for _, inv := range invoices {
+			if err := scanner.reconciler.AddInvoice(inv); err != nil {
+				return nil, err
+			}
+		}
+		scn.Scanner = scanner
 	default:
 		scn.Scanner = nil
 	}
@@ -0,0 +1,157 @@
+package levin
+
+import (
+	"fmt"
+	"sync"
+
+	"filippo.io/edwards25519"
+)
+
+// Account — кошелёк под наблюдением. Ключи декодируются один раз при создании.
+type Account struct {
+	Address string
+
+	PubSpend Key
+	PubView  Key
+	viewKey  Key
+	spendKey *Key // nil — view-only
+
+	viewScalar *edwards25519.Scalar // viewKey для скана, разбирается один раз
+
+	mu             sync.RWMutex
+	subaddresses   map[Key]SubaddressIndex // D -> (major, minor), строится при первом скане
+	minorEnd       map[uint32]uint32
+	lookaheadMajor uint32
+	lookaheadMinor uint32
+}
+
+// NewAccount: адрес и приватный view key (hex)
+func NewAccount(address string, privateViewKey string) (*Account, error) {
+	pubSpend, pubView, err := DecodeAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode address: %w", err)
+	}
+
+	viewKey, err := ParseKeyFromHex(privateViewKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private view key: %w", err)
+	}
+
+	if *viewKey.PubKey() != Key(pubView) {
+		return nil, fmt.Errorf("private view key does not match address")
+	}
+	viewScalar, err := new(edwards25519.Scalar).SetCanonicalBytes(viewKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private view key: %w", err)
+	}
+
+	return &Account{
+		Address:  address,
+		PubSpend: Key(pubSpend),
+		PubView:  Key(pubView),
+		viewKey:  viewKey,
+
+		viewScalar: viewScalar,
+
+		minorEnd:       make(map[uint32]uint32),
+		lookaheadMajor: SubaddressLookaheadMajor,
+		lookaheadMinor: SubaddressLookaheadMinor,
+	}, nil
+}
+
+// NewAccountFromSpendKey: обычный (не субадресный) кошелёк сети network по
+// приватному spend key, view key выводится из него как в wallet2
+func NewAccountFromSpendKey(spendKey Key, network Network) (*Account, error) {
+	viewKey := ViewKeyFromSpendKey(spendKey)
+	address, err := EncodeAddress(network, AddressStandard, *spendKey.PubKey(), *viewKey.PubKey(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := NewAccount(address, viewKey.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := account.SetSpendKey(spendKey.String()); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// NewAccountFromMnemonic: кошелёк по 25-словной сид-фразе
+func NewAccountFromMnemonic(mnemonic string, network Network) (*Account, error) {
+	spendKey, err := DecodeMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	ScReduce32(&spendKey) // старые сиды бывают не приведены, wallet2 делает так же
+	return NewAccountFromSpendKey(spendKey, network)
+}
+
+// Mnemonic: сид-фраза кошелька, только если известен spend key
+func (a *Account) Mnemonic() (string, error) {
+	if a.spendKey == nil {
+		return "", fmt.Errorf("account %s is view-only", a.Address)
+	}
+	return EncodeMnemonic(*a.spendKey), nil
+}
+
+// ViewKey возвращает приватный view key
+func (a *Account) ViewKey() Key {
+	return a.viewKey
+}
+
+// IntegratedAddress: основной адрес кошелька с зашитым 8-байтным payment id
+func (a *Account) IntegratedAddress(paymentID [8]byte) (string, error) {
+	p, err := ParseAddress(a.Address)
+	if err != nil {
+		return "", err
+	}
+	if p.Type != AddressStandard {
+		return "", fmt.Errorf("integrated address needs a standard address, got %s", p.Type)
+	}
+	return EncodeAddress(p.Network, AddressIntegrated, a.PubSpend, a.PubView, paymentID[:])
+}
+
+// SetSpendKey: с приватным spend key watcher сам считает key images и видит траты
+func (a *Account) SetSpendKey(privateSpendKey string) error {
+	spendKey, err := ParseKeyFromHex(privateSpendKey)
+	if err != nil {
+		return fmt.Errorf("failed to decode private spend key: %w", err)
+	}
+	if *spendKey.PubKey() != a.PubSpend {
+		return fmt.Errorf("private spend key does not match address")
+	}
+	a.spendKey = &spendKey
+	return nil
+}
+
+// SpendKey: ok == false для view-only кошелька
+func (a *Account) SpendKey() (Key, bool) {
+	if a.spendKey == nil {
+		return Key{}, false
+	}
+	return *a.spendKey, true
+}
+
+// outputKeyImage: x = Hs(derivation || i) + b (+ m для субадреса), KI = x * Hp(P)
+func (a *Account) outputKeyImage(derivation *Key, index uint64, sub SubaddressIndex, P Hash) (Hash, error) {
+	if a.spendKey == nil {
+		return Hash{}, fmt.Errorf("account %s is view-only", a.Address)
+	}
+
+	x := DeriveSecretKey(derivation, index, a.spendKey)
+	if sub != (SubaddressIndex{}) {
+		m, err := subaddressSecret(&a.viewKey, sub)
+		if err != nil {
+			return Hash{}, err
+		}
+		mKey := Key(m.Bytes())
+		ScAdd(&x, &x, &mKey)
+	}
+	if *x.PubKey() != Key(P) {
+		return Hash{}, fmt.Errorf("derived secret key doesn't match output key %x", P)
+	}
+
+	return Hash(GenerateKeyImage(&x)), nil
+}
@@ -0,0 +1,176 @@
+package levin
+
+import (
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// Network — сеть Monero, определяется префиксом адреса
+type Network uint8
+
+const (
+	Mainnet Network = iota
+	Testnet
+	Stagenet
+)
+
+func (n Network) String() string {
+	switch n {
+	case Mainnet:
+		return "mainnet"
+	case Testnet:
+		return "testnet"
+	case Stagenet:
+		return "stagenet"
+	}
+	return fmt.Sprintf("network(%d)", uint8(n))
+}
+
+// AddressType — обычный адрес, субадрес или интегрированный (с payment id)
+type AddressType uint8
+
+const (
+	AddressStandard AddressType = iota
+	AddressSubaddress
+	AddressIntegrated
+)
+
+func (t AddressType) String() string {
+	switch t {
+	case AddressStandard:
+		return "standard"
+	case AddressSubaddress:
+		return "subaddress"
+	case AddressIntegrated:
+		return "integrated"
+	}
+	return fmt.Sprintf("address_type(%d)", uint8(t))
+}
+
+// префиксы из cryptonote_config.h; все меньше 0x80, так что varint в один байт
+var addressPrefixes = map[Network][3]byte{
+	//            standard, subaddress, integrated
+	Mainnet:  {0x12, 0x2A, 0x13},
+	Testnet:  {0x35, 0x3F, 0x36},
+	Stagenet: {0x18, 0x24, 0x19},
+}
+
+const (
+	addressLength           = 69 // prefix + spend + view + checksum
+	integratedAddressLength = 77 // + 8 байт payment id
+	paymentIDLength         = 8
+	addressChecksumLength   = 4
+)
+
+var (
+	ErrAddressEncoding = errors.New("invalid address encoding")
+	ErrAddressPrefix   = errors.New("unknown address prefix")
+	ErrAddressLength   = errors.New("invalid address length")
+	ErrAddressChecksum = errors.New("address checksum mismatch")
+	ErrAddressKey      = errors.New("invalid address public key")
+	ErrAddressNetwork  = errors.New("address from another network")
+)
+
+// ParsedAddress — разобранный и проверенный адрес
+type ParsedAddress struct {
+	Network   Network
+	Type      AddressType
+	PubSpend  Key
+	PubView   Key
+	PaymentID []byte // 8 байт, только у интегрированного
+}
+
+// ParseAddress проверяет кодировку, префикс, длину, контрольную сумму и
+// ключи адреса. Ошибки оборачивают ErrAddress*, их можно различать errors.Is.
+func ParseAddress(addr string) (*ParsedAddress, error) {
+	b, err := decodeMoneroBase58(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAddressEncoding, err)
+	}
+	// base58 неоднозначен при переполнении блока, канонична только обратная кодировка
+	if encodeMoneroBase58(b) != addr {
+		return nil, fmt.Errorf("%w: non-canonical base58", ErrAddressEncoding)
+	}
+
+	p := &ParsedAddress{}
+	if p.Network, p.Type, err = addressPrefixInfo(b[0]); err != nil {
+		return nil, err
+	}
+
+	expected := addressLength
+	if p.Type == AddressIntegrated {
+		expected = integratedAddressLength
+	}
+	if len(b) != expected {
+		return nil, fmt.Errorf("%w: %s %s address must be %d bytes, got %d", ErrAddressLength, p.Network, p.Type, expected, len(b))
+	}
+
+	body := b[:len(b)-addressChecksumLength]
+	if !equalBytes(keccak256(body)[:addressChecksumLength], b[len(body):]) {
+		return nil, ErrAddressChecksum
+	}
+
+	copy(p.PubSpend[:], b[1:33])
+	copy(p.PubView[:], b[33:65])
+	if p.Type == AddressIntegrated {
+		p.PaymentID = append([]byte(nil), b[65:73]...)
+	}
+
+	for _, k := range []Key{p.PubSpend, p.PubView} {
+		if _, err := new(edwards25519.Point).SetBytes(k[:]); err != nil {
+			return nil, fmt.Errorf("%w: %x", ErrAddressKey, k)
+		}
+	}
+	return p, nil
+}
+
+// ValidateAddress: адрес корректен и принадлежит сети network
+func ValidateAddress(addr string, network Network) error {
+	p, err := ParseAddress(addr)
+	if err != nil {
+		return err
+	}
+	if p.Network != network {
+		return fmt.Errorf("%w: %s address on %s", ErrAddressNetwork, p.Network, network)
+	}
+	return nil
+}
+
+// String кодирует адрес обратно
+func (p *ParsedAddress) String() string {
+	addr, _ := EncodeAddress(p.Network, p.Type, p.PubSpend, p.PubView, p.PaymentID)
+	return addr
+}
+
+// EncodeAddress: prefix || spend || view [|| payment id] || checksum в base58 Monero.
+// paymentID (8 байт) нужен только для AddressIntegrated.
+func EncodeAddress(network Network, typ AddressType, pubSpend, pubView Key, paymentID []byte) (string, error) {
+	prefixes, ok := addressPrefixes[network]
+	if !ok || typ > AddressIntegrated {
+		return "", fmt.Errorf("%w: %s %s", ErrAddressPrefix, network, typ)
+	}
+	if (typ == AddressIntegrated) != (paymentID != nil) || (paymentID != nil && len(paymentID) != paymentIDLength) {
+		return "", fmt.Errorf("%s address: unexpected payment id of %d bytes", typ, len(paymentID))
+	}
+
+	data := make([]byte, 0, integratedAddressLength)
+	data = append(data, prefixes[typ])
+	data = append(data, pubSpend[:]...)
+	data = append(data, pubView[:]...)
+	data = append(data, paymentID...)
+	data = append(data, keccak256(data)[:addressChecksumLength]...)
+	return encodeMoneroBase58(data), nil
+}
+
+func addressPrefixInfo(prefix byte) (Network, AddressType, error) {
+	for network, prefixes := range addressPrefixes {
+		for typ, p := range prefixes {
+			if p == prefix {
+				return network, AddressType(typ), nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: 0x%02x", ErrAddressPrefix, prefix)
+}
@@ -0,0 +1,209 @@
+package levin
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"filippo.io/edwards25519"
+)
+
+// опубликованные адреса; ключи сверены с ParseAddress
+var addressVectors = []struct {
+	address string
+	network Network
+	typ     AddressType
+	spend   string
+	view    string
+	pid     string
+}{
+	{testAddress, Mainnet, AddressStandard, "", "", ""},
+	{"888tNkZrPN6JsEgekjMnABU4TBzc2Dt29EPAvkRxbANsAnjyPbb3iQ1YBRk1UXcdRsiKc9dhwMVgN5S9cQUiyoogDavup3H", Mainnet, AddressSubaddress,
+		"95f965b0c4ff276ad08d06ab69a8c8a1c73a7e7ab89b7e5001df3733cd1c383a", "85be1dfe95652aba69625405fe5d6af70a77439402765b1a81b8dc7447c07b6f", ""},
+	{"4LL9oSLmtpccfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2bYXZKKQePHES9khPK", Mainnet, AddressIntegrated,
+		"eda9fe8dfcdd25d5430ea64229d04f6b41b2e5a1587c29cd499a63eb79d11711", "3076a02b73d130fb904c9e91075fcd16f735c6850dfadb125eb826d96a113f09", "8a125052fe6f3877"},
+	{"9uVsvEryzpN8WH2t1WWhFFCG5tS8cBNdmJYNRuckLENFimfauV5pZKeS1P2CbxGkSDTUPHXWwiYE5ZGSXDAGbaZgDxobqDN", Testnet, AddressStandard,
+		"3d86481e3745ef2cde396a386ba9724351d00044326c2868deb24a7256472cf9", "ba2041507a095195811e66abc65a0a9e443f8fa669bebac0e8345316fd1deb72", ""},
+	{"5B8s3obCY2ETeQB3GNAGPK2zRGen5UeW1WzegSizVsmf6z5NvM2GLoN6zzk1vHyzGAAfA8pGhuYAeCFZjHAp59jRVQkunGS", Stagenet, AddressStandard,
+		"f58e8d02b44abb9f4c892b62cda2c20be5b3a55df4db1eb35279979b05cf7623", "c6bf5acd9653d523df06c589226ed339bda10f3007d0cb4344176fc2fa38ecfb", ""},
+}
+
+func hexKey(t *testing.T, s string) Key {
+	t.Helper()
+	var k Key
+	if n, err := hex.Decode(k[:], []byte(s)); err != nil || n != len(k) {
+		t.Fatalf("key %q: %v", s, err)
+	}
+	return k
+}
+
+func TestParseAddressVectors(t *testing.T) {
+	for _, v := range addressVectors {
+		t.Run(v.address[:8], func(t *testing.T) {
+			p, err := ParseAddress(v.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Network != v.network || p.Type != v.typ {
+				t.Fatalf("%s %s, expected %s %s", p.Network, p.Type, v.network, v.typ)
+			}
+			if v.spend != "" && (p.PubSpend != hexKey(t, v.spend) || p.PubView != hexKey(t, v.view)) {
+				t.Fatalf("keys %x %x", p.PubSpend, p.PubView)
+			}
+			if hex.EncodeToString(p.PaymentID) != v.pid {
+				t.Fatalf("payment id %x", p.PaymentID)
+			}
+			if err := ValidateAddress(v.address, v.network); err != nil {
+				t.Fatal(err)
+			}
+			if p.String() != v.address {
+				t.Fatalf("re-encoded %s", p.String())
+			}
+		})
+	}
+}
+
+// ключи одного адреса во всех сетях и типах: первый символ задаётся префиксом
+func TestEncodeAddressNetworks(t *testing.T) {
+	p, err := ParseAddress(addressVectors[3].address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	leading := map[Network][3]string{
+		Mainnet:  {"4", "8", "4"},
+		Testnet:  {"9", "B", "A"},
+		Stagenet: {"5", "7", "5"},
+	}
+	for network, first := range leading {
+		for typ := AddressStandard; typ <= AddressIntegrated; typ++ {
+			var id []byte
+			if typ == AddressIntegrated {
+				id = pid
+			}
+			addr, err := EncodeAddress(network, typ, p.PubSpend, p.PubView, id)
+			if err != nil {
+				t.Fatalf("%s %s: %v", network, typ, err)
+			}
+			expected := 95
+			if typ == AddressIntegrated {
+				expected = 106
+			}
+			if len(addr) != expected || !strings.HasPrefix(addr, first[typ]) {
+				t.Fatalf("%s %s: %s", network, typ, addr)
+			}
+
+			parsed, err := ParseAddress(addr)
+			if err != nil {
+				t.Fatalf("%s %s: %v", network, typ, err)
+			}
+			if parsed.Network != network || parsed.Type != typ || parsed.PubSpend != p.PubSpend || parsed.PubView != p.PubView || string(parsed.PaymentID) != string(id) {
+				t.Fatalf("%s %s: parsed %+v", network, typ, parsed)
+			}
+			for other := Mainnet; other <= Stagenet; other++ {
+				err := ValidateAddress(addr, other)
+				if (other == network) != (err == nil) {
+					t.Fatalf("%s %s validated on %s: %v", network, typ, other, err)
+				}
+				if err != nil && !errors.Is(err, ErrAddressNetwork) {
+					t.Fatalf("%s %s on %s: %v", network, typ, other, err)
+				}
+			}
+		}
+	}
+}
+
+// reencode: байты адреса с правкой, контрольная сумма пересчитывается при fixChecksum
+func reencode(t *testing.T, addr string, edit func([]byte) []byte, fixChecksum bool) string {
+	t.Helper()
+	b, err := decodeMoneroBase58(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = edit(b)
+	if fixChecksum {
+		body := b[:len(b)-addressChecksumLength]
+		b = append(body, keccak256(body)[:addressChecksumLength]...)
+	}
+	return encodeMoneroBase58(b)
+}
+
+func TestParseAddressRejects(t *testing.T) {
+	std, integrated := addressVectors[0].address, addressVectors[2].address
+	tests := []struct {
+		name    string
+		address string
+		err     error
+	}{
+		{"bad checksum", reencode(t, std, func(b []byte) []byte { b[len(b)-1] ^= 1; return b }, false), ErrAddressChecksum},
+		{"flipped key", reencode(t, std, func(b []byte) []byte { b[5] ^= 1; return b }, false), ErrAddressChecksum},
+		{"truncated", std[:len(std)-1], ErrAddressEncoding},
+		{"overlong", std + "1", ErrAddressEncoding},
+		{"overlong by 3", std + "111", ErrAddressEncoding},
+		{"block overflow", std[:len(std)-7] + "zzzzzzz", ErrAddressEncoding},
+		// корректный base58 и контрольная сумма, но не та длина
+		{"missing block", reencode(t, std, func(b []byte) []byte { return append(b[:57], b[65:]...) }, true), ErrAddressLength},
+		{"extra block", reencode(t, std, func(b []byte) []byte { return append(b[:65], append(make([]byte, 8), b[65:]...)...) }, true), ErrAddressLength},
+		{"non-base58", strings.Replace(std, std[10:11], "0", 1), ErrAddressEncoding},
+		{"empty", "", ErrAddressEncoding},
+		{"unknown prefix", reencode(t, std, func(b []byte) []byte { b[0] = 0x7f; return b }, true), ErrAddressPrefix},
+		// интегрированный с payment id не в 8 байт, контрольная сумма верна
+		{"integrated short payment id", reencode(t, integrated, func(b []byte) []byte {
+			return append(b[:65+4], b[73:]...)
+		}, true), ErrAddressLength},
+		{"integrated without payment id", reencode(t, integrated, func(b []byte) []byte {
+			return append(b[:65], b[73:]...)
+		}, true), ErrAddressLength},
+		{"key not on curve", reencode(t, std, func(b []byte) []byte {
+			copy(b[1:33], notOnCurve(t))
+			return b
+		}, true), ErrAddressKey},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseAddress(tc.address); !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if err := ValidateAddress(tc.address, Mainnet); !errors.Is(err, tc.err) {
+				t.Fatalf("validate: expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestEncodeAddressRejects(t *testing.T) {
+	p, err := ParseAddress(testAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		network Network
+		typ     AddressType
+		pid     []byte
+	}{
+		"integrated short payment id":   {Mainnet, AddressIntegrated, make([]byte, 4)},
+		"integrated long payment id":    {Mainnet, AddressIntegrated, make([]byte, 32)},
+		"integrated without payment id": {Mainnet, AddressIntegrated, nil},
+		"standard with payment id":      {Mainnet, AddressStandard, make([]byte, 8)},
+		"unknown network":               {Network(9), AddressStandard, nil},
+		"unknown type":                  {Mainnet, AddressType(3), nil},
+	} {
+		if addr, err := EncodeAddress(tc.network, tc.typ, p.PubSpend, p.PubView, tc.pid); err == nil {
+			t.Errorf("%s: encoded %s", name, addr)
+		}
+	}
+}
+
+// notOnCurve: 32 байта, которые не декодируются в точку
+func notOnCurve(t *testing.T) []byte {
+	t.Helper()
+	for i := byte(2); i != 0; i++ {
+		k := Key{i}
+		if _, err := new(edwards25519.Point).SetBytes(k[:]); err != nil {
+			return k[:]
+		}
+	}
+	t.Fatal("no invalid point found")
+	return nil
+}
@@ -0,0 +1,78 @@
+package levin
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Amount — сумма в атомарных единицах (пиконеро). Все суммы в API только так,
+// float64 теряет младшие разряды уже на балансах порядка тысяч XMR.
+type Amount uint64
+
+const (
+	Piconero Amount = 1
+	XMR      Amount = 1_000_000_000_000
+
+	amountDecimals = 12
+)
+
+// ParseAmount разбирает сумму в XMR: "1", "0.5", "12.000000000001".
+// Больше 12 знаков после точки, знак и экспонента — ошибка, без округления.
+func ParseAmount(s string) (Amount, error) {
+	whole, frac, hasDot := strings.Cut(s, ".")
+	if whole == "" || (hasDot && frac == "") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > amountDecimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimals", s, amountDecimals)
+	}
+	for _, part := range []string{whole, frac} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return 0, fmt.Errorf("invalid amount %q", s)
+			}
+		}
+	}
+
+	w, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	var f uint64
+	if frac != "" {
+		// дополняем нулями до пиконеро: "5" -> 500000000000
+		if f, err = strconv.ParseUint(frac+strings.Repeat("0", amountDecimals-len(frac)), 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+		}
+	}
+
+	hi, lo := bits.Mul64(w, uint64(XMR))
+	sum, carry := bits.Add64(lo, f, 0)
+	if hi != 0 || carry != 0 {
+		return 0, fmt.Errorf("amount %q overflows", s)
+	}
+	return Amount(sum), nil
+}
+
+// String: XMR без хвостовых нулей, "0.000123", "1.5", "2"
+func (a Amount) String() string {
+	whole, frac := uint64(a/XMR), uint64(a%XMR)
+	if frac == 0 {
+		return strconv.FormatUint(whole, 10)
+	}
+	f := fmt.Sprintf("%012d", frac)
+	return strconv.FormatUint(whole, 10) + "." + strings.TrimRight(f, "0")
+}
+
+// Add/Sub с проверкой переполнения, для сумм из внешних источников
+func (a Amount) Add(b Amount) (Amount, bool) {
+	sum, carry := bits.Add64(uint64(a), uint64(b), 0)
+	return Amount(sum), carry == 0
+}
+
+func (a Amount) Sub(b Amount) (Amount, bool) {
+	diff, borrow := bits.Sub64(uint64(a), uint64(b), 0)
+	return Amount(diff), borrow == 0
+}
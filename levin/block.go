@@ -11,8 +11,12 @@ import (
 )
 
 type Block struct {
-	block []byte   `json:"-"`
-	tx    [][]byte `json:"-"`
+	block        []byte   `json:"-"`
+	tx           [][]byte `json:"-"`
+	prunableHash []Hash   `json:"-"`
+	weight       uint64   // block_weight из pruned ответа
+
+	Pruned bool `json:"pruned"`
 
 	MajorVersion      uint8  `json:"major_version"`
 	MinorVersion      uint8  `json:"minor_version"`
@@ -53,7 +57,73 @@ func (b *Block) InsertTx(data []byte) {
 	b.tx = append(b.tx, data)
 }
 
+// NewBlockFromEntries собирает блок из block_complete_entry ответа NotifyResponseGetObjects
+func NewBlockFromEntries(entries Entries) *Block {
+	block := NewBlock()
+	for _, ibl := range entries {
+		switch ibl.Name {
+		case "block":
+			block.SetBlockData([]byte(ibl.String()))
+		case "block_weight":
+			block.weight = ibl.Uint64()
+		case "txs":
+			for _, itx := range ibl.Entries() {
+				// pruned: {blob, prunable_hash}, иначе просто blob
+				txEntries, ok := itx.Value.(Entries)
+				if !ok {
+					block.InsertTx([]byte(itx.String()))
+					continue
+				}
+				var blob string
+				var prunableHash Hash
+				for _, f := range txEntries {
+					switch f.Name {
+					case "blob":
+						blob = f.String()
+					case "prunable_hash":
+						copy(prunableHash[:], f.String())
+					}
+				}
+				block.InsertPrunedTx([]byte(blob), prunableHash)
+			}
+		}
+	}
+	return block
+}
+
+// Weight: вес блока. В pruned ответе его присылает нода, иначе считаем сами:
+// blob блока (заголовок, coinbase, хэши) плюс веса транзакций.
+func (b *Block) Weight() uint64 {
+	if b.weight != 0 {
+		return b.weight
+	}
+	weight := uint64(len(b.block))
+	for _, tx := range b.TXs {
+		weight += tx.Weight()
+	}
+	return weight
+}
+
+// InsertPrunedTx: tx_blob_entry из pruned ответа — префикс + RCT base и хэш prunable части
+func (b *Block) InsertPrunedTx(data []byte, prunableHash Hash) {
+	b.Pruned = true
+	b.tx = append(b.tx, data)
+	b.prunableHash = append(b.prunableHash, prunableHash)
+}
+
+// FullfillBlockHeader разбирает блок вместе с телами транзакций: их должно быть
+// ровно TxsCount, иначе блок нельзя сканировать и индексировать.
 func (block *Block) FullfillBlockHeader() error {
+	return block.fullfill(false)
+}
+
+// FullfillHeaderOnly разбирает голый blob блока без тел транзакций (xmrdecode):
+// у транзакций заполняются только хэши.
+func (block *Block) FullfillHeaderOnly() error {
+	return block.fullfill(true)
+}
+
+func (block *Block) fullfill(headerOnly bool) error {
 	if len(block.block) < 43 {
 		return fmt.Errorf("block data too short: %d bytes", len(block.block))
 	}
@@ -96,9 +166,19 @@ func (block *Block) FullfillBlockHeader() error {
 	reader.Seek(1, io.SeekCurrent)
 	//----
 	block.TxsCount, _ = ReadVarint(reader)
+	if headerOnly {
+		block.tx, block.prunableHash, block.Pruned = nil, nil, false
+	} else if int(block.TxsCount) != len(block.tx) {
+		return fmt.Errorf("block lists %d txs, got %d", block.TxsCount, len(block.tx))
+	}
 	for i := 0; i <= int(block.TxsCount)-1; i++ {
-		tx := &Transaction{
-			Raw: block.tx[i],
+		tx := &Transaction{}
+		if i < len(block.tx) {
+			tx.Raw = block.tx[i]
+		}
+		if block.Pruned {
+			tx.Pruned = true
+			tx.PrunableHash = block.prunableHash[i]
 		}
 		reader.Read(tx.Hash[:])
 		block.TXs = append(block.TXs, tx)
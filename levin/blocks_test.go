@@ -0,0 +1,79 @@
+package levin
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// levin дампы из ../blocks, разбираются один раз на весь прогон. Тесты их не меняют.
+var testBlocks struct {
+	once   sync.Once
+	blocks []*Block
+	err    error
+}
+
+func loadTestBlocks(tb testing.TB) []*Block {
+	tb.Helper()
+	testBlocks.once.Do(func() {
+		testBlocks.blocks, testBlocks.err = readDumps(filepath.Join("..", "blocks"))
+	})
+	if testBlocks.err != nil {
+		tb.Fatalf("load blocks: %v", testBlocks.err)
+	}
+	if len(testBlocks.blocks) == 0 {
+		tb.Skip("no levin dumps in ../blocks")
+	}
+	return testBlocks.blocks
+}
+
+func readDumps(dir string) ([]*Block, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.bin"))
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*Block
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) >= LevinHeaderSizeBytes && binary.LittleEndian.Uint64(data) == LevinSignature {
+			data = data[LevinHeaderSizeBytes:]
+		}
+		storage, err := NewPortableStorageFromBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range storage.Entries {
+			if entry.Name != "blocks" {
+				continue
+			}
+			for _, blk := range entry.Entries() {
+				block := NewBlockFromEntries(blk.Entries())
+				if err := block.FullfillBlockHeader(); err != nil {
+					return nil, err
+				}
+				for _, tx := range block.TXs {
+					tx.ParseTx()
+					tx.ParseRctSig()
+					tx.CalcHash()
+				}
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// testTxs: все транзакции дампов, кроме coinbase
+func testTxs(tb testing.TB) []*Transaction {
+	var txs []*Transaction
+	for _, b := range loadTestBlocks(tb) {
+		txs = append(txs, b.TXs...)
+	}
+	return txs
+}
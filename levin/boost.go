@@ -87,6 +87,15 @@ func (v BoostUint64) Bytes() []byte {
 	return b
 }
 
+type BoostBool bool
+
+func (v BoostBool) Bytes() []byte {
+	if v {
+		return []byte{BoostSerializeTypeBool, 0x01}
+	}
+	return []byte{BoostSerializeTypeBool, 0x00}
+}
+
 type BoostString string
 
 func (v BoostString) Bytes() []byte {
@@ -195,3 +204,24 @@ func (blockIds BoostBlock) Bytes() []byte {
 
 	return result
 }
+
+// BoostBlobs — массив строк (std::vector<blobdata>), например txs в NOTIFY_NEW_TRANSACTIONS
+type BoostBlobs [][]byte
+
+func (blobs BoostBlobs) Bytes() []byte {
+	countB, err := VarIn(len(blobs))
+	if err != nil {
+		panic(fmt.Errorf("varin '%d': %w", len(blobs), err))
+	}
+
+	result := append([]byte{BoostSerializeTypeString | BoostSerializeFlagArray}, countB...)
+	for _, blob := range blobs {
+		lenB, err := VarIn(len(blob))
+		if err != nil {
+			panic(fmt.Errorf("varin '%d': %w", len(blob), err))
+		}
+		result = append(result, lenB...)
+		result = append(result, blob...)
+	}
+	return result
+}
@@ -2,8 +2,10 @@ package levin
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"sync"
 
 	"filippo.io/edwards25519"
 )
@@ -25,6 +27,11 @@ type MultiexpData struct {
 const maxN = 64
 const maxM = 16
 
+var (
+	exponentsOnce  sync.Once
+	exponentsCache *Exponent
+)
+
 func (t *Transaction) signBpp() (Bpp, error) {
 	bpp := Bpp{}
 	// Bulletproof Plus для доказательства, что суммы выходов положительные
@@ -149,10 +156,13 @@ func createBulletproofPlus(amounts []uint64, masks []*edwards25519.Scalar) (Bpp,
 	}
 
 	for j := 0; j < M; j++ {
-		for i := N - 1; i >= 0; i-- {
-			amountBytes := make([]byte, 8)
+		// строки сверх числа выходов (M — степень двойки) доказывают ноль
+		amountBytes := make([]byte, 8)
+		if j < len(amounts) {
 			binary.LittleEndian.PutUint64(amountBytes, amounts[j])
-			if j < len(amounts) && (amountBytes[i/8]&(1<<(i%8))) != 0 {
+		}
+		for i := N - 1; i >= 0; i-- {
+			if (amountBytes[i/8] & (1 << (i % 8))) != 0 {
 				aL[j*N+i] = Identity
 				aL8[j*N+i] = INV_EIGHT
 				aR[j*N+i] = Zero
@@ -166,7 +176,7 @@ func createBulletproofPlus(amounts []uint64, masks []*edwards25519.Scalar) (Bpp,
 		}
 	}
 
-	exponent := initExponents(maxN, maxM)
+	exponent := cachedExponents()
 
 	var buf bytes.Buffer
 	for _, v := range V {
@@ -537,20 +547,39 @@ func computeLR(size int, y Key, G *[]edwards25519.Point, G0 int, H *[]edwards255
 	return *key.KeyToPoint()
 }
 
-// multiexp вычисляет multi-scalar multiplication: sum(scalar[i] * point[i])
+// multiexp вычисляет multi-scalar multiplication: sum(scalar[i] * point[i]).
+// Constant time: в доказательстве скаляры секретные (маски, суммы). Получатель —
+// identity: с нулевым Point MultiScalarMult edwards25519 v1.1.0 считает неверно.
 func multiexp(data []MultiexpData) Key {
-	// Результат - сумма всех scalar[i] * point[i]
-	result := edwards25519.NewIdentityPoint()
-
-	for _, d := range data {
-		scalar := d.Scalar.KeyToScalar()
-		term := new(edwards25519.Point).ScalarMult(scalar, d.Point)
-		result.Add(result, term)
+	if len(data) == 0 {
+		return Identity
 	}
+	scalars, points := multiexpArgs(data)
+	result := edwards25519.NewIdentityPoint().MultiScalarMult(scalars, points)
+	return Key(result.Bytes())
+}
 
+// multiexpVarTime: то же за переменное время, только для проверки, где все
+// скаляры публичные — как straus/pippenger в Monero
+func multiexpVarTime(data []MultiexpData) Key {
+	if len(data) == 0 {
+		return Identity
+	}
+	scalars, points := multiexpArgs(data)
+	result := edwards25519.NewIdentityPoint().VarTimeMultiScalarMult(scalars, points)
 	return Key(result.Bytes())
 }
 
+func multiexpArgs(data []MultiexpData) ([]*edwards25519.Scalar, []*edwards25519.Point) {
+	scalars := make([]*edwards25519.Scalar, len(data))
+	points := make([]*edwards25519.Point, len(data))
+	for i, d := range data {
+		scalars[i] = d.Scalar.KeyToScalar()
+		points[i] = d.Point
+	}
+	return scalars, points
+}
+
 // hadamardFold складывает вектор точек пополам используя линейную комбинацию
 // v[i] = a*v[i] + b*v[i+sz] для i < sz, затем уменьшает размер вектора вдвое
 func hadamardFold(v *[]edwards25519.Point, a, b Key) {
@@ -596,6 +625,19 @@ func initExponents(mN, mM int) *Exponent {
 	return &exp
 }
 
+// cachedExponents returns the Gi/Hi generators, computed once per process.
+// The result is a shallow copy so the caller owns its Transcript; the point
+// slices are shared and must be treated as read-only.
+func cachedExponents() *Exponent {
+	exponentsOnce.Do(func() {
+		exponentsCache = initExponents(maxN, maxM)
+	})
+
+	exp := *exponentsCache
+	exp.Transcript = INITIAL_TRANSCRIPT
+	return &exp
+}
+
 // get_exponent генерирует точки для bulletproofs
 func getExponent(base *edwards25519.Point, idx int) *edwards25519.Point {
 	// Hash base point и индекс
@@ -627,7 +669,7 @@ func (t *Transaction) calculatePseudoOuts() ([]Hash, error) {
 		randomMask := RandomScalar()
 		t.InputScalars = append(t.InputScalars, randomMask.KeyToScalar())
 		sumpouts.Add(sumpouts, randomMask.KeyToScalar())
-		amountAtomic := uint64(t.PInputs[i]["amount"].(float64) * 1e12)
+		amountAtomic := uint64(t.PInputs[i].Output.Amount)
 		pseudoOut, err := CalcCommitment(amountAtomic, randomMask.ToBytes())
 		if err != nil {
 			return []Hash{}, fmt.Errorf("Error of calc commitment: %w", err)
@@ -637,7 +679,7 @@ func (t *Transaction) calculatePseudoOuts() ([]Hash, error) {
 	}
 
 	lastI := len(pseudoOuts) - 1
-	amountAtomic := uint64(t.PInputs[lastI]["amount"].(float64) * 1e12)
+	amountAtomic := uint64(t.PInputs[lastI].Output.Amount)
 
 	sumouts, err := CalcScalars(t.BlindScalars)
 	if err != nil {
@@ -660,8 +702,9 @@ func (t *Transaction) calculatePseudoOuts() ([]Hash, error) {
 // randomScalar генерирует криптографически стойкий случайный скаляр
 func randomScalar() *edwards25519.Scalar {
 	var buf [64]byte
-	binary.LittleEndian.PutUint64(buf[:8], 1) //
-	// rand.Read(buf[:])
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err) // crypto/rand не отказывает на поддерживаемых платформах
+	}
 	scalar := new(edwards25519.Scalar)
 	scalar.SetUniformBytes(buf[:])
 	return scalar
@@ -0,0 +1,426 @@
+package levin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// BppStatement is a Bulletproof+ together with the commitments it proves.
+// V holds the commitments the prover hashed into the transcript, i.e. each
+// output commitment multiplied by INV_EIGHT (see VerifyRangeProofs).
+type BppStatement struct {
+	Proof Bpp
+	V     []Hash
+}
+
+// 2^64 - 1, the sum of one bit-window of the d vector
+var twoSixtyFourMinusOne = Key{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// bppProofData holds the transcript challenges of a single proof
+type bppProofData struct {
+	y, z, e    Key
+	challenges []Key
+	logM       int
+	invOffset  int
+}
+
+// VerifyBulletproofPlus checks a batch of Bulletproof+ range proofs with a
+// single multi-exponentiation (port of bulletproof_plus_VERIFY from Monero).
+// Each proof is weighted by a random scalar, so one bad proof fails the batch.
+func VerifyBulletproofPlus(statements []BppStatement) error {
+	if len(statements) == 0 {
+		return nil
+	}
+
+	const (
+		logN = 6
+		N    = 1 << logN
+	)
+
+	exponent := cachedExponents()
+
+	maxLength := 0
+	maxLogM := 0
+	nV := 0
+	proofData := make([]bppProofData, len(statements))
+	toInvert := make([]Key, 0, len(statements)*(logN+5))
+
+	for k, st := range statements {
+		proof := &st.Proof
+		pd := &proofData[k]
+
+		for _, sc := range []Hash{proof.R1, proof.S1, proof.D1} {
+			if !ScValid((*Key)(&sc)) {
+				return fmt.Errorf("bpp %d: input scalars not in range", k)
+			}
+		}
+		if len(proof.L) != len(proof.R) {
+			return fmt.Errorf("bpp %d: mismatched L and R sizes", k)
+		}
+		if len(st.V) == 0 {
+			return fmt.Errorf("bpp %d: V does not have at least one element", k)
+		}
+		if len(proof.L) == 0 {
+			return fmt.Errorf("bpp %d: empty proof", k)
+		}
+
+		maxLength = max(maxLength, len(proof.L))
+		nV += len(st.V)
+
+		var vbuf bytes.Buffer
+		for _, v := range st.V {
+			vbuf.Write(v[:])
+		}
+		transcript := INITIAL_TRANSCRIPT
+		TranscriptUpdate(&transcript, HashToScalar(vbuf.Bytes()).ToBytes2())
+
+		pd.y = TranscriptUpdate(&transcript, proof.A[:])
+		if ScIsZero(&pd.y) {
+			return fmt.Errorf("bpp %d: y == 0", k)
+		}
+		pd.z = *HashToScalar(pd.y[:])
+		transcript = pd.z
+		if ScIsZero(&pd.z) {
+			return fmt.Errorf("bpp %d: z == 0", k)
+		}
+
+		for pd.logM = 0; (1<<pd.logM) <= maxM && (1<<pd.logM) < len(st.V); pd.logM++ {
+		}
+		if (1 << pd.logM) > maxM {
+			return fmt.Errorf("bpp %d: too many commitments: %d", k, len(st.V))
+		}
+		if len(proof.L) != logN+pd.logM {
+			return fmt.Errorf("bpp %d: proof is not the expected size", k)
+		}
+		maxLogM = max(maxLogM, pd.logM)
+
+		rounds := pd.logM + logN
+		pd.challenges = make([]Key, rounds)
+		for j := 0; j < rounds; j++ {
+			pd.challenges[j] = TranscriptUpdate(&transcript, append(proof.L[j][:], proof.R[j][:]...))
+			if ScIsZero(&pd.challenges[j]) {
+				return fmt.Errorf("bpp %d: challenges[%d] == 0", k, j)
+			}
+		}
+
+		pd.e = TranscriptUpdate(&transcript, append(proof.A1[:], proof.B[:]...))
+		if ScIsZero(&pd.e) {
+			return fmt.Errorf("bpp %d: e == 0", k)
+		}
+
+		pd.invOffset = len(toInvert)
+		toInvert = append(toInvert, pd.challenges...)
+		toInvert = append(toInvert, pd.y)
+	}
+
+	if maxLength >= 32 {
+		return errors.New("bpp: at least one proof is too large")
+	}
+	maxMN := 1 << maxLength
+
+	inverses := invertKeys(toInvert)
+
+	multiexpData := make([]MultiexpData, 2*maxMN, 2*maxMN+nV+(2*(maxLogM+logN)+3)*len(statements)+2)
+
+	var (
+		GScalar   = Zero
+		HScalar   = Zero
+		GiScalars = make([]Key, maxMN)
+		HiScalars = make([]Key, maxMN)
+		temp      Key
+		temp2     Key
+	)
+
+	for k, st := range statements {
+		proof := &st.Proof
+		pd := &proofData[k]
+
+		rounds := pd.logM + logN
+		M := 1 << pd.logM
+		MN := M * N
+
+		// Random weighting factor must be nonzero
+		weight := Zero
+		for ScIsZero(&weight) {
+			weight = *RandomScalar()
+		}
+
+		// Rescale previously offset proof elements
+		proof8V := make([]*edwards25519.Point, len(st.V))
+		for i, v := range st.V {
+			p, err := scalarmult8(v)
+			if err != nil {
+				return fmt.Errorf("bpp %d: V[%d]: %w", k, i, err)
+			}
+			proof8V[i] = p
+		}
+		proof8L := make([]*edwards25519.Point, rounds)
+		proof8R := make([]*edwards25519.Point, rounds)
+		for j := 0; j < rounds; j++ {
+			var err error
+			if proof8L[j], err = scalarmult8(proof.L[j]); err != nil {
+				return fmt.Errorf("bpp %d: L[%d]: %w", k, j, err)
+			}
+			if proof8R[j], err = scalarmult8(proof.R[j]); err != nil {
+				return fmt.Errorf("bpp %d: R[%d]: %w", k, j, err)
+			}
+		}
+		proof8A1, err := scalarmult8(proof.A1)
+		if err != nil {
+			return fmt.Errorf("bpp %d: A1: %w", k, err)
+		}
+		proof8B, err := scalarmult8(proof.B)
+		if err != nil {
+			return fmt.Errorf("bpp %d: B: %w", k, err)
+		}
+		proof8A, err := scalarmult8(proof.A)
+		if err != nil {
+			return fmt.Errorf("bpp %d: A: %w", k, err)
+		}
+
+		// Compute necessary powers of the y-challenge
+		yMN := pd.y
+		for tempMN := MN; tempMN > 1; tempMN /= 2 {
+			ScMul(&yMN, yMN, yMN)
+		}
+		var yMN1 Key
+		ScMul(&yMN1, yMN, pd.y)
+
+		var eSquared, zSquared Key
+		ScMul(&eSquared, pd.e, pd.e)
+		ScMul(&zSquared, pd.z, pd.z)
+
+		// V_j: -e**2 * z**(2*j+1) * y**(MN+1) * weight
+		ScSub(&temp, &Zero, &eSquared)
+		ScMul(&temp, temp, yMN1)
+		ScMul(&temp, temp, weight)
+		for j := range proof8V {
+			ScMul(&temp, temp, zSquared)
+			multiexpData = append(multiexpData, MultiexpData{Scalar: temp, Point: proof8V[j]})
+		}
+
+		// B: -weight
+		ScMul(&temp, MINUS_ONE, weight)
+		multiexpData = append(multiexpData, MultiexpData{Scalar: temp, Point: proof8B})
+
+		// A1: -weight*e
+		ScMul(&temp, temp, pd.e)
+		multiexpData = append(multiexpData, MultiexpData{Scalar: temp, Point: proof8A1})
+
+		// A: -weight*e*e
+		var minusWeightESquared Key
+		ScMul(&minusWeightESquared, temp, pd.e)
+		multiexpData = append(multiexpData, MultiexpData{Scalar: minusWeightESquared, Point: proof8A})
+
+		// G: weight*d1
+		ScMulAdd(&GScalar, &weight, (*Key)(&proof.D1), &GScalar)
+
+		// Windowed vector d[j*N+i] = z**(2*(j+1)) * 2**i
+		d := createWindowedVector(zSquared, N, M)
+
+		// sum(d) = (2**64 - 1) * (z**2 + z**4 + ... + z**(2*M))
+		sumD := Zero
+		zPow := ONE
+		for j := 0; j < M; j++ {
+			ScMul(&zPow, zPow, zSquared)
+			ScAdd(&sumD, &sumD, &zPow)
+		}
+		ScMul(&sumD, sumD, twoSixtyFourMinusOne)
+
+		// sum(y) = y + y**2 + ... + y**MN
+		sumY := Zero
+		yPow := ONE
+		for i := 0; i < MN; i++ {
+			ScMul(&yPow, yPow, pd.y)
+			ScAdd(&sumY, &sumY, &yPow)
+		}
+
+		// H: weight*( r1*y*s1 + e**2*( y**(MN+1)*z*sum(d) + (z**2-z)*sum(y) ) )
+		ScSub(&temp, &zSquared, &pd.z)
+		ScMul(&temp, temp, sumY)
+
+		ScMul(&temp2, yMN1, pd.z)
+		ScMul(&temp2, temp2, sumD)
+		ScAdd(&temp, &temp, &temp2)
+		ScMul(&temp, temp, eSquared)
+		ScMul(&temp2, Key(proof.R1), pd.y)
+		ScMul(&temp2, temp2, Key(proof.S1))
+		ScAdd(&temp, &temp, &temp2)
+		ScMulAdd(&HScalar, &temp, &weight, &HScalar)
+
+		challengesInv := inverses[pd.invOffset : pd.invOffset+rounds]
+		yinv := inverses[pd.invOffset+rounds]
+
+		// Compute challenge products
+		challengesCache := make([]Key, 1<<rounds)
+		challengesCache[0] = challengesInv[0]
+		challengesCache[1] = pd.challenges[0]
+		for j := 1; j < rounds; j++ {
+			slots := 1 << (j + 1)
+			for s := slots - 1; s > 0; s -= 2 {
+				ScMul(&challengesCache[s], challengesCache[s/2], pd.challenges[j])
+				ScMul(&challengesCache[s-1], challengesCache[s/2], challengesInv[j])
+			}
+		}
+
+		// Gi and Hi
+		var eR1WY, eS1W, eSquaredZW, minusESquaredZW, minusESquaredWY Key
+		ScMul(&eR1WY, pd.e, Key(proof.R1))
+		ScMul(&eR1WY, eR1WY, weight)
+		ScMul(&eS1W, pd.e, Key(proof.S1))
+		ScMul(&eS1W, eS1W, weight)
+		ScMul(&eSquaredZW, eSquared, pd.z)
+		ScMul(&eSquaredZW, eSquaredZW, weight)
+		ScSub(&minusESquaredZW, &Zero, &eSquaredZW)
+		ScSub(&minusESquaredWY, &Zero, &eSquared)
+		ScMul(&minusESquaredWY, minusESquaredWY, weight)
+		ScMul(&minusESquaredWY, minusESquaredWY, yMN)
+
+		for i := 0; i < MN; i++ {
+			var gScalar, hScalar Key
+
+			// Use the binary decomposition of the index
+			ScMulAdd(&gScalar, &eR1WY, &challengesCache[i], &eSquaredZW)
+			ScMulAdd(&hScalar, &eS1W, &challengesCache[(^i)&(MN-1)], &minusESquaredZW)
+
+			// Complete the scalar derivation
+			ScAdd(&GiScalars[i], &GiScalars[i], &gScalar)
+			ScMulAdd(&hScalar, &minusESquaredWY, &d[i], &hScalar)
+			ScAdd(&HiScalars[i], &HiScalars[i], &hScalar)
+
+			// Update iterated values
+			ScMul(&eR1WY, eR1WY, yinv)
+			ScMul(&minusESquaredWY, minusESquaredWY, yinv)
+		}
+
+		// L_j: -weight*e*e*challenges[j]**2
+		// R_j: -weight*e*e*challenges[j]**(-2)
+		for j := 0; j < rounds; j++ {
+			ScMul(&temp, pd.challenges[j], pd.challenges[j])
+			ScMul(&temp, temp, minusWeightESquared)
+			multiexpData = append(multiexpData, MultiexpData{Scalar: temp, Point: proof8L[j]})
+
+			ScMul(&temp, challengesInv[j], challengesInv[j])
+			ScMul(&temp, temp, minusWeightESquared)
+			multiexpData = append(multiexpData, MultiexpData{Scalar: temp, Point: proof8R[j]})
+		}
+	}
+
+	// Verify all proofs in the weighted batch
+	multiexpData = append(multiexpData,
+		MultiexpData{Scalar: GScalar, Point: edwards25519.NewGeneratorPoint()},
+		MultiexpData{Scalar: HScalar, Point: getH()},
+	)
+	for i := 0; i < maxMN; i++ {
+		multiexpData[i*2] = MultiexpData{Scalar: GiScalars[i], Point: exponent.Gi_p3[i]}
+		multiexpData[i*2+1] = MultiexpData{Scalar: HiScalars[i], Point: exponent.Hi_p3[i]}
+	}
+
+	if multiexpVarTime(multiexpData) != Identity {
+		return errors.New("bpp: verification failed")
+	}
+	return nil
+}
+
+// VerifyRangeProofs checks the Bulletproof+ proofs of a parsed RCTTypeBulletproofPlus transaction
+func (tx *Transaction) VerifyRangeProofs() error {
+	statements, err := tx.bppStatements()
+	if err != nil {
+		return err
+	}
+	return VerifyBulletproofPlus(statements)
+}
+
+// VerifyRangeProofs checks the range proofs of every transaction in the block in one batch
+func (b *Block) VerifyRangeProofs() error {
+	var statements []BppStatement
+	for _, tx := range b.TXs {
+		st, err := tx.bppStatements()
+		if err != nil {
+			return fmt.Errorf("tx %x: %w", tx.Hash, err)
+		}
+		statements = append(statements, st...)
+	}
+	return VerifyBulletproofPlus(statements)
+}
+
+// bppStatements pairs the proofs of the transaction with V = outPk * INV_EIGHT.
+// Outputs are assigned to proofs in order, each proof covering up to 2^(len(L)-6) of them.
+func (tx *Transaction) bppStatements() ([]BppStatement, error) {
+	if tx.Pruned {
+		return nil, errors.New("transaction is pruned: range proofs are not available")
+	}
+	if tx.RctSignature == nil || tx.RctSigPrunable == nil {
+		return nil, errors.New("rct signature is not parsed")
+	}
+	if tx.RctSignature.Type != uint64(RCTTypeBulletproofPlus) {
+		return nil, fmt.Errorf("unsupported rct type for bulletproof+: %d", tx.RctSignature.Type)
+	}
+	if len(tx.RctSigPrunable.Bpp) == 0 {
+		return nil, errors.New("no bulletproof+ in transaction")
+	}
+
+	outPk := tx.RctSignature.OutPk
+	statements := make([]BppStatement, 0, len(tx.RctSigPrunable.Bpp))
+	next := 0
+	for i, proof := range tx.RctSigPrunable.Bpp {
+		if len(proof.L) < 6 {
+			return nil, fmt.Errorf("bpp %d: proof is too short", i)
+		}
+		count := min(1<<(len(proof.L)-6), len(outPk)-next)
+		if count <= 0 {
+			return nil, fmt.Errorf("bpp %d: no outputs left to prove", i)
+		}
+
+		V := make([]Hash, count)
+		for j := 0; j < count; j++ {
+			pk := Key(outPk[next+j])
+			V[j] = Hash(ScalarMult(&INV_EIGHT, &pk))
+		}
+		next += count
+
+		statements = append(statements, BppStatement{Proof: proof, V: V})
+	}
+	if next != len(outPk) {
+		return nil, fmt.Errorf("range proofs cover %d of %d outputs", next, len(outPk))
+	}
+
+	return statements, nil
+}
+
+// scalarmult8 decodes a point and multiplies it by the cofactor
+func scalarmult8(h Hash) (*edwards25519.Point, error) {
+	p, err := new(edwards25519.Point).SetBytes(h[:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid point %x: %w", h, err)
+	}
+	return p.MultByCofactor(p), nil
+}
+
+// invertKeys inverts a vector of nonzero scalars with a single field inversion (Montgomery's trick)
+func invertKeys(keys []Key) []Key {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	scratch := make([]*edwards25519.Scalar, len(keys))
+	acc := edwards25519.NewScalar()
+	acc.Set(ONE.KeyToScalar())
+	for i := range keys {
+		scratch[i] = new(edwards25519.Scalar).Set(acc)
+		acc.Multiply(acc, keys[i].KeyToScalar())
+	}
+
+	acc.Invert(acc)
+
+	result := make([]Key, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		inv := new(edwards25519.Scalar).Multiply(acc, scratch[i])
+		result[i].FromScalar(inv)
+		acc.Multiply(acc, keys[i].KeyToScalar())
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package levin
+
+import (
+	"fmt"
+	"testing"
+
+	"filippo.io/edwards25519"
+)
+
+func TestVerifyRangeProofsDumps(t *testing.T) {
+	txs := testTxs(t)
+	for _, tx := range txs {
+		t.Run(fmt.Sprintf("%x", tx.Hash), func(t *testing.T) {
+			if err := tx.VerifyRangeProofs(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	t.Logf("%d txs", len(txs))
+}
+
+func TestVerifyRangeProofsBlockBatch(t *testing.T) {
+	for _, b := range loadTestBlocks(t) {
+		if err := b.VerifyRangeProofs(); err != nil {
+			t.Fatalf("block %d: %v", b.BlockHeight, err)
+		}
+	}
+}
+
+func TestVerifyBulletproofPlusTampered(t *testing.T) {
+	tx := testTxs(t)[0]
+	statements, err := tx.bppStatements()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tamper := []struct {
+		name string
+		edit func(st *BppStatement)
+	}{
+		{"r1", func(st *BppStatement) { st.Proof.R1[0] ^= 1 }},
+		{"d1", func(st *BppStatement) { st.Proof.D1[0] ^= 1 }},
+		{"L", func(st *BppStatement) { st.Proof.L = append([]Hash(nil), st.Proof.L...); st.Proof.L[0] = st.Proof.R[0] }},
+		{"V", func(st *BppStatement) { st.V = append([]Hash(nil), st.V...); st.V[0] = st.Proof.A }},
+	}
+	for _, tc := range tamper {
+		t.Run(tc.name, func(t *testing.T) {
+			bad := append([]BppStatement(nil), statements...)
+			tc.edit(&bad[0])
+			if err := VerifyBulletproofPlus(bad); err == nil {
+				t.Fatal("tampered proof verified")
+			}
+		})
+	}
+}
+
+// доказательство строится constant time multiexp, проверяется variable time
+func TestMultiexpVarTimeMatches(t *testing.T) {
+	data := make([]MultiexpData, 70)
+	for i := range data {
+		data[i].Scalar = *RandomScalar()
+		data[i].Point = new(edwards25519.Point).ScalarBaseMult(RandomScalar().KeyToScalar())
+	}
+	if multiexp(data) != multiexpVarTime(data) {
+		t.Fatal("constant and variable time multiexp differ")
+	}
+	if multiexp(nil) != Identity || multiexpVarTime(nil) != Identity {
+		t.Fatal("empty multiexp is not identity")
+	}
+}
+
+func TestCreateBulletproofPlusVerifies(t *testing.T) {
+	a, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 2, 3} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			var destinations []Destination
+			for i := range n {
+				destinations = append(destinations, Destination{Address: a.Address, Amount: Amount(i+1) * XMR})
+			}
+			tx := outputsTx(t, destinations...)
+			proof, err := tx.signBpp()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx.RctSigPrunable.Bpp[0] = proof
+			if err := tx.VerifyRangeProofs(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
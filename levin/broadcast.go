@@ -0,0 +1,671 @@
+package levin
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Отправка подписанной транзакции: NOTIFY_NEW_TRANSACTIONS пирам и/или
+// send_raw_transaction демона, дальше подтверждение по пулу и блокам.
+
+const (
+	defaultBroadcastRetry    = 2 * time.Minute
+	defaultBroadcastAttempts = 5 // всего за жизнь транзакции, не на каждый выход из пула
+	defaultPeerRelayTimeout  = 20 * time.Second
+
+	// блок с транзакцией может уйти при reorg, до этой глубины она отслеживается
+	defaultBroadcastConfirmations = CryptonoteDefaultTxSpendableAge
+)
+
+// ErrDoubleSpend: key image транзакции уже потрачен другой транзакцией
+var ErrDoubleSpend = errors.New("double spend")
+
+// TxRelay отправляет blob транзакции в сеть. fluff == false — stem фаза
+// Dandelion++: получатель передаёт tx одному своему соседу, а не всем.
+type TxRelay interface {
+	Relay(blob []byte, fluff bool) error
+}
+
+// TxStatusSource: где сейчас транзакции по мнению демона
+type TxStatusSource interface {
+	TxStatus(hashes []Hash) ([]TxStatus, error)
+}
+
+type TxStatus struct {
+	Hash        Hash
+	Known       bool // false — демон о транзакции не знает
+	InPool      bool
+	BlockHeight uint64
+}
+
+// NewTransactionsPayload: тело NOTIFY_NEW_TRANSACTIONS
+func NewTransactionsPayload(blobs [][]byte, fluff bool) []byte {
+	return (&PortableStorage{
+		Entries: []Entry{
+			{
+				Name:         "txs",
+				Serializable: BoostBlobs(blobs),
+			},
+			{
+				Name:         "dandelionpp_fluff",
+				Serializable: BoostBool(fluff),
+			},
+		},
+	}).Bytes()
+}
+
+/*--- PeerRelay ---*/
+
+// PeerRelay рассылает транзакцию напрямую пирам: handshake, NOTIFY_NEW_TRANSACTIONS,
+// разрыв. В stem режиме tx уходит одному случайному пиру, в fluff — Fanout пирам.
+type PeerRelay struct {
+	Peers   []string
+	Fanout  int // 0 — всем пирам
+	Height  uint64
+	TopHash string // hex, для handshake
+	PeerID  uint64
+	Timeout time.Duration // на пира целиком, 0 — 20 секунд
+	Dialer  ContextDialer // nil — net.Dialer с DialTimeout
+}
+
+func (r *PeerRelay) Relay(blob []byte, fluff bool) error {
+	if len(r.Peers) == 0 {
+		return fmt.Errorf("peer relay: no peers")
+	}
+	want := 1
+	if fluff {
+		want = len(r.Peers)
+		if r.Fanout > 0 {
+			want = min(r.Fanout, want)
+		}
+	}
+
+	payload := NewTransactionsPayload([][]byte{blob}, fluff)
+	var (
+		sent int
+		errs []error
+	)
+	// недоступный пир заменяется следующим из перемешанного списка
+	for _, i := range rand.Perm(len(r.Peers)) {
+		if sent == want {
+			break
+		}
+		if err := r.send(r.Peers[i], payload); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Peers[i], err))
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		return fmt.Errorf("peer relay: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (r *PeerRelay) send(addr string, payload []byte) error {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = defaultPeerRelayTimeout
+	}
+	dialer := r.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: DialTimeout}
+	}
+
+	c, err := NewClient(addr, WithContextDialer(dialer))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := c.Handshake(r.Height, r.TopHash, r.PeerID); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	return c.SendRequest(NotifyNewTransaction, payload)
+}
+
+/*--- DaemonRelay ---*/
+
+// DaemonRelay: send_raw_transaction, демон сам выбирает stem или fluff
+type DaemonRelay struct {
+	RPC *DaemonRPC
+}
+
+func (r DaemonRelay) Relay(blob []byte, fluff bool) error {
+	var resp struct {
+		Status            string `json:"status"`
+		Reason            string `json:"reason"`
+		DoubleSpend       bool   `json:"double_spend"`
+		FeeTooLow         bool   `json:"fee_too_low"`
+		InvalidInput      bool   `json:"invalid_input"`
+		InvalidOutput     bool   `json:"invalid_output"`
+		LowMixin          bool   `json:"low_mixin"`
+		Overspend         bool   `json:"overspend"`
+		TooBig            bool   `json:"too_big"`
+		TooFewOutputs     bool   `json:"too_few_outputs"`
+		SanityCheckFailed bool   `json:"sanity_check_failed"`
+		TxExtraTooBig     bool   `json:"tx_extra_too_big"`
+		NonzeroUnlockTime bool   `json:"nonzero_unlock_time"`
+	}
+	req := map[string]any{
+		"tx_as_hex":    hex.EncodeToString(blob),
+		"do_not_relay": false,
+	}
+	if err := r.RPC.Call("/send_raw_transaction", req, &resp); err != nil {
+		return err
+	}
+	if resp.DoubleSpend {
+		return fmt.Errorf("send_raw_transaction: %w", ErrDoubleSpend)
+	}
+	if resp.Status == "OK" {
+		return nil
+	}
+
+	var flags []string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{resp.FeeTooLow, "fee too low"},
+		{resp.InvalidInput, "invalid input"},
+		{resp.InvalidOutput, "invalid output"},
+		{resp.LowMixin, "low mixin"},
+		{resp.Overspend, "overspend"},
+		{resp.TooBig, "too big"},
+		{resp.TooFewOutputs, "too few outputs"},
+		{resp.SanityCheckFailed, "sanity check failed"},
+		{resp.TxExtraTooBig, "tx extra too big"},
+		{resp.NonzeroUnlockTime, "nonzero unlock time"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return fmt.Errorf("send_raw_transaction: %s %s [%s]", resp.Status, resp.Reason, strings.Join(flags, ", "))
+}
+
+// TxStatus: /get_transactions без тел транзакций
+func (r DaemonRelay) TxStatus(hashes []Hash) ([]TxStatus, error) {
+	req := struct {
+		TxsHashes []string `json:"txs_hashes"`
+		Prune     bool     `json:"prune"`
+	}{Prune: true}
+	for _, h := range hashes {
+		req.TxsHashes = append(req.TxsHashes, hex.EncodeToString(h[:]))
+	}
+	var resp struct {
+		Status string `json:"status"`
+		Txs    []struct {
+			TxHash      string `json:"tx_hash"`
+			InPool      bool   `json:"in_pool"`
+			BlockHeight uint64 `json:"block_height"`
+		} `json:"txs"`
+	}
+	if err := r.RPC.Call("/get_transactions", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "OK" {
+		return nil, fmt.Errorf("get_transactions: %s", resp.Status)
+	}
+
+	found := make(map[Hash]TxStatus, len(resp.Txs))
+	for _, tx := range resp.Txs {
+		key, err := ParseKeyFromHex(tx.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("get_transactions: tx hash %q: %w", tx.TxHash, err)
+		}
+		found[Hash(key)] = TxStatus{Hash: Hash(key), Known: true, InPool: tx.InPool, BlockHeight: tx.BlockHeight}
+	}
+	result := make([]TxStatus, len(hashes))
+	for i, h := range hashes {
+		result[i] = found[h]
+		result[i].Hash = h
+	}
+	return result, nil
+}
+
+/*--- Broadcaster ---*/
+
+type BroadcastState int
+
+const (
+	BroadcastPending     BroadcastState = iota // отправлена, в пуле ещё не видна
+	BroadcastInPool                            // в пуле
+	BroadcastConfirmed                         // в блоке, подтверждений меньше нужного
+	BroadcastDoubleSpent                       // key image потрачен другой транзакцией
+	BroadcastFailed                            // попытки кончились
+	BroadcastFinalized                         // блок (наш или с конфликтом) достаточно глубоко
+)
+
+func (s BroadcastState) String() string {
+	switch s {
+	case BroadcastPending:
+		return "pending"
+	case BroadcastInPool:
+		return "in_pool"
+	case BroadcastConfirmed:
+		return "confirmed"
+	case BroadcastDoubleSpent:
+		return "double_spent"
+	case BroadcastFailed:
+		return "failed"
+	case BroadcastFinalized:
+		return "finalized"
+	}
+	return fmt.Sprintf("BroadcastState(%d)", int(s))
+}
+
+func (s BroadcastState) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+type BroadcastTx struct {
+	Hash        Hash           `json:"hash"`
+	Blob        []byte         `json:"-"`
+	KeyImages   []Hash         `json:"key_images"`
+	State       BroadcastState `json:"state"`
+	Attempts    int            `json:"attempts"`
+	LastAttempt time.Time      `json:"last_attempt"`
+	BlockHeight uint64         `json:"block_height,omitempty"`
+	BlockHash   string         `json:"block_hash,omitempty"`  // hex id блока, по нему виден reorg
+	ConflictTx  *Hash          `json:"conflict_tx,omitempty"` // кто потратил наш key image
+	Err         string         `json:"error,omitempty"`       // последняя ошибка relay
+}
+
+// mined: транзакция или конфликт в блоке, блок ещё может уйти
+func (bt *BroadcastTx) mined() bool {
+	return bt.BlockHeight > 0 && (bt.State == BroadcastConfirmed || bt.State == BroadcastDoubleSpent)
+}
+
+// final: после него транзакция больше не отслеживается. Double spend из пула
+// или от relay финальный сразу, из блока — как и подтверждение, после глубины.
+func (bt *BroadcastTx) final() bool {
+	switch bt.State {
+	case BroadcastFailed, BroadcastFinalized:
+		return true
+	case BroadcastDoubleSpent:
+		return bt.BlockHeight == 0
+	}
+	return false
+}
+
+type BroadcastUpdate struct {
+	Tx   BroadcastTx    `json:"tx"`
+	From BroadcastState `json:"from"`
+	To   BroadcastState `json:"to"`
+}
+
+// Broadcaster отправляет транзакции через все relay и ведёт их до блока и дальше,
+// пока блок не наберёт confirmations: ушедший при reorg блок (другой блок на той
+// же высоте или Rollback) возвращает транзакцию в pending. Pending транзакции
+// переотправляются (уже fluff) раз в retry интервал, выпавшие из пула снова
+// становятся pending; attempts — на всю жизнь транзакции. Финальное состояние
+// сообщается один раз, после него транзакция забывается.
+type Broadcaster struct {
+	mu sync.Mutex
+
+	relays      []TxRelay
+	status      TxStatusSource
+	stem        bool
+	interval    time.Duration
+	maxAttempts int
+	confirms    uint64
+	tip         uint64 // последний блок из ObserveBlock
+
+	txs       map[Hash]*BroadcastTx
+	keyImages map[Hash]Hash // key image -> наша транзакция
+}
+
+func NewBroadcaster(relays ...TxRelay) *Broadcaster {
+	return &Broadcaster{
+		relays:      relays,
+		interval:    defaultBroadcastRetry,
+		maxAttempts: defaultBroadcastAttempts,
+		confirms:    defaultBroadcastConfirmations,
+		txs:         make(map[Hash]*BroadcastTx),
+		keyImages:   make(map[Hash]Hash),
+	}
+}
+
+// SetStem: первая отправка в stem фазе Dandelion++, повторы — fluff
+func (b *Broadcaster) SetStem(stem bool) *Broadcaster {
+	b.stem = stem
+	return b
+}
+
+// SetStatusSource: опрос демона в Retry, без него состояние берётся только из
+// ObservePool и ObserveBlock
+func (b *Broadcaster) SetStatusSource(src TxStatusSource) *Broadcaster {
+	b.status = src
+	return b
+}
+
+// SetRetry: attempts — всего отправок транзакции, включая первую
+func (b *Broadcaster) SetRetry(interval time.Duration, attempts int) *Broadcaster {
+	b.interval = interval
+	b.maxAttempts = attempts
+	return b
+}
+
+// SetConfirmations: сколько подтверждений ждать до finalized. Высота вершины
+// берётся из ObserveBlock, без него подтверждённые транзакции не забываются.
+func (b *Broadcaster) SetConfirmations(n uint64) *Broadcaster {
+	b.confirms = max(n, 1)
+	return b
+}
+
+// Broadcast отправляет подписанную транзакцию. Если ни один relay её не принял,
+// ошибка возвращается, но транзакция остаётся pending и уйдёт снова в Retry;
+// отказ из-за double spend сразу финальный.
+func (b *Broadcaster) Broadcast(tx *Transaction) (Hash, error) {
+	if len(b.relays) == 0 {
+		return Hash{}, fmt.Errorf("broadcast: no relays")
+	}
+	tx.CalcHash()
+	bt := &BroadcastTx{
+		Hash: tx.Hash,
+		Blob: tx.Serialize(),
+	}
+	for _, in := range tx.Inputs {
+		bt.KeyImages = append(bt.KeyImages, in.KeyImage)
+	}
+
+	b.mu.Lock()
+	if _, ok := b.txs[bt.Hash]; ok {
+		b.mu.Unlock()
+		return bt.Hash, fmt.Errorf("broadcast: tx %x is already being broadcast", bt.Hash)
+	}
+	for _, ki := range bt.KeyImages {
+		if other, ok := b.keyImages[ki]; ok {
+			b.mu.Unlock()
+			return bt.Hash, fmt.Errorf("broadcast: key image %x is spent by our tx %x: %w", ki, other, ErrDoubleSpend)
+		}
+	}
+	b.track(bt)
+	b.mu.Unlock()
+
+	err := b.send(bt.Hash, bt.Blob, !b.stem)
+	if errors.Is(err, ErrDoubleSpend) {
+		b.mu.Lock()
+		b.forget(bt)
+		b.mu.Unlock()
+	}
+	return bt.Hash, err
+}
+
+// Get: копия состояния, пока транзакция отслеживается
+func (b *Broadcaster) Get(hash Hash) (BroadcastTx, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bt, ok := b.txs[hash]
+	if !ok {
+		return BroadcastTx{}, false
+	}
+	return *bt, true
+}
+
+// Pending: все отслеживаемые транзакции
+func (b *Broadcaster) Pending() []BroadcastTx {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make([]BroadcastTx, 0, len(b.txs))
+	for _, bt := range b.txs {
+		result = append(result, *bt)
+	}
+	return result
+}
+
+// ObservePool: транзакции из NOTIFY_NEW_TRANSACTIONS или пула демона. Чужая
+// транзакция в пуле с нашим key image значит, что нашу узлы уже не примут.
+func (b *Broadcaster) ObservePool(txs ...*Transaction) []BroadcastUpdate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var updates []BroadcastUpdate
+	for _, tx := range txs {
+		if bt, ok := b.txs[tx.Hash]; ok {
+			switch {
+			case bt.State == BroadcastPending:
+				updates = b.transition(updates, bt, BroadcastInPool)
+			case bt.State == BroadcastConfirmed:
+				// блок ушёл, демон вернул транзакцию в пул
+				updates = b.unmine(updates, bt, BroadcastInPool)
+			}
+			continue
+		}
+		if bt := b.conflict(tx); bt != nil && bt.State == BroadcastPending {
+			conflictTx := Hash(tx.Hash)
+			bt.ConflictTx = &conflictTx
+			updates = b.transition(updates, bt, BroadcastDoubleSpent)
+		}
+	}
+	return updates
+}
+
+// ObserveBlock: блок с разобранными транзакциями (ParseTx), как в processblocks.
+// Блоки идут по порядку; другой блок на высоте, где была наша транзакция, — reorg.
+func (b *Broadcaster) ObserveBlock(block *Block) []BroadcastUpdate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.txs) == 0 {
+		b.tip = max(b.tip, block.BlockHeight)
+		return nil
+	}
+	id := block.GetBlockId()
+
+	var updates []BroadcastUpdate
+	for _, bt := range b.txs {
+		if bt.mined() && bt.BlockHeight == block.BlockHeight && bt.BlockHash != "" && bt.BlockHash != id {
+			updates = b.unmine(updates, bt, BroadcastPending)
+			b.tip = block.BlockHeight
+		}
+	}
+	b.tip = max(b.tip, block.BlockHeight)
+
+	for _, tx := range block.TXs {
+		if bt, ok := b.txs[tx.Hash]; ok {
+			if bt.State == BroadcastConfirmed && bt.BlockHeight == block.BlockHeight {
+				bt.BlockHash = id // уже знали от демона
+				continue
+			}
+			bt.BlockHeight, bt.BlockHash = block.BlockHeight, id
+			updates = b.transition(updates, bt, BroadcastConfirmed)
+			continue
+		}
+		if bt := b.conflict(tx); bt != nil && (bt.State != BroadcastDoubleSpent || bt.BlockHeight != block.BlockHeight) {
+			conflictTx := Hash(tx.Hash)
+			bt.ConflictTx = &conflictTx
+			bt.BlockHeight, bt.BlockHash = block.BlockHeight, id
+			updates = b.transition(updates, bt, BroadcastDoubleSpent)
+		}
+	}
+	return b.finalize(updates)
+}
+
+// Rollback: блоки с height и выше ушли из цепочки, их транзакции снова pending
+func (b *Broadcaster) Rollback(height uint64) []BroadcastUpdate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var updates []BroadcastUpdate
+	for _, bt := range b.txs {
+		if bt.mined() && bt.BlockHeight >= height {
+			updates = b.unmine(updates, bt, BroadcastPending)
+		}
+	}
+	if height > 0 {
+		b.tip = min(b.tip, height-1)
+	}
+	return updates
+}
+
+// Retry: опрос TxStatusSource и переотправка pending транзакций, у которых
+// прошёл интервал. Вызывается периодически.
+func (b *Broadcaster) Retry(now time.Time) []BroadcastUpdate {
+	var updates []BroadcastUpdate
+	if b.status != nil {
+		updates = append(updates, b.poll()...)
+	}
+
+	type resend struct {
+		hash Hash
+		blob []byte
+	}
+	var due []resend
+	b.mu.Lock()
+	for _, bt := range b.txs {
+		if bt.State != BroadcastPending || now.Sub(bt.LastAttempt) < b.interval {
+			continue
+		}
+		if bt.Attempts >= b.maxAttempts {
+			updates = b.transition(updates, bt, BroadcastFailed)
+			continue
+		}
+		due = append(due, resend{bt.Hash, bt.Blob})
+	}
+	b.mu.Unlock()
+
+	for _, r := range due {
+		if err := b.send(r.hash, r.blob, true); errors.Is(err, ErrDoubleSpend) {
+			b.mu.Lock()
+			if bt, ok := b.txs[r.hash]; ok {
+				updates = b.transition(updates, bt, BroadcastDoubleSpent)
+			}
+			b.mu.Unlock()
+		}
+	}
+	return updates
+}
+
+func (b *Broadcaster) poll() []BroadcastUpdate {
+	b.mu.Lock()
+	hashes := make([]Hash, 0, len(b.txs))
+	for h := range b.txs {
+		hashes = append(hashes, h)
+	}
+	b.mu.Unlock()
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	statuses, err := b.status.TxStatus(hashes)
+	if err != nil {
+		return nil // демон недоступен — ждём следующего Retry
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var updates []BroadcastUpdate
+	for _, s := range statuses {
+		bt, ok := b.txs[s.Hash]
+		if !ok {
+			continue
+		}
+		switch {
+		case s.Known && !s.InPool:
+			if bt.State != BroadcastConfirmed || bt.BlockHeight != s.BlockHeight {
+				// id блока демон не отдаёт, его запишет ObserveBlock
+				bt.BlockHeight, bt.BlockHash = s.BlockHeight, ""
+				updates = b.transition(updates, bt, BroadcastConfirmed)
+			}
+		case s.InPool && bt.State == BroadcastPending:
+			updates = b.transition(updates, bt, BroadcastInPool)
+		case s.InPool && bt.State == BroadcastConfirmed:
+			updates = b.unmine(updates, bt, BroadcastInPool)
+		case !s.Known && (bt.State == BroadcastInPool || bt.State == BroadcastConfirmed):
+			// выпала из пула или ушла вместе с блоком: переотправим, attempts
+			// не сбрасываются, так что бесконечно это не повторится
+			updates = b.unmine(updates, bt, BroadcastPending)
+		}
+	}
+	return b.finalize(updates)
+}
+
+// send: успех, если принял хотя бы один relay. Вызывается без b.mu.
+func (b *Broadcaster) send(hash Hash, blob []byte, fluff bool) error {
+	var errs []error
+	accepted := false
+	for _, r := range b.relays {
+		if err := r.Relay(blob, fluff); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		accepted = true
+	}
+	err := errors.Join(errs...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bt, ok := b.txs[hash]; ok {
+		bt.Attempts++
+		bt.LastAttempt = time.Now()
+		bt.Err = ""
+		if err != nil {
+			bt.Err = err.Error()
+		}
+	}
+	if accepted {
+		return nil
+	}
+	return fmt.Errorf("broadcast %x: %w", hash, err)
+}
+
+// conflict: наша транзакция, чей key image тратит tx
+func (b *Broadcaster) conflict(tx *Transaction) *BroadcastTx {
+	for _, in := range tx.Inputs {
+		if hash, ok := b.keyImages[in.KeyImage]; ok && hash != tx.Hash {
+			return b.txs[hash]
+		}
+	}
+	return nil
+}
+
+func (b *Broadcaster) transition(updates []BroadcastUpdate, bt *BroadcastTx, to BroadcastState) []BroadcastUpdate {
+	from := bt.State
+	bt.State = to
+	updates = append(updates, BroadcastUpdate{Tx: *bt, From: from, To: to})
+	if bt.final() {
+		b.forget(bt)
+	}
+	return updates
+}
+
+// unmine: блок с транзакцией (или с конфликтом) ушёл
+func (b *Broadcaster) unmine(updates []BroadcastUpdate, bt *BroadcastTx, to BroadcastState) []BroadcastUpdate {
+	bt.BlockHeight, bt.BlockHash, bt.ConflictTx = 0, "", nil
+	return b.transition(updates, bt, to)
+}
+
+// finalize: транзакции в блоках глубже confirms
+func (b *Broadcaster) finalize(updates []BroadcastUpdate) []BroadcastUpdate {
+	for _, bt := range b.txs {
+		if bt.mined() && Confirmations(bt.BlockHeight, b.tip) >= b.confirms {
+			updates = b.transition(updates, bt, BroadcastFinalized)
+		}
+	}
+	return updates
+}
+
+func (b *Broadcaster) track(bt *BroadcastTx) {
+	b.txs[bt.Hash] = bt
+	for _, ki := range bt.KeyImages {
+		b.keyImages[ki] = bt.Hash
+	}
+}
+
+func (b *Broadcaster) forget(bt *BroadcastTx) {
+	delete(b.txs, bt.Hash)
+	for _, ki := range bt.KeyImages {
+		if b.keyImages[ki] == bt.Hash {
+			delete(b.keyImages, ki)
+		}
+	}
+}
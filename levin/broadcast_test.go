@@ -0,0 +1,180 @@
+package levin
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type countRelay struct{ sent int }
+
+func (r *countRelay) Relay(blob []byte, fluff bool) error {
+	r.sent++
+	return nil
+}
+
+// poolStatus: демон, у которого транзакция то в пуле, то нет
+type poolStatus struct{ inPool bool }
+
+func (s *poolStatus) TxStatus(hashes []Hash) ([]TxStatus, error) {
+	result := make([]TxStatus, len(hashes))
+	for i, h := range hashes {
+		result[i] = TxStatus{Hash: h, Known: s.inPool, InPool: s.inPool}
+	}
+	return result, nil
+}
+
+// testBlockWithTxs: блок из дампов, в котором есть транзакции
+func testBlockWithTxs(tb testing.TB) *Block {
+	for _, b := range loadTestBlocks(tb) {
+		if len(b.TXs) > 0 {
+			return b
+		}
+	}
+	tb.Skip("no blocks with transactions in dumps")
+	return nil
+}
+
+func nextBlock(prev *Block, txs ...*Transaction) *Block {
+	return &Block{
+		MajorVersion: prev.MajorVersion,
+		MinorVersion: prev.MinorVersion,
+		BlockHeight:  prev.BlockHeight + 1,
+		Timestamp:    prev.Timestamp + DifficultyTarget,
+		TxsCount:     uint64(len(txs)),
+		TXs:          txs,
+	}
+}
+
+func expectState(t *testing.T, b *Broadcaster, hash Hash, want BroadcastState) BroadcastTx {
+	t.Helper()
+	bt, ok := b.Get(hash)
+	if !ok {
+		t.Fatalf("tx %x is not tracked, expected %s", hash, want)
+	}
+	if bt.State != want {
+		t.Fatalf("tx %x: state %s, expected %s", hash, bt.State, want)
+	}
+	return bt
+}
+
+func TestBroadcasterReorg(t *testing.T) {
+	block := testBlockWithTxs(t)
+	tx := block.TXs[0]
+	relay := &countRelay{}
+	b := NewBroadcaster(relay).SetRetry(0, 5).SetConfirmations(3)
+
+	hash, err := b.Broadcast(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ObserveBlock(block)
+	bt := expectState(t, b, hash, BroadcastConfirmed)
+	if bt.BlockHeight != block.BlockHeight || bt.BlockHash != block.GetBlockId() {
+		t.Fatalf("confirmed in %d %s", bt.BlockHeight, bt.BlockHash)
+	}
+
+	// на той же высоте другой блок без нашей транзакции
+	replaced := *block
+	replaced.Nonce++
+	replaced.TXs, replaced.TxsCount = nil, 0
+	updates := b.ObserveBlock(&replaced)
+	if len(updates) != 1 || updates[0].From != BroadcastConfirmed || updates[0].To != BroadcastPending {
+		t.Fatalf("reorg updates %+v", updates)
+	}
+	if bt := expectState(t, b, hash, BroadcastPending); bt.BlockHeight != 0 {
+		t.Fatalf("block height %d after reorg", bt.BlockHeight)
+	}
+
+	b.Retry(time.Now())
+	if relay.sent != 2 {
+		t.Fatalf("sent %d times, expected a resend after reorg", relay.sent)
+	}
+
+	tip := nextBlock(&replaced, tx)
+	b.ObserveBlock(tip)
+	expectState(t, b, hash, BroadcastConfirmed)
+	tip = nextBlock(tip)
+	b.ObserveBlock(tip)
+	expectState(t, b, hash, BroadcastConfirmed)
+
+	updates = b.ObserveBlock(nextBlock(tip))
+	if len(updates) != 1 || updates[0].To != BroadcastFinalized || updates[0].Tx.BlockHeight != replaced.BlockHeight+1 {
+		t.Fatalf("finalize updates %+v", updates)
+	}
+	if _, ok := b.Get(hash); ok {
+		t.Fatal("finalized tx is still tracked")
+	}
+}
+
+func TestBroadcasterRollbackDoubleSpend(t *testing.T) {
+	block := testBlockWithTxs(t)
+	tx := block.TXs[0]
+	b := NewBroadcaster(&countRelay{}).SetConfirmations(2)
+	hash, err := b.Broadcast(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conflict := &Transaction{Hash: Hash{9}, Inputs: []TxInput{{KeyImage: tx.Inputs[0].KeyImage}}}
+	b.ObserveBlock(nextBlock(block, conflict))
+	if bt := expectState(t, b, hash, BroadcastDoubleSpent); bt.ConflictTx == nil || *bt.ConflictTx != conflict.Hash {
+		t.Fatalf("conflict %v", bt.ConflictTx)
+	}
+
+	updates := b.Rollback(block.BlockHeight + 1)
+	if len(updates) != 1 || updates[0].To != BroadcastPending {
+		t.Fatalf("rollback updates %+v", updates)
+	}
+	bt := expectState(t, b, hash, BroadcastPending)
+	if bt.ConflictTx != nil {
+		t.Fatalf("conflict %x after rollback", *bt.ConflictTx)
+	}
+	// без конфликта поля в JSON нет
+	if data, err := json.Marshal(bt); err != nil || bytes.Contains(data, []byte("conflict_tx")) {
+		t.Fatalf("json %s, %v", data, err)
+	}
+
+	// конфликт снова в блоке и набрал глубину — double spend окончательный
+	tip := nextBlock(block, conflict)
+	b.ObserveBlock(tip)
+	updates = b.ObserveBlock(nextBlock(tip))
+	if len(updates) != 1 || updates[0].From != BroadcastDoubleSpent || updates[0].To != BroadcastFinalized {
+		t.Fatalf("finalize updates %+v", updates)
+	}
+}
+
+func TestBroadcasterAttemptsCap(t *testing.T) {
+	tx := testBlockWithTxs(t).TXs[0]
+	relay := &countRelay{}
+	status := &poolStatus{}
+	b := NewBroadcaster(relay).SetStatusSource(status).SetRetry(0, 3)
+	hash, err := b.Broadcast(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// транзакция попадает в пул и выпадает из него, каждый раз переотправляясь
+	var failed bool
+	for range 10 {
+		status.inPool = true
+		b.Retry(time.Now())
+		status.inPool = false
+		for _, u := range b.Retry(time.Now()) {
+			failed = failed || u.To == BroadcastFailed
+		}
+		if failed {
+			break
+		}
+	}
+	if !failed {
+		t.Fatalf("tx is resent forever, sent %d times", relay.sent)
+	}
+	if relay.sent != 3 {
+		t.Fatalf("sent %d times, expected 3 in total", relay.sent)
+	}
+	if _, ok := b.Get(hash); ok {
+		t.Fatal("failed tx is still tracked")
+	}
+}
@@ -52,6 +52,11 @@ func NewClient(addr string, opts ...ClientOption) (*Client, error) {
 	}, nil
 }
 
+// SetDeadline: чтение и запись после t завершаются ошибкой, как net.Conn.SetDeadline
+func (c *Client) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
 func (c *Client) Close() error {
 	if c.conn == nil {
 		return nil
@@ -230,7 +235,7 @@ func (c *Client) ReadMessage() (*Header, *PortableStorage, error) {
 func (c *Client) SendRequest(Command uint32, payload []byte) error {
 	len := uint64(len(payload))
 	reqHeaderB := NewRequestHeader(Command, len)
-	if slices.Contains([]uint32{NotifyRequestChain, NotifyRequestGetObjects}, Command) {
+	if slices.Contains([]uint32{NotifyRequestChain, NotifyRequestGetObjects, NotifyNewTransaction}, Command) {
 		reqHeaderB.ExpectsResponse = false
 	}
 
@@ -0,0 +1,291 @@
+package levin
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// SelectionStrategy — как выбирать входы из выходов кошелька
+type SelectionStrategy int
+
+const (
+	// SelectLargestFirst: самые крупные выходы, пока не хватит
+	SelectLargestFirst SelectionStrategy = iota
+	// SelectMinInputs: один наименьший достаточный выход, иначе как largest-first
+	SelectMinInputs
+	// SelectAgeAware: сначала старые выходы и не больше одного из каждой транзакции,
+	// чтобы не связывать выходы, пришедшие вместе
+	SelectAgeAware
+)
+
+// ErrWouldLink: SelectAgeAware набирает сумму, только взяв несколько выходов
+// одной транзакции. Разрешить — SelectionRequest.AllowLinking, или другая стратегия.
+var ErrWouldLink = errors.New("selection would link outputs of one transaction")
+
+func (s SelectionStrategy) String() string {
+	switch s {
+	case SelectLargestFirst:
+		return "largest-first"
+	case SelectMinInputs:
+		return "min-inputs"
+	case SelectAgeAware:
+		return "age-aware"
+	}
+	return fmt.Sprintf("strategy(%d)", int(s))
+}
+
+// SelectionRequest — на что подобрать входы. Выходы берутся только из одного
+// кошелька и одного его аккаунта (major индекс субадресов), как в wallet2.
+type SelectionRequest struct {
+	Account  *Account
+	Major    uint32
+	Amount   Amount // сумма получателям без комиссии
+	Outputs  int    // число получателей, 0 — один
+	Strategy SelectionStrategy
+	// AllowLinking: SelectAgeAware может взять несколько выходов одной транзакции,
+	// если иначе не хватает; без него — ErrWouldLink
+	AllowLinking bool
+
+	FeeSource FeeSource // nil — фиксированная Fee
+	Priority  FeePriority
+	Fee       Amount
+}
+
+// SelectionID — номер блокировки выбранных выходов
+type SelectionID uint64
+
+// вход с кольцом из 16 весит ~0.7 KB, транзакция ограничена ~149 KB
+const maxTxInputs = 150
+
+// Selection — выбранные входы. Комиссия оценочная, точную считает TxBuilder.
+type Selection struct {
+	ID     SelectionID
+	Inputs []Input
+	Total  Amount
+	Fee    Amount
+	Change Amount
+}
+
+// CoinSelector выбирает входы из выходов Watcher и блокирует их, чтобы две
+// транзакции не потратили один выход. Блокировка снимается, когда watcher
+// увидит трату (транзакция подтверждена), или через Release (отменена).
+// Блокировки живут только в памяти: после рестарта выбранные, но ещё не
+// подтверждённые входы снова свободны. Отправленную транзакцию это не ломает —
+// повторная трата тех же выходов будет отклонена как double spend.
+type CoinSelector struct {
+	watcher *Watcher
+
+	mu     sync.Mutex
+	nextID SelectionID
+	locked map[outpoint]outputLock
+}
+
+type outputLock struct {
+	id      SelectionID
+	address string
+}
+
+func NewCoinSelector(w *Watcher) *CoinSelector {
+	return &CoinSelector{
+		watcher: w,
+		locked:  make(map[outpoint]outputLock),
+	}
+}
+
+// Select подбирает входы на req.Amount плюс комиссию и блокирует их
+func (s *CoinSelector) Select(req SelectionRequest) (*Selection, error) {
+	if req.Account == nil {
+		return nil, fmt.Errorf("no account")
+	}
+	// у view-only кошелька spend key нулевой: входы годятся только для BuildUnsigned
+	spendKey, _ := req.Account.SpendKey()
+	if req.Amount == 0 {
+		return nil, fmt.Errorf("zero amount")
+	}
+
+	feeFor, err := selectionFee(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked(req.Account.Address)
+
+	var candidates []OwnedOutput
+	for _, o := range s.watcher.SpendableOutputs(req.Account.Address) {
+		if _, locked := s.locked[outpoint{o.TxHash, o.OutputIndex}]; locked || o.Subaddress.Major != req.Major {
+			continue
+		}
+		candidates = append(candidates, o)
+	}
+
+	chosen, total, fee, err := selectOutputs(candidates, req.Amount, req.Strategy, req.AllowLinking, feeFor)
+	if err != nil {
+		return nil, fmt.Errorf("account %s/%d: %w", req.Account.Address, req.Major, err)
+	}
+
+	s.nextID++
+	sel := &Selection{ID: s.nextID, Total: total, Fee: fee, Change: total - req.Amount - fee}
+	for _, o := range chosen {
+		s.locked[outpoint{o.TxHash, o.OutputIndex}] = outputLock{sel.ID, req.Account.Address}
+		sel.Inputs = append(sel.Inputs, Input{Output: o, SpendKey: spendKey, ViewKey: req.Account.ViewKey()})
+	}
+	return sel, nil
+}
+
+// Release: транзакция не отправлена или отклонена, выходы снова доступны
+func (s *CoinSelector) Release(id SelectionID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for op, lock := range s.locked {
+		if lock.id == id {
+			delete(s.locked, op)
+		}
+	}
+}
+
+// Locked: заблокированные выходы кошелька
+func (s *CoinSelector) Locked(address string) []OwnedOutput {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var locked []OwnedOutput
+	for _, o := range s.watcher.UnspentOutputs(address) {
+		if _, ok := s.locked[outpoint{o.TxHash, o.OutputIndex}]; ok {
+			locked = append(locked, o)
+		}
+	}
+	return locked
+}
+
+// pruneLocked: выходы кошелька, которые watcher увидел потраченными, больше не держим
+func (s *CoinSelector) pruneLocked(address string) {
+	unspent := make(map[outpoint]bool)
+	for _, o := range s.watcher.UnspentOutputs(address) {
+		unspent[outpoint{o.TxHash, o.OutputIndex}] = true
+	}
+	for op, lock := range s.locked {
+		if lock.address == address && !unspent[op] {
+			delete(s.locked, op)
+		}
+	}
+}
+
+// selectionFee: комиссия в зависимости от числа входов; выходов — получатели и сдача
+func selectionFee(req SelectionRequest) (func(inputs int) (Amount, error), error) {
+	if req.FeeSource == nil {
+		return func(int) (Amount, error) { return req.Fee, nil }, nil
+	}
+	est, err := req.FeeSource.FeeEstimate()
+	if err != nil {
+		return nil, fmt.Errorf("fee estimate: %w", err)
+	}
+	outputs := max(req.Outputs, 1) + 1
+	return func(inputs int) (Amount, error) {
+		return est.Fee(EstimateTxWeight(inputs, outputs, DefaultRingSize, 33+11), req.Priority)
+	}, nil
+}
+
+func selectOutputs(candidates []OwnedOutput, amount Amount, strategy SelectionStrategy, allowLinking bool, feeFor func(int) (Amount, error)) ([]OwnedOutput, Amount, Amount, error) {
+	// порядок не должен зависеть от обхода map в Watcher
+	byOutpoint := func(a, b OwnedOutput) int {
+		if c := bytes.Compare(a.TxHash[:], b.TxHash[:]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.OutputIndex, b.OutputIndex)
+	}
+	largestFirst := func(a, b OwnedOutput) int {
+		if c := cmp.Compare(b.Amount, a.Amount); c != 0 {
+			return c
+		}
+		return byOutpoint(a, b)
+	}
+
+	switch strategy {
+	case SelectLargestFirst:
+		slices.SortFunc(candidates, largestFirst)
+		return accumulate(candidates, amount, feeFor, nil)
+
+	case SelectMinInputs:
+		fee, err := feeFor(1)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		need, ok := amount.Add(fee)
+		if !ok {
+			return nil, 0, 0, fmt.Errorf("amount overflows")
+		}
+		slices.SortFunc(candidates, largestFirst)
+		// с конца — наименьший выход, которого хватает одного
+		for i := len(candidates) - 1; i >= 0; i-- {
+			if candidates[i].Amount >= need {
+				return candidates[i : i+1], candidates[i].Amount, fee, nil
+			}
+		}
+		return accumulate(candidates, amount, feeFor, nil)
+
+	case SelectAgeAware:
+		slices.SortFunc(candidates, func(a, b OwnedOutput) int {
+			if c := cmp.Compare(a.BlockHeight, b.BlockHeight); c != 0 {
+				return c
+			}
+			return byOutpoint(a, b)
+		})
+		usedTx := make(map[Hash]bool)
+		chosen, total, fee, err := accumulate(candidates, amount, feeFor, func(o OwnedOutput) bool {
+			if usedTx[o.TxHash] {
+				return false
+			}
+			usedTx[o.TxHash] = true
+			return true
+		})
+		if err == nil {
+			return chosen, total, fee, nil
+		}
+		// без связывания не набирается — берём всё по возрасту, только если разрешено
+		linked, total, fee, linkErr := accumulate(candidates, amount, feeFor, nil)
+		if linkErr != nil || allowLinking {
+			return linked, total, fee, linkErr
+		}
+		return nil, 0, 0, fmt.Errorf("%w: %d outputs from distinct txs are not enough", ErrWouldLink, len(usedTx))
+	}
+	return nil, 0, 0, fmt.Errorf("unknown selection strategy %d", int(strategy))
+}
+
+// accumulate: берёт выходы по порядку, пока сумма не покроет amount и комиссию
+// за текущее число входов. allow == nil — подходят все.
+func accumulate(candidates []OwnedOutput, amount Amount, feeFor func(int) (Amount, error), allow func(OwnedOutput) bool) ([]OwnedOutput, Amount, Amount, error) {
+	var (
+		chosen []OwnedOutput
+		total  Amount
+		fee    Amount
+	)
+	for _, o := range candidates {
+		if len(chosen) == maxTxInputs {
+			break
+		}
+		if allow != nil && !allow(o) {
+			continue
+		}
+		chosen = append(chosen, o)
+		var ok bool
+		if total, ok = total.Add(o.Amount); !ok {
+			return nil, 0, 0, fmt.Errorf("inputs amount overflows")
+		}
+
+		var err error
+		if fee, err = feeFor(len(chosen)); err != nil {
+			return nil, 0, 0, err
+		}
+		if need, ok := amount.Add(fee); ok && total >= need {
+			return chosen, total, fee, nil
+		}
+	}
+	return nil, 0, 0, fmt.Errorf("insufficient unlocked balance: have %s in %d outputs, need %s plus fee %s XMR", total, len(chosen), amount, fee)
+}
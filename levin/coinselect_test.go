@@ -0,0 +1,116 @@
+package levin
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelectWithoutGlobalIndex(t *testing.T) {
+	full, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := full.ViewKey()
+	viewOnly, err := NewAccount(full.Address, view.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := OwnedOutput{Address: full.Address, TxHash: Hash{1}, Amount: 2 * XMR, BlockHeight: 100}
+	tests := []struct {
+		name    string
+		account *Account
+		ki      *Hash
+		ok      bool
+	}{
+		{"view-only", viewOnly, nil, true},
+		{"spend key, no key image", full, nil, false},
+		{"spend key", full, &Hash{2}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWatcher()
+			w.AddAccount(tc.account)
+			o := out
+			o.KeyImage = tc.ki
+			w.TrackOutput(o)
+			w.Advance(200, 0)
+
+			s := NewCoinSelector(w)
+			sel, err := s.Select(SelectionRequest{Account: tc.account, Amount: XMR, Fee: XMR / 100})
+			if tc.ok != (err == nil) {
+				t.Fatalf("ok %v, err %v", tc.ok, err)
+			}
+			if err != nil {
+				return
+			}
+			if len(sel.Inputs) != 1 || sel.Inputs[0].Output.GlobalIndex != nil {
+				t.Fatalf("inputs %+v", sel.Inputs)
+			}
+			// выход заблокирован до Release
+			if _, err := s.Select(SelectionRequest{Account: tc.account, Amount: XMR, Fee: XMR / 100}); err == nil {
+				t.Fatal("locked output selected twice")
+			}
+			s.Release(sel.ID)
+			if _, err := s.Select(SelectionRequest{Account: tc.account, Amount: XMR, Fee: XMR / 100}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSelectAgeAwareLinking(t *testing.T) {
+	a, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWatcher()
+	w.AddAccount(a)
+	// два выхода одной транзакции и один другой, все с key image
+	for i, o := range []OwnedOutput{
+		{TxHash: Hash{1}, OutputIndex: 0, Amount: 2 * XMR, BlockHeight: 100},
+		{TxHash: Hash{1}, OutputIndex: 1, Amount: 2 * XMR, BlockHeight: 100},
+		{TxHash: Hash{2}, OutputIndex: 0, Amount: XMR, BlockHeight: 101},
+	} {
+		o.Address = a.Address
+		o.KeyImage = &Hash{byte(10 + i)}
+		w.TrackOutput(o)
+	}
+	w.Advance(200, 0)
+	s := NewCoinSelector(w)
+	req := SelectionRequest{Account: a, Amount: 2*XMR + XMR/2, Fee: XMR / 100, Strategy: SelectAgeAware}
+
+	sel, err := s.Select(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.Inputs) != 2 || sel.Inputs[0].Output.TxHash == sel.Inputs[1].Output.TxHash {
+		t.Fatalf("inputs %+v", sel.Inputs)
+	}
+	s.Release(sel.ID)
+
+	// 4 XMR без двух выходов первой транзакции не набрать
+	req.Amount = 4 * XMR
+	if _, err := s.Select(req); !errors.Is(err, ErrWouldLink) {
+		t.Fatalf("expected ErrWouldLink, got %v", err)
+	}
+	if len(s.Locked(a.Address)) != 0 {
+		t.Fatal("outputs locked after a failed selection")
+	}
+
+	req.AllowLinking = true
+	sel, err = s.Select(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.Inputs) != 3 || sel.Total != 5*XMR {
+		t.Fatalf("linked selection %d inputs, total %s", len(sel.Inputs), sel.Total)
+	}
+	s.Release(sel.ID)
+
+	// не хватает и со связыванием — обычная ошибка баланса
+	req.Amount, req.AllowLinking = 10*XMR, false
+	if _, err := s.Select(req); err == nil || errors.Is(err, ErrWouldLink) {
+		t.Fatalf("expected insufficient balance, got %v", err)
+	}
+}
@@ -0,0 +1,362 @@
+package levin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+// Холодная подпись. Онлайн машина (view-only кошелёк с импортированными key images)
+// выбирает входы, кольца, получателей и комиссию и выгружает UnsignedTx; офлайн
+// машина со spend key собирает и подписывает транзакцию и возвращает SignedTx.
+
+const (
+	UnsignedTxVersion = 1
+	SignedTxVersion   = 1
+)
+
+type UnsignedInput struct {
+	Output    OwnedOutput  `json:"output"`
+	Ring      []RingMember `json:"ring"` // по возрастанию GlobalIndex
+	RealIndex int          `json:"real_index"`
+}
+
+type UnsignedDestination struct {
+	Address string `json:"address"`
+	Amount  Amount `json:"amount"`
+	Change  bool   `json:"change,omitempty"`
+}
+
+// UnsignedTx — всё, что выбрано онлайн. Tx ключи, пустой выход и порядок выходов
+// появляются только при подписи.
+type UnsignedTx struct {
+	Version      int                   `json:"version"`
+	Inputs       []UnsignedInput       `json:"inputs"`
+	Destinations []UnsignedDestination `json:"destinations"` // вместе со сдачей
+	Fee          Amount                `json:"fee"`
+}
+
+func ParseUnsignedTx(data []byte) (*UnsignedTx, error) {
+	u := &UnsignedTx{}
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, fmt.Errorf("failed to decode unsigned tx: %w", err)
+	}
+	if u.Version != UnsignedTxVersion {
+		return nil, fmt.Errorf("unsigned tx version %d, supported %d", u.Version, UnsignedTxVersion)
+	}
+	if err := u.check(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Sent: сумма получателям без сдачи
+func (u *UnsignedTx) Sent() Amount {
+	var sent Amount
+	for _, d := range u.Destinations {
+		if !d.Change {
+			sent += d.Amount
+		}
+	}
+	return sent
+}
+
+// check: входы покрывают получателей и комиссию без остатка
+func (u *UnsignedTx) check() error {
+	if len(u.Inputs) == 0 {
+		return fmt.Errorf("unsigned tx has no inputs")
+	}
+	if len(u.Destinations) == 0 {
+		return fmt.Errorf("unsigned tx has no destinations")
+	}
+
+	var in Amount
+	for i, ui := range u.Inputs {
+		if len(ui.Ring) < 2 {
+			return fmt.Errorf("input %d: ring of %d members", i, len(ui.Ring))
+		}
+		var ok bool
+		if in, ok = in.Add(ui.Output.Amount); !ok {
+			return fmt.Errorf("inputs amount overflows")
+		}
+	}
+	out := u.Fee
+	for i, d := range u.Destinations {
+		if d.Amount == 0 {
+			return fmt.Errorf("destination %d: zero amount", i)
+		}
+		var ok bool
+		if out, ok = out.Add(d.Amount); !ok {
+			return fmt.Errorf("destinations amount overflows")
+		}
+	}
+	if in != out {
+		return fmt.Errorf("inputs %s XMR do not match destinations and fee %s XMR", in, out)
+	}
+	return nil
+}
+
+// Sign: офлайн часть. accounts — кошельки входов со spend key.
+func (u *UnsignedTx) Sign(accounts ...*Account) (*Transaction, error) {
+	inputs := make([]Input, len(u.Inputs))
+	for i, ui := range u.Inputs {
+		j := slices.IndexFunc(accounts, func(a *Account) bool { return a.Address == ui.Output.Address })
+		if j < 0 {
+			return nil, fmt.Errorf("input %d: no account for %s", i, ui.Output.Address)
+		}
+		spendKey, ok := accounts[j].SpendKey()
+		if !ok {
+			return nil, fmt.Errorf("input %d: account %s is view-only", i, ui.Output.Address)
+		}
+		inputs[i] = Input{Output: ui.Output, SpendKey: spendKey, ViewKey: accounts[j].ViewKey()}
+	}
+	return u.sign(inputs)
+}
+
+// sign: inputs в порядке u.Inputs
+func (u *UnsignedTx) sign(inputs []Input) (*Transaction, error) {
+	if err := u.check(); err != nil {
+		return nil, err
+	}
+	for i, in := range inputs {
+		if in.SpendKey == (Key{}) {
+			return nil, fmt.Errorf("input %d: no private spend key", i)
+		}
+	}
+
+	destinations := make([]Destination, 0, len(u.Destinations)+1)
+	for i, d := range u.Destinations {
+		dest := Destination{Address: d.Address, Amount: d.Amount}
+		if d.Change {
+			viewKey := inputs[0].ViewKey
+			if err := checkOwnAddress(d.Address, viewKey); err != nil {
+				return nil, fmt.Errorf("destination %d: change: %w", i, err)
+			}
+			dest.change, dest.viewKey = true, &viewKey
+		}
+		destinations = append(destinations, dest)
+	}
+	if len(destinations) > maxTxOutputs {
+		return nil, fmt.Errorf("too many outputs: %d, max %d", len(destinations), maxTxOutputs)
+	}
+
+	// выходов не меньше двух: без сдачи — пустой выход на случайный адрес, как в wallet2
+	if len(destinations) == 1 {
+		dummy, err := dummyDestination(destinations[0].Address)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, dummy)
+	}
+	// порядок выходов не должен выдавать сдачу
+	rand.Shuffle(len(destinations), func(i, j int) {
+		destinations[i], destinations[j] = destinations[j], destinations[i]
+	})
+
+	tx := NewEmptyTransaction()
+	tx.RctSignature.TxnFee = uint64(u.Fee)
+	tx.PInputs = append([]Input(nil), inputs...)
+	tx.POutputs = destinations
+
+	if err := tx.calcExtra(); err != nil {
+		return nil, fmt.Errorf("failed to calc extra: %w", err)
+	}
+	for i, in := range tx.PInputs {
+		if err := tx.writeInput2(in, u.Inputs[i]); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	tx.sortInputs()
+	for i, d := range tx.POutputs {
+		if err := tx.writeOutput2(d); err != nil {
+			return nil, fmt.Errorf("destination %d: %w", i, err)
+		}
+	}
+	if err := tx.SignTransaction(); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// CheckSigned: онлайн, перед отправкой — подписанная транзакция тратит ровно
+// выбранные входы с выбранными кольцами и платит ту же комиссию. Входы
+// сопоставляются по кольцу: у view-only кошелька key images входов нет.
+func (u *UnsignedTx) CheckSigned(tx *Transaction) error {
+	_, err := u.matchInputs(tx)
+	return err
+}
+
+// CheckSignedTx: CheckSigned для SignedTx и сверка key images — каждый должен
+// стоять во входе с кольцом своего выхода, иначе ImportKeyImages запишет чужой
+func (u *UnsignedTx) CheckSignedTx(s *SignedTx) (*Transaction, error) {
+	tx, err := s.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	match, err := u.matchInputs(tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.KeyImages) != len(u.Inputs) {
+		return nil, fmt.Errorf("signed tx has %d key images, expected %d", len(s.KeyImages), len(u.Inputs))
+	}
+	for _, ki := range s.KeyImages {
+		i := slices.IndexFunc(match, func(j int) bool {
+			out := u.Inputs[j].Output
+			return out.TxHash == ki.TxHash && out.OutputIndex == ki.OutputIndex
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("key image for %x:%d: not one of our inputs", ki.TxHash, ki.OutputIndex)
+		}
+		if tx.Inputs[i].KeyImage != ki.KeyImage {
+			return nil, fmt.Errorf("key image for %x:%d does not match signed tx input %d", ki.TxHash, ki.OutputIndex, i)
+		}
+	}
+	return tx, nil
+}
+
+// matchInputs: для каждого входа tx — индекс в u.Inputs
+func (u *UnsignedTx) matchInputs(tx *Transaction) ([]int, error) {
+	if tx.RctSignature == nil || tx.RctSignature.TxnFee != uint64(u.Fee) {
+		return nil, fmt.Errorf("signed tx fee does not match %s XMR", u.Fee)
+	}
+	if len(tx.Inputs) != len(u.Inputs) {
+		return nil, fmt.Errorf("signed tx has %d inputs, expected %d", len(tx.Inputs), len(u.Inputs))
+	}
+	if len(tx.Outputs) < len(u.Destinations) {
+		return nil, fmt.Errorf("signed tx has %d outputs, expected at least %d", len(tx.Outputs), len(u.Destinations))
+	}
+
+	offsets := make([][]uint64, len(u.Inputs))
+	for j, ui := range u.Inputs {
+		if ui.RealIndex < 0 || ui.RealIndex >= len(ui.Ring) {
+			return nil, fmt.Errorf("input %d: real index %d is out of ring of %d", j, ui.RealIndex, len(ui.Ring))
+		}
+		real := ui.Ring[ui.RealIndex]
+		if ui.Output.OutputKey != (Hash{}) && real.Key != ui.Output.OutputKey ||
+			ui.Output.GlobalIndex != nil && real.GlobalIndex != *ui.Output.GlobalIndex {
+			return nil, fmt.Errorf("input %d: ring member %d is not output %x:%d", j, ui.RealIndex, ui.Output.TxHash, ui.Output.OutputIndex)
+		}
+		indices := make([]uint64, len(ui.Ring))
+		for k, m := range ui.Ring {
+			indices[k] = m.GlobalIndex
+		}
+		var err error
+		if offsets[j], err = BuildKeyOffsets(indices); err != nil {
+			return nil, fmt.Errorf("input %d: %w", j, err)
+		}
+	}
+
+	match := make([]int, len(tx.Inputs))
+	used := make([]bool, len(u.Inputs))
+	for i, in := range tx.Inputs {
+		j := -1
+		for k, ui := range u.Inputs {
+			if used[k] || !slices.Equal(offsets[k], in.KeyOffsets) {
+				continue
+			}
+			// key image известен только кошельку со spend key, тогда он тоже должен совпасть
+			if ui.Output.KeyImage != nil && *ui.Output.KeyImage != in.KeyImage {
+				continue
+			}
+			j = k
+			break
+		}
+		if j < 0 {
+			return nil, fmt.Errorf("signed tx input %d: ring does not match any of our inputs", i)
+		}
+		used[j] = true
+		match[i] = j
+	}
+	return match, nil
+}
+
+// selectInputRing: онлайн часть входа — глобальный индекс и кольцо
+func selectInputRing(out OwnedOutput, picker *GammaPicker, src RingMemberSource) (UnsignedInput, error) {
+	if out.GlobalIndex == nil {
+		gi, err := src.GlobalIndex(out.TxHash, out.OutputIndex)
+		if err != nil {
+			return UnsignedInput{}, fmt.Errorf("failed to get output index: %w", err)
+		}
+		out.GlobalIndex = &gi
+	}
+
+	ring, realIndex, err := SelectRing(picker, src, *out.GlobalIndex, out.OutputKey, DefaultRingSize)
+	if err != nil {
+		return UnsignedInput{}, fmt.Errorf("failed to select decoys: %w", err)
+	}
+	return UnsignedInput{Output: out, Ring: ring, RealIndex: realIndex}, nil
+}
+
+/*--- SignedTx ---*/
+
+// SignedTx — результат офлайн подписи, его онлайн машина отправляет в сеть.
+// TxKey (FormatTxKey) нужно сохранить: без него не построить OutProof.
+// KeyImages — для view-only кошелька: без них он не увидит трату своих выходов.
+type SignedTx struct {
+	Version   int              `json:"version"`
+	Hash      Hash             `json:"hash"`
+	Blob      ByteArray        `json:"blob"`
+	TxKey     string           `json:"tx_key"`
+	KeyImages []SignedKeyImage `json:"key_images"`
+}
+
+// SignedKeyImage: key image потраченного выхода
+type SignedKeyImage struct {
+	TxHash      Hash   `json:"tx_hash"`
+	OutputIndex uint64 `json:"output_index"`
+	KeyImage    Hash   `json:"key_image"`
+}
+
+func NewSignedTx(tx *Transaction) *SignedTx {
+	tx.CalcHash()
+	s := &SignedTx{
+		Version: SignedTxVersion,
+		Hash:    tx.Hash,
+		Blob:    ByteArray(tx.Serialize()),
+		TxKey:   FormatTxKey(tx.SecretKey, tx.AdditionalSecretKeys),
+	}
+	// после sortInputs PInputs идут в том же порядке, что и Inputs
+	if len(tx.PInputs) == len(tx.Inputs) {
+		for i, in := range tx.PInputs {
+			s.KeyImages = append(s.KeyImages, SignedKeyImage{
+				TxHash:      in.Output.TxHash,
+				OutputIndex: in.Output.OutputIndex,
+				KeyImage:    tx.Inputs[i].KeyImage,
+			})
+		}
+	}
+	return s
+}
+
+// ImportKeyImages: после CheckSignedTx — key images входов в Watcher, чтобы
+// view-only кошелёк отметил выходы потраченными, когда транзакция попадёт в блок
+func (s *SignedTx) ImportKeyImages(w *Watcher) {
+	for _, ki := range s.KeyImages {
+		w.ImportKeyImage(ki.TxHash, ki.OutputIndex, ki.KeyImage)
+	}
+}
+
+func ParseSignedTx(data []byte) (*SignedTx, error) {
+	s := &SignedTx{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to decode signed tx: %w", err)
+	}
+	if s.Version != SignedTxVersion {
+		return nil, fmt.Errorf("signed tx version %d, supported %d", s.Version, SignedTxVersion)
+	}
+	return s, nil
+}
+
+// Transaction: разбор blob, хэш сверяется с записанным
+func (s *SignedTx) Transaction() (*Transaction, error) {
+	tx := &Transaction{Raw: s.Blob}
+	tx.ParseTx()
+	tx.ParseRctSig()
+	tx.CalcHash()
+	if tx.Hash != s.Hash {
+		return nil, fmt.Errorf("signed tx hash %x does not match blob hash %x", s.Hash, tx.Hash)
+	}
+	return tx, nil
+}
@@ -0,0 +1,133 @@
+package levin
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// outputsTx: транзакция только с выходами (extra, ключи, ecdhInfo, outPk) —
+// входы для скана и доказательств не нужны. Hash фиксированный.
+func outputsTx(t *testing.T, destinations ...Destination) *Transaction {
+	t.Helper()
+	tx := NewEmptyTransaction()
+	tx.POutputs = destinations
+	if err := tx.calcExtra(); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range tx.POutputs {
+		if err := tx.writeOutput2(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx.Hash = Hash{7}
+	return tx
+}
+
+// fundedViewOnly: выход на новый кошелёк, найденный его view-only копией
+func fundedViewOnly(t *testing.T, amount Amount) (full *Account, w *Watcher, out OwnedOutput, commitment Hash) {
+	t.Helper()
+	full, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := full.ViewKey()
+	viewOnly, err := NewAccount(full.Address, view.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewOnly.SetSubaddressLookahead(0, 0)
+	w = NewWatcher()
+	w.AddAccount(viewOnly)
+
+	other, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funding := outputsTx(t, Destination{Address: full.Address, Amount: amount}, Destination{Address: other.Address, Amount: XMR})
+	owned, _ := w.ScanTx(funding, 100000)
+	if len(owned) != 1 {
+		t.Fatalf("view-only wallet found %d outputs", len(owned))
+	}
+	return full, w, owned[0], funding.RctSignature.OutPk[owned[0].OutputIndex]
+}
+
+func TestColdSignViewOnly(t *testing.T) {
+	full, w, out, commitment := fundedViewOnly(t, 2*XMR)
+	if out.KeyImage != nil {
+		t.Fatal("view-only wallet knows a key image")
+	}
+	gi := uint64(100000 * fakeOutputsPerBlock)
+	out.GlobalIndex = &gi
+	src := NewFakeRingSource(200000*fakeOutputsPerBlock).Add(out, commitment)
+
+	dest, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := full.ViewKey()
+	u, err := NewTxBuilder().
+		AddInput(Input{Output: out, ViewKey: view}).
+		AddDestination(dest.Address, XMR).
+		SetChangeAddress(full.Address).
+		SetFee(XMR / 1000).
+		SetRingMemberSource(src).
+		BuildUnsigned()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// онлайн -> офлайн -> онлайн через JSON, как xmrsign
+	data, _ := json.Marshal(u)
+	offline, err := ParseUnsignedTx(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := offline.Sign(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(NewSignedTx(tx))
+	signed, err := ParseSignedTx(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.CheckSigned(tx); err != nil {
+		t.Fatalf("CheckSigned: %v", err)
+	}
+	parsed, err := u.CheckSignedTx(signed)
+	if err != nil {
+		t.Fatalf("CheckSignedTx: %v", err)
+	}
+	if len(signed.KeyImages) != 1 || signed.KeyImages[0].TxHash != out.TxHash || signed.KeyImages[0].KeyImage != parsed.Inputs[0].KeyImage {
+		t.Fatalf("key images %+v", signed.KeyImages)
+	}
+
+	// без импорта view-only кошелёк трату не видит
+	if _, spent := w.ScanTx(parsed, 100020); len(spent) != 0 {
+		t.Fatal("spend seen without key images")
+	}
+	signed.ImportKeyImages(w)
+	if _, spent := w.ScanTx(parsed, 100020); len(spent) != 1 || spent[0].Output.TxHash != out.TxHash {
+		t.Fatalf("spent %+v after import", spent)
+	}
+
+	t.Run("wrong key image", func(t *testing.T) {
+		bad := *signed
+		bad.KeyImages = []SignedKeyImage{signed.KeyImages[0]}
+		bad.KeyImages[0].KeyImage = Hash{1}
+		if _, err := u.CheckSignedTx(&bad); err == nil {
+			t.Fatal("key image of another input accepted")
+		}
+	})
+	t.Run("other ring", func(t *testing.T) {
+		other := *u
+		other.Inputs = []UnsignedInput{u.Inputs[0]}
+		other.Inputs[0].Ring = append([]RingMember(nil), u.Inputs[0].Ring...)
+		k := (u.Inputs[0].RealIndex + 1) % len(other.Inputs[0].Ring)
+		other.Inputs[0].Ring[k].GlobalIndex++
+		if err := other.CheckSigned(tx); err == nil {
+			t.Fatal("input with a different ring accepted")
+		}
+	})
+}
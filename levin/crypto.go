@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"strings"
 
 	"filippo.io/edwards25519"
@@ -35,24 +36,20 @@ func decodeMoneroBase58(s string) ([]byte, error) {
 		return nil, errors.New("empty string")
 	}
 
-	out := make([]byte, 0)
-	i := 0
-	for i < len(s) {
-		rem := len(s) - i
-		var chunkLenChars int
-		var chunkLenBytes int
-		if rem > 7 {
-			chunkLenChars = 11
-			chunkLenBytes = 8
-		} else if rem == 7 {
-			chunkLenChars = 7
-			chunkLenBytes = 5
-		} else {
-			return nil, errors.New("unexpected base58 length for monero address")
-		}
+	// полные блоки по 11 символов, хвост — по таблице размеров, как в encode
+	fullBlocks, tail := len(s)/11, len(s)%11
+	tailBytes := slices.Index(moneroBase58EncodedBlockSizes[:], tail)
+	if tailBytes < 0 {
+		return nil, errors.New("unexpected base58 length for monero address")
+	}
 
-		chunk := s[i : i+chunkLenChars]
-		i += chunkLenChars
+	out := make([]byte, 0, fullBlocks*8+tailBytes)
+	for i := 0; i < len(s); i += 11 {
+		chunk := s[i:min(i+11, len(s))]
+		chunkLenBytes := 8
+		if len(chunk) < 11 {
+			chunkLenBytes = tailBytes
+		}
 
 		val := big.NewInt(0)
 		for _, ch := range []byte(chunk) {
@@ -63,22 +60,38 @@ func decodeMoneroBase58(s string) ([]byte, error) {
 			val.Mul(val, big.NewInt(58))
 			val.Add(val, big.NewInt(int64(idx)))
 		}
-
-		buf := make([]byte, chunkLenBytes)
-		tmp := new(big.Int).Set(val)
-		for j := chunkLenBytes - 1; j >= 0; j-- {
-			b := new(big.Int)
-			b.Mod(tmp, big.NewInt(256))
-			buf[j] = byte(b.Int64())
-			tmp.Div(tmp, big.NewInt(256))
+		if val.BitLen() > 8*chunkLenBytes {
+			return nil, errors.New("base58 block overflow")
 		}
 
-		out = append(out, buf...)
+		out = append(out, val.FillBytes(make([]byte, chunkLenBytes))...)
 	}
 
 	return out, nil
 }
 
+// размер в символах base58 для блока из 0..8 байт
+var moneroBase58EncodedBlockSizes = [9]int{0, 2, 3, 5, 6, 7, 9, 10, 11}
+
+// encodeMoneroBase58: блоки по 8 байт кодируются в 11 символов, последний неполный — по таблице
+func encodeMoneroBase58(data []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i += 8 {
+		block := data[i:min(i+8, len(data))]
+		size := moneroBase58EncodedBlockSizes[len(block)]
+
+		val := new(big.Int).SetBytes(block)
+		chars := make([]byte, size)
+		for j := size - 1; j >= 0; j-- {
+			mod := new(big.Int)
+			val.DivMod(val, big.NewInt(58), mod)
+			chars[j] = moneroBase58Alphabet[mod.Int64()]
+		}
+		sb.Write(chars)
+	}
+	return sb.String()
+}
+
 func DecodeAddressRaw(s string) ([]byte, error) {
 	return decodeMoneroBase58(s)
 }
@@ -99,89 +112,39 @@ func KeccakByte(data []byte) byte {
 
 // Проверка, является ли адрес субадресом
 func isSubAddress(addr string) bool {
-	b, err := decodeMoneroBase58(addr)
-	if err != nil {
-		return false
-	}
-
-	if len(b) < 69 {
-		return false
-	}
-
-	networkByte := b[0]
-	return networkByte == 0x2A
+	p, err := ParseAddress(addr)
+	return err == nil && p.Type == AddressSubaddress
 }
 
-// DecodeAddress decodes a standard or integrated Monero address and returns public spend and view keys
+// DecodeAddress decodes a Monero address of any type and network and returns public spend and view keys
 func DecodeAddress(addr string) (pubSpend [32]byte, pubView [32]byte, err error) {
-	b, err := decodeMoneroBase58(addr)
+	p, err := ParseAddress(addr)
 	if err != nil {
 		return pubSpend, pubView, err
 	}
-
-	if len(b) < 69 {
-		return pubSpend, pubView, errors.New("decoded address too short")
-	}
-
-	networkByte := b[0]
-
-	var checksumDataLen int
-	var checksumStart int
-
-	switch {
-	case len(b) == 69 && (networkByte == 0x12 || networkByte == 0x2A):
-		// Standard address or subaddress
-		checksumDataLen = 65
-		checksumStart = 65
-
-	case len(b) == 77 && networkByte == 0x13:
-		// Integrated address (has 8-byte payment_id after pubView)
-		checksumDataLen = 73
-		checksumStart = 73
-
-	default:
-		return pubSpend, pubView, fmt.Errorf("invalid address: unknown format (len=%d, network_byte=0x%02x)", len(b), networkByte)
-	}
-
-	// Extract keys (same position for all types)
-	copy(pubSpend[:], b[1:33])
-	copy(pubView[:], b[33:65])
-
-	// Verify checksum
-	payload := b[:checksumDataLen]
-	sum := keccak256(payload)
-
-	expectedChecksum := b[checksumStart : checksumStart+4]
-	if !equalBytes(sum[:4], expectedChecksum) {
-		return pubSpend, pubView, errors.New("address checksum mismatch")
-	}
-
-	return pubSpend, pubView, nil
+	return p.PubSpend, p.PubView, nil
 }
 
 // ExtractPaymentID extracts payment_id from an integrated address
 // Returns empty slice for standard addresses
 func ExtractPaymentID(addr string) ([]byte, error) {
-	b, err := decodeMoneroBase58(addr)
+	p, err := ParseAddress(addr)
 	if err != nil {
 		return nil, err
 	}
+	return p.PaymentID, nil
+}
 
-	if len(b) < 69 {
-		return nil, errors.New("decoded address too short")
+// ParsePaymentID decodes a hex payment id: 16 chars (short, 8 bytes) or 64 chars (long, 32 bytes)
+func ParsePaymentID(s string) (ByteArray, error) {
+	if len(s) != 16 && len(s) != 64 {
+		return nil, fmt.Errorf("payment id must be 16 or 64 hex chars, got %d", len(s))
 	}
-
-	networkByte := b[0]
-
-	// Only integrated addresses (0x13) have payment_id
-	if len(b) == 77 && networkByte == 0x13 {
-		paymentID := make([]byte, 8)
-		copy(paymentID, b[65:73])
-		return paymentID, nil
+	pid, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment id: %w", err)
 	}
-
-	// Standard or subaddress - no payment_id
-	return nil, nil
+	return pid, nil
 }
 
 func equalBytes(a, b []byte) bool {
@@ -329,147 +292,6 @@ func SharedSecret(txPubKey []byte, privateViewKey []byte) ([]byte, error) {
 	return shared.Bytes(), nil
 }
 
-const (
-	TX_EXTRA_TAG_PUBKEY         = 0x01
-	TX_EXTRA_NONCE              = 0x02
-	TX_EXTRA_ADDITIONAL_PUBKEYS = 0x04
-
-	TX_EXTRA_NONCE_PAYMENT_ID     = 0x00
-	TX_EXTRA_NONCE_ENC_PAYMENT_ID = 0x01
-)
-
-func parseTxExtra(extra []byte) (txPubKey []byte, additionalKeys [][]byte, pid []byte, encPID []byte, err error) {
-	i := 0
-	for i < len(extra) {
-		tag := extra[i]
-		i++
-
-		switch tag {
-		case 0x00: // padding
-			continue
-
-		case TX_EXTRA_TAG_PUBKEY:
-			if i+32 > len(extra) {
-				return nil, nil, nil, nil, errors.New("tx extra: truncated pubkey")
-			}
-			txPubKey = make([]byte, 32)
-			copy(txPubKey, extra[i:i+32])
-			i += 32
-
-		case TX_EXTRA_NONCE:
-			if i >= len(extra) {
-				return nil, nil, nil, nil, errors.New("tx extra: nonce length missing")
-			}
-			L := int(extra[i])
-			i++
-			if i+L > len(extra) {
-				return nil, nil, nil, nil, errors.New("tx extra: nonce truncated")
-			}
-			nonce := extra[i : i+L]
-			i += L
-
-			if len(nonce) >= 1 {
-				switch nonce[0] {
-				case TX_EXTRA_NONCE_PAYMENT_ID:
-					if len(nonce) >= 1+32 {
-						pid = make([]byte, 32)
-						copy(pid, nonce[1:1+32])
-					}
-				case TX_EXTRA_NONCE_ENC_PAYMENT_ID:
-					if len(nonce) >= 1+8 {
-						encPID = make([]byte, 8)
-						copy(encPID, nonce[1:1+8])
-					}
-				}
-			}
-
-		case TX_EXTRA_ADDITIONAL_PUBKEYS:
-			if i >= len(extra) {
-				return nil, nil, nil, nil, errors.New("tx extra: additional keys length missing")
-			}
-			L := int(extra[i])
-			i++
-			if i+L > len(extra) || L%32 != 0 {
-				return nil, nil, nil, nil, errors.New("tx extra: invalid additional keys")
-			}
-			count := L / 32
-			for j := 0; j < count; j++ {
-				key := make([]byte, 32)
-				copy(key, extra[i+j*32:i+(j+1)*32])
-				additionalKeys = append(additionalKeys, key)
-			}
-			i += L
-
-		default:
-			// неизвестный тег — в Monero их может быть больше
-			// но без длины мы не можем корректно пропустить
-			// так что просто прерываемся
-			return txPubKey, additionalKeys, pid, encPID, nil
-		}
-	}
-	return txPubKey, additionalKeys, pid, encPID, nil
-}
-
-// decryptShortPaymentID:
-// - txPubKey: 32-byte tx public key (from extra tag 0x01)
-// - privViewKey: 32-byte private view key (hex-decoded, little-endian)
-// - encPID: 8-byte encrypted payment id (from nonce type 0x01)
-// returns uint64 payment id (interpreted little-endian), and raw 8 bytes
-func decryptShortPaymentID(txPubKey, privViewKey, encPID []byte) (uint64, []byte, error) {
-	if len(txPubKey) != 32 {
-		return 0, nil, errors.New("txPubKey must be 32 bytes")
-	}
-	if len(privViewKey) != 32 {
-		return 0, nil, errors.New("privViewKey must be 32 bytes")
-	}
-	if len(encPID) != 8 {
-		return 0, nil, errors.New("encPID must be 8 bytes")
-	}
-
-	// Decode txPubKey as Point
-	R := new(edwards25519.Point)
-	if _, err := R.SetBytes(txPubKey); err != nil {
-		return 0, nil, fmt.Errorf("invalid tx pubkey: %w", err)
-	}
-
-	// Load private view key as scalar (Monero private keys are scalars mod l)
-	// Use SetCanonicalBytes to set scalar from 32-byte little-endian encoding (must be canonical)
-	scalar := new(edwards25519.Scalar)
-	if _, err := scalar.SetCanonicalBytes(privViewKey); err != nil {
-		// Try SetBytesWithClamping as fallback if not canonical
-		if _, err2 := scalar.SetBytesWithClamping(privViewKey); err2 != nil {
-			return 0, nil, fmt.Errorf("invalid privViewKey: %v / %v", err, err2)
-		}
-	}
-
-	// shared = a * R
-	sharedPoint := new(edwards25519.Point).ScalarMult(scalar, R)
-
-	// multiply by 8 (Monero code uses 8*R etc in derivations)
-	eight := ScalarFromUint64(8)
-	sharedPoint = new(edwards25519.Point).ScalarMult(eight, sharedPoint)
-
-	// serialize shared point to bytes (compressed)
-	sharedBytes := sharedPoint.Bytes() // 32 bytes
-
-	// compute keccak256(sharedBytes || 0x8d)
-	h := sha3.NewLegacyKeccak256()
-	h.Write(sharedBytes)
-	h.Write([]byte{0x8d})
-	hash := h.Sum(nil) // 32 bytes
-
-	// XOR first 8 bytes of hash with encPID
-	key := hash[:8]
-	pidBytes := make([]byte, 8)
-	for i := 0; i < 8; i++ {
-		pidBytes[i] = encPID[i] ^ key[i]
-	}
-
-	// interpret as little-endian uint64
-	id := binary.LittleEndian.Uint64(pidBytes)
-	return id, pidBytes, nil
-}
-
 func encryptPaymentID(paymentID, pubViewKey, txSecretKey []byte) ([8]byte, error) {
 	shared, err := SharedSecret(pubViewKey, txSecretKey)
 	if err != nil {
@@ -0,0 +1,212 @@
+package levin
+
+import (
+	"cmp"
+	crand "crypto/rand"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+)
+
+// Выбор decoy как в wallet2 (gamma_picker, get_outs)
+const (
+	DifficultyTarget = 120 // DIFFICULTY_TARGET_V2, секунд на блок
+
+	DecoyGammaShape = 19.28
+	DecoyGammaScale = 1 / 1.61
+
+	defaultUnlockTime = CryptonoteDefaultTxSpendableAge * DifficultyTarget
+	recentSpendWindow = 15 * DifficultyTarget
+	blocksInAYear     = 86400 * 365 / DifficultyTarget
+)
+
+// OutputDistribution — кумулятивное число RingCT выходов по блокам, как
+// get_output_distribution с cumulative. Cumulative[i] — выходов в цепочке
+// после блока StartHeight+i, Base — до StartHeight.
+type OutputDistribution struct {
+	StartHeight uint64   `json:"start_height"`
+	Base        uint64   `json:"base"`
+	Cumulative  []uint64 `json:"distribution"`
+}
+
+// GammaPicker — gamma_picker из wallet2: возраст траты ~ exp(Gamma(19.28, 1/1.61))
+// секунд, переводится в выход через среднее время между выходами за последний год.
+type GammaPicker struct {
+	rng *rand.Rand
+
+	offsets           []uint64
+	base              uint64
+	end               int    // последние 10 блоков ещё заблокированы
+	numRctOutputs     uint64 // выходов в разблокированных блоках
+	averageOutputTime float64
+}
+
+// NewGammaPicker: rng == nil — ChaCha8 с ключом из crypto/rand
+func NewGammaPicker(d *OutputDistribution, rng *rand.Rand) (*GammaPicker, error) {
+	offsets := d.Cumulative
+	if len(offsets) <= CryptonoteDefaultTxSpendableAge {
+		return nil, fmt.Errorf("output distribution has %d blocks, need more than %d", len(offsets), CryptonoteDefaultTxSpendableAge)
+	}
+	if rng == nil {
+		var seed [32]byte
+		crand.Read(seed[:])
+		rng = rand.New(rand.NewChaCha8(seed))
+	}
+
+	blocksToConsider := min(len(offsets), blocksInAYear)
+	outputsToConsider := offsets[len(offsets)-1] - d.Base
+	if blocksToConsider < len(offsets) {
+		outputsToConsider = offsets[len(offsets)-1] - offsets[len(offsets)-blocksToConsider-1]
+	}
+
+	p := &GammaPicker{
+		rng:     rng,
+		offsets: offsets,
+		base:    d.Base,
+		end:     len(offsets) - CryptonoteDefaultTxSpendableAge,
+	}
+	p.numRctOutputs = offsets[p.end-1]
+	if p.numRctOutputs == 0 || outputsToConsider == 0 {
+		return nil, fmt.Errorf("output distribution has no RingCT outputs")
+	}
+	// считается, что время блока постоянно на всём отрезке
+	p.averageOutputTime = DifficultyTarget * float64(blocksToConsider) / float64(outputsToConsider)
+	return p, nil
+}
+
+// NumOutputs: выходов, из которых выбираются decoy (без последних 10 блоков)
+func (p *GammaPicker) NumOutputs() uint64 {
+	return p.numRctOutputs
+}
+
+func (p *GammaPicker) AverageOutputTime() float64 {
+	return p.averageOutputTime
+}
+
+// Pick: глобальный индекс кандидата; ok == false — неудачный выбор, wallet2 тогда
+// просто выбирает снова
+func (p *GammaPicker) Pick() (uint64, bool) {
+	x := math.Exp(sampleGamma(p.rng, DecoyGammaShape, DecoyGammaScale))
+	if x > defaultUnlockTime {
+		// возраст считается от момента разблокировки
+		x -= defaultUnlockTime
+	} else {
+		x = float64(p.rng.Uint64N(uint64(math.Ceil(recentSpendWindow))))
+	}
+
+	outputIndex := uint64(x / p.averageOutputTime)
+	if outputIndex >= p.numRctOutputs {
+		return 0, false
+	}
+	outputIndex = p.numRctOutputs - 1 - outputIndex
+	if outputIndex < p.base {
+		return 0, false // старше известной части распределения
+	}
+
+	// lower_bound, как в wallet2: выход, равный offsets[i-1], относится к блоку i-1
+	index := sort.Search(p.end, func(i int) bool { return p.offsets[i] >= outputIndex })
+	if index == p.end {
+		return 0, false
+	}
+	firstRct := p.base
+	if index > 0 {
+		firstRct = p.offsets[index-1]
+	}
+	nRct := p.offsets[index] - firstRct
+	if nRct == 0 {
+		return 0, false
+	}
+	return firstRct + p.rng.Uint64N(nRct), true
+}
+
+// SelectRing набирает кольцо как wallet2::get_outs: запрашивает (ringSize*1.5+1)
+// кандидатов вместе с настоящим выходом, отбрасывает заблокированные и повторы
+// ключей и берёт ringSize-1 случайных из оставшихся. Возвращает кольцо по
+// возрастанию индекса и позицию настоящего выхода. outputKey (если известен)
+// сверяется с ответом src.
+func SelectRing(picker *GammaPicker, src RingMemberSource, real uint64, outputKey Hash, ringSize int) ([]RingMember, int, error) {
+	if picker.NumOutputs() < uint64(ringSize) {
+		return nil, 0, fmt.Errorf("only %d outputs to pick decoys from, ring size %d", picker.NumOutputs(), ringSize)
+	}
+
+	requested := int(float64(ringSize)*1.5 + 1)
+	seen := map[uint64]bool{real: true}
+	picks := []uint64{real}
+	for tries := 0; len(picks) < requested; tries++ {
+		if tries > 100*requested {
+			return nil, 0, fmt.Errorf("failed to pick %d decoys after %d tries", requested-1, tries)
+		}
+		idx, ok := picker.Pick()
+		if !ok || seen[idx] {
+			continue
+		}
+		seen[idx] = true
+		picks = append(picks, idx)
+	}
+
+	members, err := src.Outputs(picks)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(members) != len(picks) {
+		return nil, 0, fmt.Errorf("asked %d ring members, got %d", len(picks), len(members))
+	}
+	if outputKey != (Hash{}) && members[0].Key != outputKey {
+		return nil, 0, fmt.Errorf("output %d key %x does not match our output key %x", real, members[0].Key, outputKey)
+	}
+
+	ring := []RingMember{members[0]}
+	keys := map[Hash]bool{members[0].Key: true}
+	candidates := members[1:]
+	picker.rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	for _, m := range candidates {
+		if len(ring) == ringSize {
+			break
+		}
+		if !m.Unlocked || keys[m.Key] {
+			continue
+		}
+		keys[m.Key] = true
+		ring = append(ring, m)
+	}
+	if len(ring) < ringSize {
+		return nil, 0, fmt.Errorf("only %d usable ring members out of %d picks", len(ring), len(picks))
+	}
+
+	slices.SortFunc(ring, func(a, b RingMember) int {
+		return cmp.Compare(a.GlobalIndex, b.GlobalIndex)
+	})
+	realPos := slices.IndexFunc(ring, func(m RingMember) bool { return m.GlobalIndex == real })
+	return ring, realPos, nil
+}
+
+// sampleGamma: Marsaglia and Tsang
+func sampleGamma(r *rand.Rand, k, theta float64) float64 {
+	if k < 1 {
+		return sampleGamma(r, k+1, theta) * math.Pow(r.Float64(), 1.0/k)
+	}
+
+	d := k - 1.0/3.0
+	c := 1.0 / math.Sqrt(9*d)
+
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+
+		if u < 1-0.0331*(x*x)*(x*x) {
+			return d * v * theta
+		}
+		if math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v * theta
+		}
+	}
+}
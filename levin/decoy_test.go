@@ -0,0 +1,253 @@
+package levin
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// На синтетической цепочке с постоянным числом выходов в блоке возраст выбранного
+// выхода (с момента разблокировки) должен иметь распределение
+//
+//	F(t) = G(ln(t+1200)) - G(ln 1200) + G(ln 1200) * min(t/1800, 1),
+//
+// где G — CDF Gamma(19.28, 1/1.61): wallet2 берёт возраст exp(Gamma) секунд, а
+// слишком свежие заменяет равномерным в окне 15 блоков. Проверка —
+// Колмогоров-Смирнов на уровне 0.01.
+func TestGammaPickerDistribution(t *testing.T) {
+	const (
+		blocks   = 3 * 365 * 720
+		perBlock = 60
+		picks    = 100000
+	)
+	if testing.Short() {
+		t.Skip("statistical test")
+	}
+
+	dist := &OutputDistribution{Cumulative: make([]uint64, blocks)}
+	for i := range dist.Cumulative {
+		dist.Cumulative[i] = uint64(i+1) * perBlock
+	}
+	picker, err := NewGammaPicker(dist, rand.New(rand.NewPCG(1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUnlocked := len(dist.Cumulative) - CryptonoteDefaultTxSpendableAge - 1
+
+	ages := make([]float64, 0, picks)
+	for len(ages) < picks {
+		idx, ok := picker.Pick()
+		if !ok {
+			continue
+		}
+		if idx >= picker.NumOutputs() {
+			t.Fatalf("picked locked output %d of %d", idx, picker.NumOutputs())
+		}
+		block := sort.Search(len(dist.Cumulative), func(i int) bool { return dist.Cumulative[i] > idx })
+		// середина блока: внутри блока выход выбирается равномерно
+		ages = append(ages, (float64(lastUnlocked-block)+0.5)*DifficultyTarget)
+	}
+	slices.Sort(ages)
+
+	norm := decoyAgeCDF(float64(picker.NumOutputs()) * picker.AverageOutputTime())
+	var d float64
+	for i, age := range ages {
+		f := decoyAgeCDF(age) / norm
+		d = max(d, math.Abs(float64(i+1)/float64(len(ages))-f), math.Abs(f-float64(i)/float64(len(ages))))
+	}
+	critical := 1.63 / math.Sqrt(float64(len(ages)))
+	t.Logf("KS D = %.5f, critical(0.01) = %.5f", d, critical)
+	if d > critical {
+		t.Fatalf("picks do not follow the wallet2 distribution: D = %.5f > %.5f", d, critical)
+	}
+
+	var recent int
+	for _, age := range ages {
+		if age < recentSpendWindow {
+			recent++
+		}
+	}
+	got, want := float64(recent)/float64(len(ages)), decoyAgeCDF(recentSpendWindow)/norm
+	if math.Abs(got-want) > 0.01 {
+		t.Errorf("share younger than %ds: %.4f, expected %.4f", recentSpendWindow, got, want)
+	}
+}
+
+func decoyAgeCDF(t float64) float64 {
+	g0 := gammaCDF(math.Log(defaultUnlockTime))
+	return gammaCDF(math.Log(t+defaultUnlockTime)) - g0 + g0*min(t/recentSpendWindow, 1)
+}
+
+func gammaCDF(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return regularizedGammaP(DecoyGammaShape, x/DecoyGammaScale)
+}
+
+// regularizedGammaP: P(a, x), ряд при x < a+1, иначе цепная дробь (Numerical Recipes)
+func regularizedGammaP(a, x float64) float64 {
+	lgamma, _ := math.Lgamma(a)
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < 1000; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return sum * math.Exp(-x+a*math.Log(x)-lgamma)
+	}
+
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+	return 1 - math.Exp(-x+a*math.Log(x)-lgamma)*h
+}
+
+/*--- SelectRing ---*/
+
+// badRingSource: часть decoy заблокирована, у части один и тот же ключ
+type badRingSource struct {
+	*FakeRingSource
+	real           uint64
+	locked, shared func(idx uint64) bool
+	sharedKey      Hash
+
+	lockedSeen, sharedSeen int
+}
+
+func (s *badRingSource) Outputs(indices []uint64) ([]RingMember, error) {
+	members, err := s.FakeRingSource.Outputs(indices)
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		idx := members[i].GlobalIndex
+		if idx == s.real {
+			continue
+		}
+		if s.locked(idx) {
+			members[i].Unlocked = false
+			s.lockedSeen++
+		}
+		if s.shared(idx) {
+			members[i].Key = s.sharedKey
+			s.sharedSeen++
+		}
+	}
+	return members, nil
+}
+
+func TestSelectRing(t *testing.T) {
+	const (
+		count    = 200000 * fakeOutputsPerBlock
+		ringSize = DefaultRingSize
+	)
+	real := OwnedOutput{TxHash: Hash{1}, OutputKey: Hash(*RandomScalar().PubKey()), BlockHeight: 150000}
+	fake := NewFakeRingSource(count).Add(real, Hash(*RandomScalar().PubKey()))
+	realIndex, err := fake.GlobalIndex(real.TxHash, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &badRingSource{
+		FakeRingSource: fake,
+		real:           realIndex,
+		locked:         func(idx uint64) bool { return idx%7 == 0 },
+		shared:         func(idx uint64) bool { return idx%7 == 1 },
+		sharedKey:      Hash(*RandomScalar().PubKey()),
+	}
+	dist, err := src.OutputDistribution()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var usedShared bool
+	for seed := uint64(0); seed < 50; seed++ {
+		picker, err := NewGammaPicker(dist, rand.New(rand.NewPCG(seed, 7)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ring, pos, err := SelectRing(picker, src, realIndex, real.OutputKey, ringSize)
+		if err != nil {
+			t.Logf("seed %d: %v", seed, err) // слишком много плохих кандидатов — тоже ответ
+			continue
+		}
+
+		if len(ring) != ringSize {
+			t.Fatalf("seed %d: ring of %d", seed, len(ring))
+		}
+		if pos < 0 || ring[pos].GlobalIndex != realIndex || ring[pos].Key != real.OutputKey {
+			t.Fatalf("seed %d: real output at %d: %+v", seed, pos, ring[max(pos, 0)])
+		}
+		keys := map[Hash]bool{}
+		for i, m := range ring {
+			if i > 0 && ring[i-1].GlobalIndex >= m.GlobalIndex {
+				t.Fatalf("seed %d: ring is not sorted at %d", seed, i)
+			}
+			if !m.Unlocked {
+				t.Fatalf("seed %d: locked member %d", seed, m.GlobalIndex)
+			}
+			if keys[m.Key] {
+				t.Fatalf("seed %d: duplicate key %x", seed, m.Key)
+			}
+			keys[m.Key] = true
+			usedShared = usedShared || m.Key == src.sharedKey
+		}
+	}
+	if src.lockedSeen == 0 || src.sharedSeen < 2 {
+		t.Fatalf("locked %d, shared %d candidates: rejection not exercised", src.lockedSeen, src.sharedSeen)
+	}
+	if !usedShared {
+		t.Error("the first member with a shared key should be kept")
+	}
+}
+
+func TestSelectRingErrors(t *testing.T) {
+	real := OwnedOutput{TxHash: Hash{1}, OutputKey: Hash(*RandomScalar().PubKey())}
+	fake := NewFakeRingSource(100000*fakeOutputsPerBlock).Add(real, Hash{})
+	realIndex, _ := fake.GlobalIndex(real.TxHash, 0)
+	dist, _ := fake.OutputDistribution()
+	picker, err := NewGammaPicker(dist, rand.New(rand.NewPCG(3, 3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := SelectRing(picker, fake, realIndex, Hash{2}, DefaultRingSize); err == nil {
+		t.Error("ring with a wrong real output key")
+	}
+
+	allLocked := &badRingSource{
+		FakeRingSource: fake,
+		real:           realIndex,
+		locked:         func(uint64) bool { return true },
+		shared:         func(uint64) bool { return false },
+	}
+	if _, _, err := SelectRing(picker, allLocked, realIndex, real.OutputKey, DefaultRingSize); err == nil {
+		t.Error("ring from locked decoys")
+	}
+}
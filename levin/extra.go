@@ -0,0 +1,358 @@
+package levin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	TX_EXTRA_TAG_PADDING          = 0x00
+	TX_EXTRA_TAG_PUBKEY           = 0x01
+	TX_EXTRA_NONCE                = 0x02
+	TX_EXTRA_MERGE_MINING_TAG     = 0x03
+	TX_EXTRA_ADDITIONAL_PUBKEYS   = 0x04
+	TX_EXTRA_MINERGATE_TAG        = 0xDE
+	TX_EXTRA_PADDING_MAX_COUNT    = 255
+	TX_EXTRA_NONCE_MAX_COUNT      = 255
+	TX_EXTRA_NONCE_PAYMENT_ID     = 0x00
+	TX_EXTRA_NONCE_ENC_PAYMENT_ID = 0x01
+)
+
+// TxExtra is a parsed tx_extra. Fields are kept in wire order, so Bytes()
+// rebuilds the original extra byte-for-byte.
+type TxExtra struct {
+	Fields []TxExtraField `json:"fields"`
+
+	err error // первая ошибка билдера, возвращается из Bytes()
+}
+
+// TxExtraField is a single tx_extra entry. Only the members matching Tag are set.
+type TxExtraField struct {
+	Tag byte `json:"tag"`
+
+	PubKey            *Hash     `json:"pub_key,omitempty"`             // TX_EXTRA_TAG_PUBKEY
+	Nonce             ByteArray `json:"nonce,omitempty"`               // TX_EXTRA_NONCE
+	MergeMiningDepth  uint64    `json:"depth,omitempty"`               // TX_EXTRA_MERGE_MINING_TAG
+	MerkleRoot        *Hash     `json:"merkle_root,omitempty"`         // TX_EXTRA_MERGE_MINING_TAG
+	AdditionalPubKeys []Hash    `json:"additional_pub_keys,omitempty"` // TX_EXTRA_ADDITIONAL_PUBKEYS
+	PaddingSize       int       `json:"padding_size,omitempty"`        // TX_EXTRA_TAG_PADDING, zero bytes including the tag
+	Data              ByteArray `json:"data,omitempty"`                // TX_EXTRA_MINERGATE_TAG
+
+	// Raw holds the verbatim bytes (tag included) of a malformed or unknown
+	// field. Parsing stops there, since the size of such a field is unknown.
+	Raw ByteArray `json:"raw,omitempty"`
+}
+
+// ParseTxExtra parses every field of tx_extra. Malformed extras are handled
+// tolerantly: everything before the bad field is returned together with an
+// error, and the unparsed remainder is kept in a Raw field.
+func ParseTxExtra(extra []byte) (*TxExtra, error) {
+	e := &TxExtra{}
+	reader := bytes.NewReader(extra)
+
+	for reader.Len() > 0 {
+		start := len(extra) - reader.Len()
+		tag, _ := reader.ReadByte()
+
+		field, err := readTxExtraField(tag, reader)
+		if err != nil {
+			e.Fields = append(e.Fields, TxExtraField{
+				Tag: tag,
+				Raw: ByteArray(append([]byte(nil), extra[start:]...)),
+			})
+			return e, fmt.Errorf("tx extra: offset %d: %w", start, err)
+		}
+		e.Fields = append(e.Fields, field)
+	}
+
+	return e, nil
+}
+
+func readTxExtraField(tag byte, reader *bytes.Reader) (TxExtraField, error) {
+	field := TxExtraField{Tag: tag}
+
+	switch tag {
+	case TX_EXTRA_TAG_PADDING:
+		field.PaddingSize = 1
+		for reader.Len() > 0 {
+			b, _ := reader.ReadByte()
+			if b != 0 {
+				reader.UnreadByte()
+				break
+			}
+			field.PaddingSize++
+		}
+		if field.PaddingSize > TX_EXTRA_PADDING_MAX_COUNT {
+			return field, fmt.Errorf("padding too long: %d", field.PaddingSize)
+		}
+
+	case TX_EXTRA_TAG_PUBKEY:
+		var pk Hash
+		if _, err := readFull(reader, pk[:]); err != nil {
+			return field, errors.New("truncated pubkey")
+		}
+		field.PubKey = &pk
+
+	case TX_EXTRA_NONCE:
+		nonce, err := readExtraString(reader)
+		if err != nil {
+			return field, fmt.Errorf("nonce: %w", err)
+		}
+		if len(nonce) > TX_EXTRA_NONCE_MAX_COUNT {
+			return field, fmt.Errorf("nonce too long: %d", len(nonce))
+		}
+		field.Nonce = nonce
+
+	case TX_EXTRA_MERGE_MINING_TAG:
+		data, err := readExtraString(reader)
+		if err != nil {
+			return field, fmt.Errorf("merge mining tag: %w", err)
+		}
+		inner := bytes.NewReader(data)
+		depth, err := ReadVarint(inner)
+		if err != nil {
+			return field, fmt.Errorf("merge mining tag depth: %w", err)
+		}
+		var root Hash
+		if _, err := readFull(inner, root[:]); err != nil || inner.Len() != 0 {
+			return field, errors.New("merge mining tag: bad merkle root")
+		}
+		field.MergeMiningDepth = depth
+		field.MerkleRoot = &root
+
+	case TX_EXTRA_ADDITIONAL_PUBKEYS:
+		count, err := ReadVarint(reader)
+		if err != nil {
+			return field, fmt.Errorf("additional pubkeys count: %w", err)
+		}
+		if count > uint64(reader.Len()/32) {
+			return field, fmt.Errorf("additional pubkeys truncated: %d keys", count)
+		}
+		field.AdditionalPubKeys = make([]Hash, count)
+		for i := range field.AdditionalPubKeys {
+			readFull(reader, field.AdditionalPubKeys[i][:])
+		}
+
+	case TX_EXTRA_MINERGATE_TAG:
+		data, err := readExtraString(reader)
+		if err != nil {
+			return field, fmt.Errorf("minergate tag: %w", err)
+		}
+		field.Data = data
+
+	default:
+		return field, fmt.Errorf("unknown tag 0x%02x", tag)
+	}
+
+	return field, nil
+}
+
+// readExtraString reads a varint-prefixed byte string
+func readExtraString(reader *bytes.Reader) (ByteArray, error) {
+	size, err := ReadVarint(reader)
+	if err != nil {
+		return nil, errors.New("length missing")
+	}
+	if size > uint64(reader.Len()) {
+		return nil, errors.New("truncated")
+	}
+	data := make([]byte, size)
+	readFull(reader, data)
+	return data, nil
+}
+
+func readFull(reader *bytes.Reader, buf []byte) (int, error) {
+	if reader.Len() < len(buf) {
+		return 0, errors.New("unexpected end of data")
+	}
+	return reader.Read(buf)
+}
+
+// Bytes serializes the extra in field order. The error is the first one
+// met by the builder methods, the extra is not serialized then.
+func (e *TxExtra) Bytes() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	var buf bytes.Buffer
+	for _, f := range e.Fields {
+		buf.Write(f.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+// Bytes serializes a single field, tag included
+func (f *TxExtraField) Bytes() []byte {
+	if f.Raw != nil {
+		return f.Raw
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(f.Tag)
+
+	switch f.Tag {
+	case TX_EXTRA_TAG_PADDING:
+		buf.Write(make([]byte, max(f.PaddingSize-1, 0)))
+	case TX_EXTRA_TAG_PUBKEY:
+		if f.PubKey != nil {
+			buf.Write(f.PubKey[:])
+		}
+	case TX_EXTRA_NONCE:
+		buf.Write(encodeVarint(uint64(len(f.Nonce))))
+		buf.Write(f.Nonce)
+	case TX_EXTRA_MERGE_MINING_TAG:
+		var root Hash
+		if f.MerkleRoot != nil {
+			root = *f.MerkleRoot
+		}
+		data := append(encodeVarint(f.MergeMiningDepth), root[:]...)
+		buf.Write(encodeVarint(uint64(len(data))))
+		buf.Write(data)
+	case TX_EXTRA_ADDITIONAL_PUBKEYS:
+		buf.Write(encodeVarint(uint64(len(f.AdditionalPubKeys))))
+		for _, k := range f.AdditionalPubKeys {
+			buf.Write(k[:])
+		}
+	case TX_EXTRA_MINERGATE_TAG:
+		buf.Write(encodeVarint(uint64(len(f.Data))))
+		buf.Write(f.Data)
+	}
+
+	return buf.Bytes()
+}
+
+/*--- Getters ---*/
+
+// PubKey returns the first tx public key, or nil if there is none
+func (e *TxExtra) PubKey() []byte {
+	for _, f := range e.Fields {
+		if f.Tag == TX_EXTRA_TAG_PUBKEY && f.PubKey != nil {
+			return append([]byte(nil), f.PubKey[:]...)
+		}
+	}
+	return nil
+}
+
+// PubKeys returns every tx public key; some wallets emit more than one
+func (e *TxExtra) PubKeys() [][]byte {
+	var keys [][]byte
+	for _, f := range e.Fields {
+		if f.Tag == TX_EXTRA_TAG_PUBKEY && f.PubKey != nil {
+			keys = append(keys, append([]byte(nil), f.PubKey[:]...))
+		}
+	}
+	return keys
+}
+
+// AdditionalPubKeys returns the per-output public keys used for subaddress outputs
+func (e *TxExtra) AdditionalPubKeys() [][]byte {
+	for _, f := range e.Fields {
+		if f.Tag == TX_EXTRA_ADDITIONAL_PUBKEYS {
+			keys := make([][]byte, len(f.AdditionalPubKeys))
+			for i := range f.AdditionalPubKeys {
+				keys[i] = append([]byte(nil), f.AdditionalPubKeys[i][:]...)
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
+// Nonce returns the payload of the first extra nonce
+func (e *TxExtra) Nonce() []byte {
+	for _, f := range e.Fields {
+		if f.Tag == TX_EXTRA_NONCE {
+			return f.Nonce
+		}
+	}
+	return nil
+}
+
+// PaymentID returns the 32-byte unencrypted (long) payment id, if present
+func (e *TxExtra) PaymentID() []byte {
+	nonce := e.Nonce()
+	if len(nonce) == 1+32 && nonce[0] == TX_EXTRA_NONCE_PAYMENT_ID {
+		return append([]byte(nil), nonce[1:]...)
+	}
+	return nil
+}
+
+// EncryptedPaymentID returns the 8-byte encrypted (short) payment id, if present
+func (e *TxExtra) EncryptedPaymentID() []byte {
+	nonce := e.Nonce()
+	if len(nonce) == 1+8 && nonce[0] == TX_EXTRA_NONCE_ENC_PAYMENT_ID {
+		return append([]byte(nil), nonce[1:]...)
+	}
+	return nil
+}
+
+/*--- Builder ---*/
+
+func NewTxExtra() *TxExtra {
+	return &TxExtra{}
+}
+
+func (e *TxExtra) AddPubKey(pubKey Hash) *TxExtra {
+	e.Fields = append(e.Fields, TxExtraField{Tag: TX_EXTRA_TAG_PUBKEY, PubKey: &pubKey})
+	return e
+}
+
+func (e *TxExtra) AddAdditionalPubKeys(keys []Hash) *TxExtra {
+	e.Fields = append(e.Fields, TxExtraField{
+		Tag:               TX_EXTRA_ADDITIONAL_PUBKEYS,
+		AdditionalPubKeys: append([]Hash(nil), keys...),
+	})
+	return e
+}
+
+// AddNonce appends an arbitrary extra nonce (at most 255 bytes)
+func (e *TxExtra) AddNonce(nonce []byte) *TxExtra {
+	if len(nonce) > TX_EXTRA_NONCE_MAX_COUNT {
+		return e.fail(fmt.Errorf("tx extra: nonce too long: %d", len(nonce)))
+	}
+	e.Fields = append(e.Fields, TxExtraField{Tag: TX_EXTRA_NONCE, Nonce: append(ByteArray(nil), nonce...)})
+	return e
+}
+
+// AddPaymentID appends a nonce with a 32-byte unencrypted payment id
+func (e *TxExtra) AddPaymentID(paymentID [32]byte) *TxExtra {
+	return e.AddNonce(append([]byte{TX_EXTRA_NONCE_PAYMENT_ID}, paymentID[:]...))
+}
+
+// AddEncryptedPaymentID appends a nonce with an 8-byte encrypted payment id
+func (e *TxExtra) AddEncryptedPaymentID(paymentID [8]byte) *TxExtra {
+	return e.AddNonce(append([]byte{TX_EXTRA_NONCE_ENC_PAYMENT_ID}, paymentID[:]...))
+}
+
+func (e *TxExtra) AddMergeMiningTag(depth uint64, merkleRoot Hash) *TxExtra {
+	e.Fields = append(e.Fields, TxExtraField{Tag: TX_EXTRA_MERGE_MINING_TAG, MergeMiningDepth: depth, MerkleRoot: &merkleRoot})
+	return e
+}
+
+func (e *TxExtra) AddMinergateTag(data []byte) *TxExtra {
+	e.Fields = append(e.Fields, TxExtraField{Tag: TX_EXTRA_MINERGATE_TAG, Data: append(ByteArray(nil), data...)})
+	return e
+}
+
+// AddPadding appends size zero bytes (tag included). Padding must be the last field.
+func (e *TxExtra) AddPadding(size int) *TxExtra {
+	if size < 1 || size > TX_EXTRA_PADDING_MAX_COUNT {
+		return e.fail(fmt.Errorf("tx extra: invalid padding size: %d", size))
+	}
+	e.Fields = append(e.Fields, TxExtraField{Tag: TX_EXTRA_TAG_PADDING, PaddingSize: size})
+	return e
+}
+
+// fail запоминает первую ошибку, остальные вызовы цепочки продолжают работать
+func (e *TxExtra) fail(err error) *TxExtra {
+	if e.err == nil {
+		e.err = err
+	}
+	return e
+}
+
+// ParseExtra parses tx.Extra, see ParseTxExtra
+func (tx *Transaction) ParseExtra() (*TxExtra, error) {
+	return ParseTxExtra(tx.Extra)
+}
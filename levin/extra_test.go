@@ -0,0 +1,85 @@
+package levin
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestTxExtraRoundTripDumps(t *testing.T) {
+	check := func(name string, extra []byte) {
+		parsed, err := ParseTxExtra(extra)
+		if err != nil {
+			t.Logf("%s: %v", name, err) // мусор в extra сохраняется в Raw
+		}
+		out, err := parsed.Bytes()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(out, extra) {
+			t.Errorf("%s: extra %x rebuilt as %x", name, extra, out)
+		}
+	}
+	for _, b := range loadTestBlocks(t) {
+		check("miner tx", b.MinerTx.Extra)
+		for _, tx := range b.TXs {
+			check(fmt.Sprintf("tx %x", tx.Hash), tx.Extra)
+		}
+	}
+}
+
+func TestTxExtraBuilder(t *testing.T) {
+	pub := Hash{1}
+	extra, err := NewTxExtra().
+		AddPubKey(pub).
+		AddAdditionalPubKeys([]Hash{{2}, {3}}).
+		AddEncryptedPaymentID([8]byte{4}).
+		AddMergeMiningTag(5, Hash{6}).
+		AddPadding(3).
+		Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseTxExtra(extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.PubKey(), pub[:]) {
+		t.Errorf("pub key %x", parsed.PubKey())
+	}
+	if keys := parsed.AdditionalPubKeys(); len(keys) != 2 || keys[1][0] != 3 {
+		t.Errorf("additional pub keys %x", keys)
+	}
+	if id := parsed.EncryptedPaymentID(); !bytes.Equal(id, []byte{4, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Errorf("encrypted payment id %x", id)
+	}
+	if parsed.PaymentID() != nil {
+		t.Errorf("unexpected long payment id")
+	}
+	if n := len(parsed.Fields); n != 5 || parsed.Fields[4].PaddingSize != 3 {
+		t.Errorf("fields %+v", parsed.Fields)
+	}
+	again, _ := parsed.Bytes()
+	if !bytes.Equal(again, extra) {
+		t.Errorf("extra %x rebuilt as %x", extra, again)
+	}
+}
+
+func TestTxExtraBuilderError(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() *TxExtra
+	}{
+		{"long nonce", func() *TxExtra { return NewTxExtra().AddNonce(make([]byte, 256)).AddPubKey(Hash{}) }},
+		{"zero padding", func() *TxExtra { return NewTxExtra().AddPubKey(Hash{}).AddPadding(0) }},
+		{"long padding", func() *TxExtra { return NewTxExtra().AddPadding(256) }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if extra, err := tc.build().Bytes(); err == nil {
+				t.Fatalf("built %x", extra)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package levin
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+)
+
+// Константы схемы комиссий 2021 scaling (HF v15+), cryptonote_config.h
+const (
+	DynamicFeeReferenceTxWeight             = 3000
+	BlockGrantedFullRewardZoneV5            = 300000
+	BlockWeightShortTermMedianBlocks        = 100
+	FeeQuantizationMask              Amount = 10000
+	TailEmissionReward               Amount = 600_000_000_000
+
+	DefaultRingSize = 16
+)
+
+// FeePriority — уровень комиссии, как priority 1..4 в wallet2
+type FeePriority int
+
+const (
+	FeePriorityLow FeePriority = iota
+	FeePriorityNormal
+	FeePriorityElevated
+	FeePriorityHigh
+)
+
+func (p FeePriority) String() string {
+	switch p {
+	case FeePriorityLow:
+		return "low"
+	case FeePriorityNormal:
+		return "normal"
+	case FeePriorityElevated:
+		return "elevated"
+	case FeePriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// FeeEstimate — комиссия за байт веса по уровням и шаг округления
+type FeeEstimate struct {
+	Fees             [4]Amount `json:"fees"`
+	QuantizationMask Amount    `json:"quantization_mask"`
+}
+
+// Fee: weight * fee за байт, округлённая вверх до QuantizationMask
+func (e FeeEstimate) Fee(weight uint64, priority FeePriority) (Amount, error) {
+	if priority < FeePriorityLow || priority > FeePriorityHigh {
+		return 0, fmt.Errorf("unknown fee priority %d", int(priority))
+	}
+	perByte := e.Fees[priority]
+	if perByte == 0 {
+		return 0, fmt.Errorf("no fee for %s priority", priority)
+	}
+
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(weight), new(big.Int).SetUint64(uint64(perByte)))
+	if mask := new(big.Int).SetUint64(uint64(max(e.QuantizationMask, 1))); mask.Cmp(big.NewInt(1)) > 0 {
+		fee.Add(fee, new(big.Int).Sub(mask, big.NewInt(1)))
+		fee.Div(fee, mask)
+		fee.Mul(fee, mask)
+	}
+	if !fee.IsUint64() {
+		return 0, fmt.Errorf("fee overflows")
+	}
+	return Amount(fee.Uint64()), nil
+}
+
+// DynamicFeeEstimate: уровни комиссии по награде блока и медианам веса
+// (get_dynamic_base_fee_estimate_2021_scaling). Медианы не ниже 300000.
+// Все уровни считаются от 128-битного R*Wref, а не от уже поделённого Fl,
+// и округляются вверх до двух значащих цифр, как round_money_up в monerod.
+func DynamicFeeEstimate(reward Amount, shortMedian, longMedian uint64) FeeEstimate {
+	Mlw := max(longMedian, BlockGrantedFullRewardZoneV5)
+	Mnw := min(max(shortMedian, BlockGrantedFullRewardZoneV5), 50*Mlw)
+	Mfw := min(Mnw, Mlw)
+
+	// 128 бит, как mul128/div128 в monerod
+	div := func(a *big.Int, b uint64) *big.Int {
+		return a.Div(a, new(big.Int).SetUint64(b))
+	}
+	mul := func(a *big.Int, b uint64) *big.Int {
+		return new(big.Int).Mul(a, new(big.Int).SetUint64(b))
+	}
+	rw := mul(new(big.Int).SetUint64(uint64(reward)), DynamicFeeReferenceTxWeight) // R * Wref
+
+	// Fl = R*Wref/Mfw^2, Fm = 16*R*Wref*ZM/Mfw^3, Fp = 4*Fm*Mfw / (32*Wref*Mnw/ZM)
+	Fl := div(div(new(big.Int).Set(rw), Mfw), Mfw).Uint64()
+	Fn := 4 * Fl
+	Fm := div(div(div(mul(rw, 16*BlockGrantedFullRewardZoneV5), Mfw), Mfw), Mfw).Uint64()
+	Fp := div(div(div(mul(rw, 4*16*BlockGrantedFullRewardZoneV5), Mfw), Mfw), 32*DynamicFeeReferenceTxWeight*Mnw/BlockGrantedFullRewardZoneV5).Uint64()
+
+	return FeeEstimate{
+		Fees: [4]Amount{
+			roundMoneyUp(Amount(Fl), feeRoundingPlaces),
+			roundMoneyUp(Amount(Fn), feeRoundingPlaces),
+			roundMoneyUp(Amount(Fm), feeRoundingPlaces),
+			roundMoneyUp(Amount(max(4*Fm, Fp)), feeRoundingPlaces),
+		},
+		QuantizationMask: FeeQuantizationMask,
+	}
+}
+
+// CRYPTONOTE_SCALING_2021_FEE_ROUNDING_PLACES
+const feeRoundingPlaces = 2
+
+// roundMoneyUp: вверх до digits значащих цифр, 1234 -> 1300
+func roundMoneyUp(amount Amount, digits int) Amount {
+	var modulo Amount = 1
+	for a := amount; a >= 10; a /= 10 {
+		if digits > 1 {
+			digits--
+			continue
+		}
+		modulo *= 10
+	}
+	if rem := amount % modulo; rem != 0 {
+		if up, ok := amount.Add(modulo - rem); ok {
+			return up
+		}
+	}
+	return amount
+}
+
+// bppClawback: вес, добавляемый транзакции с Bulletproof+ на 3+ выходах,
+// чтобы агрегированный proof не был дешевле отдельных (get_transaction_weight_clawback)
+func bppClawback(outputs int) uint64 {
+	padded := 1
+	logPadded := 0
+	for padded < outputs {
+		padded <<= 1
+		logPadded++
+	}
+	if padded <= 2 {
+		return 0
+	}
+	const bpBase = 32 * (6 + 7*2) / 2 // proof на 2 выхода, поделённый на 2
+	bpSize := 32 * (6 + 2*(logPadded+6))
+	return uint64(bpBase*padded-bpSize) * 4 / 5
+}
+
+// EstimateTxWeight: верхняя оценка веса CLSAG/BP+ транзакции с view tags до
+// её построения (estimate_rct_tx_size + clawback в wallet2). wallet2 кладёт на
+// key offsets по 2 байта, реальные varint длиннее (~2.6 байта в среднем), и
+// его оценка бывает ниже веса. Здесь первый offset до 5 байт, остальные —
+// разности индексов — до 4: верно, пока выходов RingCT меньше 2^28.
+func EstimateTxWeight(inputs, outputs, ringSize, extraSize int) uint64 {
+	size := 1 + 6 // version, unlock time
+	size += inputs * (1 + 6 + 5 + (ringSize-1)*4 + 32)
+	size += outputs * (6 + 32)
+	size += extraSize
+	size += 1 // rct type
+
+	logPadded := 0
+	for 1<<logPadded < outputs {
+		logPadded++
+	}
+	size += (2*(6+logPadded)+6)*32 + 3  // bpp
+	size += inputs * (32*ringSize + 64) // CLSAG
+	size += 32 * inputs                 // pseudoOuts
+	size += 8 * outputs                 // ecdhInfo
+	size += 32 * outputs                // outPk
+	size += 4                           // fee
+	size += outputs                     // view tags
+
+	return uint64(size) + bppClawback(outputs)
+}
+
+// Weight: вес транзакции — размер blob плюс clawback за Bulletproof+
+func (tx *Transaction) Weight() uint64 {
+	weight := uint64(len(tx.Raw))
+	if tx.Raw == nil || tx.Pruned {
+		weight = uint64(len(tx.Serialize()))
+	}
+	if tx.RctSignature != nil && tx.RctSignature.Type == uint64(RCTTypeBulletproofPlus) {
+		weight += bppClawback(len(tx.Outputs))
+	}
+	return weight
+}
+
+// FeeSource — откуда брать комиссию за байт
+type FeeSource interface {
+	FeeEstimate() (FeeEstimate, error)
+}
+
+// StaticFeeSource: заданные уровни, например из конфига
+type StaticFeeSource FeeEstimate
+
+func (s StaticFeeSource) FeeEstimate() (FeeEstimate, error) {
+	return FeeEstimate(s), nil
+}
+
+/*--- по блокам, которые видит сканер ---*/
+
+// BlockFeeTracker считает уровни комиссии по последним блокам: базовая
+// награда — выходы coinbase минус комиссии, short term медиана — по весам
+// последних 100 блоков. Long term медиана требует 100000 блоков истории,
+// берётся её нижняя граница 300000 — выше сеть пока не поднималась.
+type BlockFeeTracker struct {
+	mu      sync.Mutex
+	weights []uint64
+	reward  Amount
+}
+
+func NewBlockFeeTracker() *BlockFeeTracker {
+	return &BlockFeeTracker{}
+}
+
+// Observe: блоки подаются по порядку
+func (t *BlockFeeTracker) Observe(b *Block) {
+	var reward, fees Amount
+	for _, out := range b.MinerTx.Outs {
+		reward += Amount(out.Amount)
+	}
+	for _, tx := range b.TXs {
+		if tx.RctSignature != nil {
+			fees += Amount(tx.RctSignature.TxnFee)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if reward > fees {
+		t.reward = reward - fees
+	}
+	t.weights = append(t.weights, b.Weight())
+	if len(t.weights) > BlockWeightShortTermMedianBlocks {
+		t.weights = t.weights[len(t.weights)-BlockWeightShortTermMedianBlocks:]
+	}
+}
+
+func (t *BlockFeeTracker) FeeEstimate() (FeeEstimate, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reward == 0 {
+		return FeeEstimate{}, fmt.Errorf("no blocks observed yet")
+	}
+
+	sorted := slices.Clone(t.weights)
+	slices.Sort(sorted)
+	var median uint64
+	if n := len(sorted); n%2 == 1 {
+		median = sorted[n/2]
+	} else {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return DynamicFeeEstimate(t.reward, median, BlockGrantedFullRewardZoneV5), nil
+}
+
+/*--- daemon RPC ---*/
+
+// DaemonFeeSource: get_fee_estimate у демона
+type DaemonFeeSource struct {
+	RPC *DaemonRPC
+}
+
+func (s DaemonFeeSource) FeeEstimate() (FeeEstimate, error) {
+	var resp struct {
+		Fee              uint64   `json:"fee"`
+		Fees             []uint64 `json:"fees"`
+		QuantizationMask uint64   `json:"quantization_mask"`
+		Status           string   `json:"status"`
+	}
+	if err := s.RPC.JSONRPC("get_fee_estimate", nil, &resp); err != nil {
+		return FeeEstimate{}, err
+	}
+	if resp.Status != "OK" {
+		return FeeEstimate{}, fmt.Errorf("get_fee_estimate: status %q", resp.Status)
+	}
+
+	est := FeeEstimate{QuantizationMask: Amount(max(resp.QuantizationMask, 1))}
+	if len(resp.Fees) == len(est.Fees) {
+		for i, fee := range resp.Fees {
+			est.Fees[i] = Amount(fee)
+		}
+	} else {
+		// старые демоны отдают только базовую комиссию
+		for i, m := range []uint64{1, 5, 25, 1000} {
+			est.Fees[i] = Amount(resp.Fee * m)
+		}
+	}
+	return est, nil
+}
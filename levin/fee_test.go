@@ -0,0 +1,123 @@
+package levin
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDynamicFeeEstimate(t *testing.T) {
+	tests := []struct {
+		name        string
+		reward      Amount
+		short, long uint64
+		fees        [4]Amount
+	}{
+		// get_fee_estimate mainnet с tail emission
+		{"mainnet", TailEmissionReward, 300000, 300000, [4]Amount{20000, 80000, 320000, 4000000}},
+		{"medians below zone", TailEmissionReward, 100000, 0, [4]Amount{20000, 80000, 320000, 4000000}},
+		// Fp ниже 4*Fm, 1280000 округляется до 1300000
+		{"short median 50x", TailEmissionReward, 15000000, 300000, [4]Amount{20000, 80000, 320000, 1300000}},
+		{"short median capped", TailEmissionReward, 100000000, 300000, [4]Amount{20000, 80000, 320000, 1300000}},
+		{"both medians 1425000", TailEmissionReward, 1425000, 1425000, [4]Amount{890, 3600, 3000, 38000}},
+		// Fm от уже поделённого Fl дал бы 92000
+		{"Fm from 128 bits", TailEmissionReward, 454536, 454536, [4]Amount{8800, 35000, 93000, 1200000}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			est := DynamicFeeEstimate(tc.reward, tc.short, tc.long)
+			if est.Fees != tc.fees {
+				t.Fatalf("fees %v, expected %v", est.Fees, tc.fees)
+			}
+			if est.QuantizationMask != FeeQuantizationMask {
+				t.Fatalf("quantization mask %d", est.QuantizationMask)
+			}
+		})
+	}
+}
+
+func TestRoundMoneyUp(t *testing.T) {
+	for in, want := range map[Amount]Amount{0: 0, 9: 9, 99: 99, 886: 890, 999: 1000, 1200: 1200, 1234: 1300, 1280000: 1300000} {
+		if got := roundMoneyUp(in, feeRoundingPlaces); got != want {
+			t.Errorf("roundMoneyUp(%d) = %d, expected %d", in, got, want)
+		}
+	}
+}
+
+func TestFeeQuantization(t *testing.T) {
+	est := DynamicFeeEstimate(TailEmissionReward, 300000, 300000)
+	fee, err := est.Fee(1501, FeePriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1501 * 80000 = 120080000, вверх до 10000
+	if fee != 120080000 {
+		t.Fatalf("fee %d", fee)
+	}
+	if _, err := est.Fee(1501, FeePriority(7)); err == nil {
+		t.Fatal("unknown priority accepted")
+	}
+}
+
+func TestBppClawback(t *testing.T) {
+	for outputs, want := range map[int]uint64{1: 0, 2: 0, 3: 460, 4: 460, 5: 1433, 8: 1433, 16: 3430} {
+		if got := bppClawback(outputs); got != want {
+			t.Errorf("clawback(%d) = %d, expected %d", outputs, got, want)
+		}
+	}
+}
+
+// вес транзакций из дампов — то, что отдаёт get_transaction_weight: blob плюс clawback
+func TestTxWeightDumps(t *testing.T) {
+	seen := map[int]bool{}
+	for _, tx := range testTxs(t) {
+		n := len(tx.Outputs)
+		if w := tx.Weight(); w != uint64(len(tx.Raw))+bppClawback(n) {
+			t.Fatalf("tx %x: weight %d, blob %d", tx.Hash, w, len(tx.Raw))
+		}
+		est := EstimateTxWeight(len(tx.Inputs), n, DefaultRingSize, len(tx.Extra))
+		if est < tx.Weight() {
+			t.Fatalf("tx %x (%d in, %d out): estimate %d below weight %d", tx.Hash, len(tx.Inputs), n, est, tx.Weight())
+		}
+		seen[n] = true
+	}
+	for _, n := range []int{2, 3, 16} {
+		if !seen[n] {
+			t.Errorf("no %d-output tx in dumps", n)
+		}
+	}
+}
+
+func TestEstimateTxWeightBuilt(t *testing.T) {
+	for _, destinations := range []int{1, 2, 15} {
+		t.Run(fmt.Sprint(destinations), func(t *testing.T) {
+			full, _, out, commitment := fundedViewOnly(t, 100*XMR)
+			gi := uint64(100000 * fakeOutputsPerBlock)
+			out.GlobalIndex = &gi
+			src := NewFakeRingSource(200000*fakeOutputsPerBlock).Add(out, commitment)
+
+			spendKey, _ := full.SpendKey()
+			b := NewTxBuilder().
+				AddInput(Input{Output: out, SpendKey: spendKey, ViewKey: full.ViewKey()}).
+				SetChangeAddress(full.Address).
+				SetFeeSource(StaticFeeSource(DynamicFeeEstimate(TailEmissionReward, 0, 0)), FeePriorityNormal).
+				SetRingMemberSource(src)
+			for range destinations {
+				dest, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b.AddDestination(dest.Address, XMR)
+			}
+			tx, err := b.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			est := EstimateTxWeight(len(tx.Inputs), len(tx.Outputs), DefaultRingSize, len(tx.Extra))
+			if est < tx.Weight() {
+				t.Fatalf("%d outputs: estimate %d below weight %d", len(tx.Outputs), est, tx.Weight())
+			}
+			t.Logf("%d outputs: weight %d, estimate %d", len(tx.Outputs), tx.Weight(), est)
+		})
+	}
+}
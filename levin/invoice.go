@@ -0,0 +1,306 @@
+package levin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type InvoiceStatus int
+
+const (
+	InvoicePending  InvoiceStatus = iota // ничего не пришло
+	InvoicePartial                       // пришло меньше Amount
+	InvoicePaid                          // ровно Amount
+	InvoiceOverpaid                      // больше Amount
+)
+
+func (s InvoiceStatus) String() string {
+	switch s {
+	case InvoicePending:
+		return "pending"
+	case InvoicePartial:
+		return "partial"
+	case InvoicePaid:
+		return "paid"
+	case InvoiceOverpaid:
+		return "overpaid"
+	}
+	return fmt.Sprintf("InvoiceStatus(%d)", int(s))
+}
+
+func (s InvoiceStatus) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// Invoice — ожидаемый платёж на кошелёк Address. Платёж опознаётся по
+// PaymentID (8 байт для integrated адреса или 32 байта) либо по субадресу.
+// Received и Status учитывают только разблокированные выходы с нужным числом
+// подтверждений, остальные лежат в Pending.
+type Invoice struct {
+	ID             string           `json:"id"`
+	Address        string           `json:"address"` // основной адрес Account
+	PaymentID      ByteArray        `json:"payment_id,omitempty"`
+	Subaddress     *SubaddressIndex `json:"subaddress,omitempty"`
+	Amount         Amount           `json:"amount"` // 0 — любая сумма
+	Received       Amount           `json:"received"`
+	Outputs        []OwnedOutput    `json:"outputs,omitempty"`
+	Pending        Amount           `json:"pending"` // видно в блоке, но заблокировано или мало подтверждений
+	PendingOutputs []OwnedOutput    `json:"pending_outputs,omitempty"`
+	Status         InvoiceStatus    `json:"status"`
+}
+
+// InvoiceUpdate — состояние счёта после того, как Output найден (Settled false)
+// или зачислен в Received (Settled true)
+type InvoiceUpdate struct {
+	Invoice   Invoice     `json:"invoice"`
+	Output    OwnedOutput `json:"output"`
+	Settled   bool        `json:"settled"`
+	Shortfall Amount      `json:"shortfall,omitempty"` // недоплата
+	Excess    Amount      `json:"excess,omitempty"`    // переплата
+}
+
+// DefaultInvoiceConfirmations: как CryptonoteDefaultTxSpendableAge, раньше выход всё равно не потратить
+const DefaultInvoiceConfirmations = CryptonoteDefaultTxSpendableAge
+
+type subaddressKey struct {
+	address string
+	index   SubaddressIndex
+}
+
+// Reconciler сопоставляет входящие OwnedOutput с открытыми счетами. Выходы
+// должны приходить от Watcher: он отбрасывает выходы, чья сумма не сходится
+// с коммитментом, так что суммы здесь уже проверены.
+type Reconciler struct {
+	mu                    sync.Mutex
+	invoices              map[string]*Invoice
+	byPaymentID           map[string]*Invoice // address + hex(pid)
+	bySubaddress          map[subaddressKey]*Invoice
+	credited              map[outpoint]string // выход уже привязан к счёту (в Outputs или PendingOutputs)
+	requiredConfirmations uint64
+}
+
+func NewReconciler() *Reconciler {
+	return &Reconciler{
+		invoices:              make(map[string]*Invoice),
+		byPaymentID:           make(map[string]*Invoice),
+		bySubaddress:          make(map[subaddressKey]*Invoice),
+		credited:              make(map[outpoint]string),
+		requiredConfirmations: DefaultInvoiceConfirmations,
+	}
+}
+
+// SetRequiredConfirmations: сколько подтверждений нужно, чтобы выход зачёлся в Received
+func (r *Reconciler) SetRequiredConfirmations(n uint64) *Reconciler {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requiredConfirmations = max(n, 1)
+	return r
+}
+
+func paymentIDKey(address string, pid []byte) string {
+	return address + ":" + hex.EncodeToString(pid)
+}
+
+// нулевой short pid кошельки добавляют как заглушку, по нему не матчим
+func isDummyPaymentID(pid []byte) bool {
+	return len(pid) == 0 || bytes.Equal(pid, make([]byte, len(pid)))
+}
+
+// AddInvoice регистрирует счёт. Received/Outputs и Pending/PendingOutputs
+// переносятся как есть, чтобы можно было восстановить состояние из базы.
+func (r *Reconciler) AddInvoice(inv Invoice) error {
+	if inv.ID == "" {
+		return fmt.Errorf("invoice id is empty")
+	}
+	if (inv.PaymentID == nil) == (inv.Subaddress == nil) {
+		return fmt.Errorf("invoice %s: exactly one of payment id or subaddress is required", inv.ID)
+	}
+	if inv.PaymentID != nil {
+		if len(inv.PaymentID) != 8 && len(inv.PaymentID) != 32 {
+			return fmt.Errorf("invoice %s: payment id must be 8 or 32 bytes, got %d", inv.ID, len(inv.PaymentID))
+		}
+		if isDummyPaymentID(inv.PaymentID) {
+			return fmt.Errorf("invoice %s: zero payment id", inv.ID)
+		}
+	}
+	if inv.Subaddress != nil && *inv.Subaddress == (SubaddressIndex{}) {
+		return fmt.Errorf("invoice %s: main address needs a payment id", inv.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.invoices[inv.ID]; ok {
+		return fmt.Errorf("invoice %s already exists", inv.ID)
+	}
+	var pidKey string
+	var subKey subaddressKey
+	if inv.PaymentID != nil {
+		pidKey = paymentIDKey(inv.Address, inv.PaymentID)
+		if other, ok := r.byPaymentID[pidKey]; ok {
+			return fmt.Errorf("invoice %s: payment id already used by %s", inv.ID, other.ID)
+		}
+	} else {
+		subKey = subaddressKey{inv.Address, *inv.Subaddress}
+		if other, ok := r.bySubaddress[subKey]; ok {
+			return fmt.Errorf("invoice %s: subaddress already used by %s", inv.ID, other.ID)
+		}
+	}
+
+	stored := inv
+	stored.PaymentID = append(ByteArray(nil), inv.PaymentID...)
+	stored.Outputs = append([]OwnedOutput(nil), inv.Outputs...)
+	stored.PendingOutputs = append([]OwnedOutput(nil), inv.PendingOutputs...)
+	stored.Status = invoiceStatus(stored.Amount, stored.Received)
+
+	r.invoices[inv.ID] = &stored
+	if inv.PaymentID != nil {
+		r.byPaymentID[pidKey] = &stored
+	} else {
+		r.bySubaddress[subKey] = &stored
+	}
+	for _, o := range append(stored.Outputs, stored.PendingOutputs...) {
+		r.credited[outpoint{o.TxHash, o.OutputIndex}] = inv.ID
+	}
+	return nil
+}
+
+// CloseInvoice: выходы по закрытому счёту больше не матчатся
+func (r *Reconciler) CloseInvoice(id string) (Invoice, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, ok := r.invoices[id]
+	if !ok {
+		return Invoice{}, false
+	}
+	delete(r.invoices, id)
+	if inv.PaymentID != nil {
+		delete(r.byPaymentID, paymentIDKey(inv.Address, inv.PaymentID))
+	} else {
+		delete(r.bySubaddress, subaddressKey{inv.Address, *inv.Subaddress})
+	}
+	for _, o := range append(inv.Outputs, inv.PendingOutputs...) {
+		delete(r.credited, outpoint{o.TxHash, o.OutputIndex})
+	}
+	return inv.snapshot(), true
+}
+
+func (r *Reconciler) Invoice(id string) (Invoice, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, ok := r.invoices[id]
+	if !ok {
+		return Invoice{}, false
+	}
+	return inv.snapshot(), true
+}
+
+// Match привязывает выходы к счетам: сначала по payment id, затем по субадресу.
+// Выходы попадают в Pending, в Received их переносит Advance. Повторно
+// переданный выход (тот же tx:index) игнорируется.
+func (r *Reconciler) Match(outputs []OwnedOutput) (updates []InvoiceUpdate, unmatched []OwnedOutput) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, o := range outputs {
+		op := outpoint{o.TxHash, o.OutputIndex}
+		if _, ok := r.credited[op]; ok {
+			continue
+		}
+
+		inv := r.lookup(o)
+		if inv == nil {
+			unmatched = append(unmatched, o)
+			continue
+		}
+
+		inv.Pending += o.Amount
+		inv.PendingOutputs = append(inv.PendingOutputs, o)
+		r.credited[op] = inv.ID
+		updates = append(updates, InvoiceUpdate{Invoice: inv.snapshot(), Output: o})
+	}
+	return
+}
+
+// Advance зачисляет в Received выходы, которые на tip разблокированы (IsUnlocked)
+// и набрали требуемые подтверждения. tipTime — timestamp блока tipHeight.
+func (r *Reconciler) Advance(tipHeight, tipTime uint64) []InvoiceUpdate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var updates []InvoiceUpdate
+	for _, inv := range r.invoices {
+		var ready, pending []OwnedOutput
+		for _, o := range inv.PendingOutputs {
+			if Confirmations(o.BlockHeight, tipHeight) >= r.requiredConfirmations && IsUnlocked(&o, tipHeight, tipTime) {
+				ready = append(ready, o)
+			} else {
+				pending = append(pending, o)
+			}
+		}
+		if len(ready) == 0 {
+			continue
+		}
+		inv.PendingOutputs = pending
+
+		for _, o := range ready {
+			inv.Pending -= o.Amount
+			inv.Received += o.Amount
+			inv.Outputs = append(inv.Outputs, o)
+			inv.Status = invoiceStatus(inv.Amount, inv.Received)
+
+			update := InvoiceUpdate{Invoice: inv.snapshot(), Output: o, Settled: true}
+			switch inv.Status {
+			case InvoicePartial:
+				update.Shortfall = inv.Amount - inv.Received
+			case InvoiceOverpaid:
+				update.Excess = inv.Received - inv.Amount
+			}
+			updates = append(updates, update)
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		a, b := &updates[i].Output, &updates[j].Output
+		if a.BlockHeight != b.BlockHeight {
+			return a.BlockHeight < b.BlockHeight
+		}
+		if c := bytes.Compare(a.TxHash[:], b.TxHash[:]); c != 0 {
+			return c < 0
+		}
+		return a.OutputIndex < b.OutputIndex
+	})
+	return updates
+}
+
+func (r *Reconciler) lookup(o OwnedOutput) *Invoice {
+	if !isDummyPaymentID(o.PaymentID) {
+		if inv, ok := r.byPaymentID[paymentIDKey(o.Address, o.PaymentID)]; ok {
+			return inv
+		}
+	}
+	return r.bySubaddress[subaddressKey{o.Address, o.Subaddress}]
+}
+
+func invoiceStatus(amount, received Amount) InvoiceStatus {
+	switch {
+	case received == 0:
+		return InvoicePending
+	case amount == 0 || received == amount:
+		return InvoicePaid
+	case received < amount:
+		return InvoicePartial
+	}
+	return InvoiceOverpaid
+}
+
+func (inv *Invoice) snapshot() Invoice {
+	c := *inv
+	c.Outputs = append([]OwnedOutput(nil), inv.Outputs...)
+	c.PendingOutputs = append([]OwnedOutput(nil), inv.PendingOutputs...)
+	return c
+}
@@ -0,0 +1,46 @@
+package levin
+
+import "testing"
+
+func TestReconcilerSettlesUnlockedOutputs(t *testing.T) {
+	const height = 1000
+	sub := SubaddressIndex{Major: 0, Minor: 1}
+	r := NewReconciler()
+	if err := r.AddInvoice(Invoice{ID: "a", Address: testAddress, Subaddress: &sub, Amount: 2 * XMR}); err != nil {
+		t.Fatal(err)
+	}
+
+	normal := OwnedOutput{Address: testAddress, TxHash: Hash{1}, Amount: XMR, BlockHeight: height, Subaddress: sub}
+	locked := OwnedOutput{Address: testAddress, TxHash: Hash{2}, Amount: XMR, BlockHeight: height, Subaddress: sub, UnlockTime: height + 100}
+
+	updates, unmatched := r.Match([]OwnedOutput{normal, locked})
+	if len(updates) != 2 || len(unmatched) != 0 {
+		t.Fatalf("updates %d, unmatched %d", len(updates), len(unmatched))
+	}
+	inv, _ := r.Invoice("a")
+	if inv.Status != InvoicePending || inv.Received != 0 || inv.Pending != 2*XMR {
+		t.Fatalf("0-conf: %s, received %s, pending %s", inv.Status, inv.Received, inv.Pending)
+	}
+
+	// девять подтверждений — ещё рано
+	if updates := r.Advance(height+8, 0); len(updates) != 0 {
+		t.Fatalf("settled with 9 confirmations: %+v", updates)
+	}
+
+	updates = r.Advance(height+9, 0)
+	if len(updates) != 1 || !updates[0].Settled || updates[0].Output.TxHash != normal.TxHash {
+		t.Fatalf("10 confirmations: %+v", updates)
+	}
+	if u := updates[0]; u.Invoice.Status != InvoicePartial || u.Shortfall != XMR || u.Invoice.Pending != XMR {
+		t.Fatalf("partial: %s, shortfall %s, pending %s", u.Invoice.Status, u.Shortfall, u.Invoice.Pending)
+	}
+
+	// unlock_time держит второй выход
+	if updates := r.Advance(height+50, 0); len(updates) != 0 {
+		t.Fatalf("locked output settled: %+v", updates)
+	}
+	updates = r.Advance(height+99, 0)
+	if len(updates) != 1 || updates[0].Invoice.Status != InvoicePaid || updates[0].Invoice.Pending != 0 {
+		t.Fatalf("unlock: %+v", updates)
+	}
+}
@@ -0,0 +1,99 @@
+package levin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+const (
+	mnemonicWords        = 24 // без контрольного слова
+	mnemonicPrefixLength = 3  // для английского словаря
+)
+
+// englishPrefixes: первые три буквы -> индекс слова. Как и wallet2, слово
+// узнаётся по префиксу, так что опечатки в хвосте не мешают.
+var englishPrefixes = func() map[string]int {
+	m := make(map[string]int, len(englishWordlist))
+	for i, w := range englishWordlist {
+		m[w[:mnemonicPrefixLength]] = i
+	}
+	return m
+}()
+
+// EncodeMnemonic: 25 слов (Electrum-style) для приватного spend key
+func EncodeMnemonic(key Key) string {
+	n := uint32(len(englishWordlist))
+
+	words := make([]string, 0, mnemonicWords+1)
+	for i := 0; i < KeyLength/4; i++ {
+		val := binary.LittleEndian.Uint32(key[i*4:])
+		w1 := val % n
+		w2 := (val/n + w1) % n
+		w3 := (val/n/n + w2) % n
+		words = append(words, englishWordlist[w1], englishWordlist[w2], englishWordlist[w3])
+	}
+	words = append(words, words[mnemonicChecksumIndex(words)])
+	return strings.Join(words, " ")
+}
+
+// DecodeMnemonic: 25 слов (с проверкой контрольного) или 24
+func DecodeMnemonic(mnemonic string) (Key, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) != mnemonicWords && len(words) != mnemonicWords+1 {
+		return Key{}, fmt.Errorf("mnemonic must have 24 or 25 words, got %d", len(words))
+	}
+
+	indices := make([]uint32, mnemonicWords)
+	for i, w := range words[:mnemonicWords] {
+		idx, ok := lookupMnemonicWord(w)
+		if !ok {
+			return Key{}, fmt.Errorf("unknown mnemonic word %q", w)
+		}
+		indices[i] = idx
+	}
+
+	if len(words) == mnemonicWords+1 {
+		expected := words[mnemonicChecksumIndex(words[:mnemonicWords])]
+		if !strings.HasPrefix(words[mnemonicWords], expected[:mnemonicPrefixLength]) {
+			return Key{}, fmt.Errorf("invalid mnemonic checksum word")
+		}
+	}
+
+	n := uint32(len(englishWordlist))
+	var key Key
+	for i := 0; i < KeyLength/4; i++ {
+		w1, w2, w3 := indices[i*3], indices[i*3+1], indices[i*3+2]
+		val := w1 + n*((n-w1+w2)%n) + n*n*((n-w2+w3)%n)
+		if val%n != w1 {
+			return Key{}, fmt.Errorf("invalid mnemonic words at %d", i*3)
+		}
+		binary.LittleEndian.PutUint32(key[i*4:], val)
+	}
+	return key, nil
+}
+
+func lookupMnemonicWord(w string) (uint32, bool) {
+	if len(w) < mnemonicPrefixLength {
+		return 0, false
+	}
+	idx, ok := englishPrefixes[w[:mnemonicPrefixLength]]
+	return uint32(idx), ok
+}
+
+// mnemonicChecksumIndex: crc32 префиксов 24 слов по модулю 24
+func mnemonicChecksumIndex(words []string) int {
+	var sb strings.Builder
+	for _, w := range words {
+		sb.WriteString(w[:min(len(w), mnemonicPrefixLength)])
+	}
+	return int(crc32.ChecksumIEEE([]byte(sb.String())) % uint32(len(words)))
+}
+
+// ViewKeyFromSpendKey: a = sc_reduce32(H(b)), как у wallet2 для обычного кошелька
+func ViewKeyFromSpendKey(spendKey Key) Key {
+	view := Key(keccak256(spendKey[:]))
+	ScReduce32(&view)
+	return view
+}
@@ -0,0 +1,84 @@
+package levin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMnemonicZeroKey(t *testing.T) {
+	want := strings.TrimSpace(strings.Repeat("abbey ", 25))
+	if got := EncodeMnemonic(Key{}); got != want {
+		t.Fatalf("zero key: %q", got)
+	}
+	key, err := DecodeMnemonic(want)
+	if err != nil || key != (Key{}) {
+		t.Fatalf("decode: %x, %v", key, err)
+	}
+}
+
+func TestMnemonicRoundTrip(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		key := *RandomScalar()
+		words := EncodeMnemonic(key)
+		if n := len(strings.Fields(words)); n != 25 {
+			t.Fatalf("%d words", n)
+		}
+
+		decoded, err := DecodeMnemonic(words)
+		if err != nil || decoded != key {
+			t.Fatalf("%q: %x, %v", words, decoded, err)
+		}
+
+		// без контрольного слова, по префиксам и в верхнем регистре — тот же ключ
+		fields := strings.Fields(words)
+		prefixes := make([]string, 24)
+		for j, w := range fields[:24] {
+			prefixes[j] = strings.ToUpper(w[:mnemonicPrefixLength])
+		}
+		if decoded, err := DecodeMnemonic(strings.Join(prefixes, " ")); err != nil || decoded != key {
+			t.Fatalf("24 prefixes: %x, %v", decoded, err)
+		}
+	}
+}
+
+func TestMnemonicErrors(t *testing.T) {
+	words := strings.Fields(EncodeMnemonic(*RandomScalar()))
+
+	badChecksum := append([]string(nil), words...)
+	for _, w := range englishWordlist {
+		if w[:mnemonicPrefixLength] != words[24][:mnemonicPrefixLength] {
+			badChecksum[24] = w
+			break
+		}
+	}
+	unknown := append([]string(nil), words...)
+	unknown[3] = "xyzzy"
+
+	for name, m := range map[string]string{
+		"checksum": strings.Join(badChecksum, " "),
+		"unknown":  strings.Join(unknown, " "),
+		"short":    strings.Join(words[:23], " "),
+	} {
+		if _, err := DecodeMnemonic(m); err == nil {
+			t.Errorf("%s: decoded", name)
+		}
+	}
+}
+
+func TestAccountFromMnemonic(t *testing.T) {
+	spend := *RandomScalar()
+	a, err := NewAccountFromMnemonic(EncodeMnemonic(spend), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ViewKey() != ViewKeyFromSpendKey(spend) {
+		t.Fatal("view key is not derived from the spend key")
+	}
+	if words, err := a.Mnemonic(); err != nil || words != EncodeMnemonic(spend) {
+		t.Fatalf("mnemonic %q, %v", words, err)
+	}
+	view := a.ViewKey()
+	if _, err := NewAccount(a.Address, view.String()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+package levin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// OutputCounters — число выходов в цепочке по каждой сумме после блока Height.
+// Все RingCT выходы (и coinbase v2) идут под amount 0, у v1 — открытая сумма.
+type OutputCounters struct {
+	Height uint64            `json:"height"`
+	Hash   string            `json:"hash"`
+	Counts map[uint64]uint64 `json:"counts"`
+}
+
+// GlobalIndices: глобальные индексы выходов по хэшу транзакции (и miner tx)
+type GlobalIndices map[Hash][]uint64
+
+// OutputIndexer ведёт счётчики, пока блоки идут строго по порядку
+type OutputIndexer struct {
+	mu sync.Mutex
+	c  OutputCounters
+}
+
+func NewOutputIndexer(c OutputCounters) *OutputIndexer {
+	counts := maps.Clone(c.Counts)
+	if counts == nil {
+		counts = make(map[uint64]uint64)
+	}
+	c.Counts = counts
+	return &OutputIndexer{c: c}
+}
+
+// Counters: копия текущего состояния, для сохранения вместе с tip
+func (ix *OutputIndexer) Counters() OutputCounters {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	c := ix.c
+	c.Counts = maps.Clone(ix.c.Counts)
+	return c
+}
+
+// IndexBlocks раздаёт индексы выходам блоков. Блоки должны продолжать
+// Counters().Hash; при ошибке счётчики не меняются.
+func (ix *OutputIndexer) IndexBlocks(blocks []*Block) (GlobalIndices, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	height, hash := ix.c.Height, ix.c.Hash
+	counts := maps.Clone(ix.c.Counts)
+	indices := make(GlobalIndices)
+
+	assign := func(txHash Hash, version uint64, outputs []TxOutput) {
+		idx := make([]uint64, len(outputs))
+		for i, out := range outputs {
+			amount := out.Amount
+			if version >= 2 {
+				amount = 0 // coinbase v2 хранится как RingCT выход с открытой маской
+			}
+			idx[i] = counts[amount]
+			counts[amount]++
+		}
+		indices[txHash] = idx
+	}
+
+	for _, b := range blocks {
+		if b.BlockHeight != height+1 || hex.EncodeToString(b.PreviousBlockHash[:]) != hash {
+			return nil, fmt.Errorf("block %d does not continue output counters at %d (%s)", b.BlockHeight, height, hash)
+		}
+
+		assign(Hash(b.CalculateMinerTxHash()), b.MinerTx.Version, b.MinerTx.Outs)
+		for _, tx := range b.TXs {
+			if tx.Raw == nil {
+				return nil, fmt.Errorf("block %d: tx %x without blob, outputs can't be counted", b.BlockHeight, tx.Hash)
+			}
+			if tx.Outputs == nil {
+				tx.ParseTx()
+				tx.ParseRctSig()
+			}
+			assign(tx.Hash, tx.Version, tx.Outputs)
+		}
+		height, hash = b.BlockHeight, b.GetBlockId()
+	}
+
+	ix.c = OutputCounters{Height: height, Hash: hash, Counts: counts}
+	return indices, nil
+}
+
+// OutputCounters: счётчики после блока height у демона. Берётся только amount 0:
+// с RingCT выходов с открытой суммой в новых блоках нет, а v2 coinbase тоже идёт под 0.
+func (d *DaemonRPC) OutputCounters(height uint64) (*OutputCounters, error) {
+	var header struct {
+		BlockHeader struct {
+			Hash   string `json:"hash"`
+			Height uint64 `json:"height"`
+		} `json:"block_header"`
+		Status string `json:"status"`
+	}
+	if err := d.JSONRPC("get_block_header_by_height", map[string]any{"height": height}, &header); err != nil {
+		return nil, err
+	}
+	if header.Status != "OK" || header.BlockHeader.Height != height {
+		return nil, fmt.Errorf("get_block_header_by_height %d: status %q, height %d", height, header.Status, header.BlockHeader.Height)
+	}
+
+	var resp struct {
+		Distributions []struct {
+			StartHeight  uint64   `json:"start_height"`
+			Distribution []uint64 `json:"distribution"`
+		} `json:"distributions"`
+		Status string `json:"status"`
+	}
+	params := map[string]any{
+		"amounts":     []uint64{0},
+		"from_height": height,
+		"to_height":   height,
+		"cumulative":  true,
+		"binary":      false,
+		"compress":    false,
+	}
+	if err := d.JSONRPC("get_output_distribution", params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "OK" || len(resp.Distributions) == 0 || len(resp.Distributions[0].Distribution) == 0 {
+		return nil, fmt.Errorf("get_output_distribution: status %q, no distribution", resp.Status)
+	}
+	dist := resp.Distributions[0]
+	if dist.StartHeight != height {
+		return nil, fmt.Errorf("get_output_distribution: asked height %d, got %d", height, dist.StartHeight)
+	}
+
+	return &OutputCounters{
+		Height: height,
+		Hash:   header.BlockHeader.Hash,
+		Counts: map[uint64]uint64{0: dist.Distribution[0]},
+	}, nil
+}
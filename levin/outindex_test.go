@@ -0,0 +1,82 @@
+package levin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// с нулевыми счётчиками первый выход получает индекс 0, и это известный индекс
+func TestOutputIndexerZeroIndex(t *testing.T) {
+	b := loadTestBlocks(t)[0]
+	ix := NewOutputIndexer(OutputCounters{
+		Height: b.BlockHeight - 1,
+		Hash:   hex.EncodeToString(b.PreviousBlockHash[:]),
+		Counts: map[uint64]uint64{0: 0},
+	})
+	indices, err := ix.IndexBlocks([]*Block{b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := indices[Hash(b.CalculateMinerTxHash())]; len(got) == 0 || got[0] != 0 {
+		t.Fatalf("miner tx indices %v", got)
+	}
+
+	a, err := NewAccount(testAddress, testViewKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetSubaddressLookahead(0, 0)
+	w := NewWatcher()
+	w.AddAccount(a)
+	owned, _ := w.ScanIndexedBlocks([]*Block{b}, indices)
+	for _, o := range owned {
+		if o.GlobalIndex == nil {
+			t.Fatalf("output %x:%d without global index", o.TxHash, o.OutputIndex)
+		}
+	}
+
+	// тот же блок счётчики уже не продолжает
+	if _, err := ix.IndexBlocks([]*Block{b}); err == nil {
+		t.Fatal("block indexed twice")
+	}
+}
+
+func TestDaemonOutputCounters(t *testing.T) {
+	const height = 3491125
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		var result any
+		switch req.Method {
+		case "get_block_header_by_height":
+			result = map[string]any{"status": "OK", "block_header": map[string]any{"height": height, "hash": "48151e"}}
+		case "get_output_distribution":
+			if req.Params["from_height"] != float64(height) || req.Params["to_height"] != float64(height) || req.Params["cumulative"] != true {
+				t.Errorf("params %v", req.Params)
+			}
+			result = map[string]any{"status": "OK", "distributions": []any{
+				map[string]any{"amount": 0, "start_height": height, "base": 0, "distribution": []uint64{123456789}},
+			}}
+		default:
+			t.Errorf("method %s", req.Method)
+		}
+		json.NewEncoder(rw).Encode(map[string]any{"jsonrpc": "2.0", "id": "0", "result": result})
+	}))
+	defer srv.Close()
+
+	c, err := NewDaemonRPC(srv.URL).OutputCounters(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Height != height || c.Hash != "48151e" || c.Counts[0] != 123456789 {
+		t.Fatalf("counters %+v", c)
+	}
+}
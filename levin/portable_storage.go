@@ -69,6 +69,15 @@ func (e Entry) Uint64() uint64 {
 	return v
 }
 
+func (e Entry) Bool() bool {
+	v, ok := e.Value.(bool)
+	if !ok {
+		panic(fmt.Errorf("interface couldnt be casted to bool"))
+	}
+
+	return v
+}
+
 func (e Entry) Entries() Entries {
 	v, ok := e.Value.(Entries)
 	if !ok {
@@ -112,6 +121,12 @@ func (e Entry) Bytes() []byte {
 		binary.LittleEndian.PutUint64(b, uint64(v))
 		return append(result, b...)
 
+	case bool:
+		if v {
+			return []byte{BoostSerializeTypeBool, 0x01}
+		}
+		return []byte{BoostSerializeTypeBool, 0x00}
+
 	case string:
 		result := []byte{BoostSerializeTypeString}
 		varInB, err := VarIn(len(v))
@@ -367,6 +382,14 @@ func ReadAny(bytes []byte, ttype byte) (int, interface{}) {
 		return idx, int64(obj)
 	}
 
+	if ttype == BoostSerializeTypeBool {
+		obj := bytes[idx] != 0
+		n += 1
+		idx += n
+
+		return idx, obj
+	}
+
 	if ttype == BoostSerializeTypeString {
 		n, obj := ReadString(bytes[idx:])
 		idx += n
@@ -0,0 +1,400 @@
+package levin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+)
+
+// RingMember — выход цепочки, который может стоять в кольце: ключ и коммитмент
+type RingMember struct {
+	GlobalIndex uint64 `json:"global_index"`
+	Key         Hash   `json:"key"`
+	Mask        Hash   `json:"mask"` // коммитмент C = xG + aH
+	Height      uint64 `json:"height"`
+	Unlocked    bool   `json:"unlocked"`
+}
+
+// RingMemberSource — откуда брать выходы для колец. Все индексы — RingCT (amount 0).
+type RingMemberSource interface {
+	// OutputDistribution: кумулятивное число выходов по блокам до вершины цепочки
+	OutputDistribution() (*OutputDistribution, error)
+	// GlobalIndex: глобальный индекс выхода outputIndex транзакции txHash
+	GlobalIndex(txHash Hash, outputIndex uint64) (uint64, error)
+	// Outputs: выходы в порядке indices
+	Outputs(indices []uint64) ([]RingMember, error)
+}
+
+/*--- daemon RPC ---*/
+
+// DaemonRingSource: get_transactions, get_output_distribution и get_outs у демона.
+// Распределение (~3 млн блоков) скачивается один раз и дальше только догружается.
+type DaemonRingSource struct {
+	RPC *DaemonRPC
+
+	mu   sync.Mutex
+	dist OutputDistribution
+}
+
+func NewDaemonRingSource(rpc *DaemonRPC) *DaemonRingSource {
+	return &DaemonRingSource{RPC: rpc}
+}
+
+func (s *DaemonRingSource) OutputDistribution() (*OutputDistribution, error) {
+	var h struct {
+		Height uint64 `json:"height"`
+		Status string `json:"status"`
+	}
+	if err := s.RPC.Call("/get_height", map[string]any{}, &h); err != nil {
+		return nil, err
+	}
+	if h.Status != "OK" || h.Height == 0 {
+		return nil, fmt.Errorf("get_height: status %q, height %d", h.Status, h.Height)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// последние блоки перезапрашиваем на случай реорганизации
+	cached := uint64(len(s.dist.Cumulative))
+	from := cached - min(cached, CryptonoteDefaultTxSpendableAge)
+	if cached == 0 || from < h.Height {
+		var resp struct {
+			Distributions []struct {
+				StartHeight  uint64   `json:"start_height"`
+				Base         uint64   `json:"base"`
+				Distribution []uint64 `json:"distribution"`
+			} `json:"distributions"`
+			Status string `json:"status"`
+		}
+		params := map[string]any{
+			"amounts":     []uint64{0},
+			"from_height": from,
+			"to_height":   h.Height - 1,
+			"cumulative":  true,
+			"binary":      false,
+			"compress":    false,
+		}
+		if err := s.RPC.JSONRPC("get_output_distribution", params, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Status != "OK" || len(resp.Distributions) == 0 {
+			return nil, fmt.Errorf("get_output_distribution: status %q, no distribution", resp.Status)
+		}
+		d := resp.Distributions[0]
+		if d.StartHeight != from {
+			return nil, fmt.Errorf("get_output_distribution: asked from height %d, got %d", from, d.StartHeight)
+		}
+		s.dist.Cumulative = append(s.dist.Cumulative[:from], d.Distribution...)
+	}
+
+	// копия: следующий вызов может дописать в тот же массив
+	return &OutputDistribution{Cumulative: slices.Clone(s.dist.Cumulative)}, nil
+}
+
+func (s *DaemonRingSource) GlobalIndex(txHash Hash, outputIndex uint64) (uint64, error) {
+	req := map[string]any{
+		"txs_hashes":     []string{hex.EncodeToString(txHash[:])},
+		"decode_as_json": false,
+	}
+	var resp struct {
+		Txs []struct {
+			OutputIndices []uint64 `json:"output_indices"`
+		} `json:"txs"`
+		MissedTx []string `json:"missed_tx"`
+		Status   string   `json:"status"`
+	}
+	if err := s.RPC.Call("/get_transactions", req, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Status != "OK" || len(resp.Txs) == 0 {
+		return 0, fmt.Errorf("get_transactions: tx %x not found (status %q)", txHash, resp.Status)
+	}
+	indices := resp.Txs[0].OutputIndices
+	if outputIndex >= uint64(len(indices)) {
+		return 0, fmt.Errorf("get_transactions: tx %x has %d output indices, want %d", txHash, len(indices), outputIndex)
+	}
+	return indices[outputIndex], nil
+}
+
+func (s *DaemonRingSource) Outputs(indices []uint64) ([]RingMember, error) {
+	type getOut struct {
+		Amount uint64 `json:"amount"`
+		Index  uint64 `json:"index"`
+	}
+	req := struct {
+		Outputs []getOut `json:"outputs"`
+		GetTxid bool     `json:"get_txid"`
+	}{}
+	for _, idx := range indices {
+		req.Outputs = append(req.Outputs, getOut{Amount: 0, Index: idx})
+	}
+
+	var resp struct {
+		Outs []struct {
+			Height   uint64 `json:"height"`
+			Key      string `json:"key"`
+			Mask     string `json:"mask"`
+			Unlocked bool   `json:"unlocked"`
+		} `json:"outs"`
+		Status string `json:"status"`
+	}
+	if err := s.RPC.Call("/get_outs", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "OK" {
+		return nil, fmt.Errorf("get_outs: status %q", resp.Status)
+	}
+	if len(resp.Outs) != len(indices) {
+		return nil, fmt.Errorf("get_outs: asked %d outputs, got %d", len(indices), len(resp.Outs))
+	}
+
+	members := make([]RingMember, len(indices))
+	for i, out := range resp.Outs {
+		key, err := ParseKeyFromHex(out.Key)
+		if err != nil {
+			return nil, fmt.Errorf("get_outs: output %d key: %w", indices[i], err)
+		}
+		mask, err := ParseKeyFromHex(out.Mask)
+		if err != nil {
+			return nil, fmt.Errorf("get_outs: output %d mask: %w", indices[i], err)
+		}
+		members[i] = RingMember{GlobalIndex: indices[i], Key: Hash(key), Mask: Hash(mask), Height: out.Height, Unlocked: out.Unlocked}
+	}
+	return members, nil
+}
+
+/*--- по блокам, которые видит сканер ---*/
+
+// LocalRingSource — индекс выходов, который ведёт сканер вместе с OutputIndexer.
+// Знает только выходы с высоты, с которой начат скан, и держит их в памяти
+// (~100 байт на выход), так что кольца из него — для сканера, запущенного давно.
+type LocalRingSource struct {
+	mu          sync.RWMutex
+	startHeight uint64   // первый блок в индексе
+	base        uint64   // RingCT выходов в цепочке до startHeight
+	counts      []uint64 // RingCT выходов в цепочке после каждого блока от startHeight
+	tipTime     uint64
+	outputs     map[uint64]RingMember
+	unlockTimes map[uint64]ringUnlock
+	txs         map[Hash][]uint64
+}
+
+type ringUnlock struct {
+	unlockTime uint64
+	coinbase   bool
+}
+
+func NewLocalRingSource() *LocalRingSource {
+	return &LocalRingSource{
+		outputs:     make(map[uint64]RingMember),
+		unlockTimes: make(map[uint64]ringUnlock),
+		txs:         make(map[Hash][]uint64),
+	}
+}
+
+// AddBlocks: блоки по порядку и их индексы из OutputIndexer.IndexBlocks
+func (s *LocalRingSource) AddBlocks(blocks []*Block, indices GlobalIndices) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range blocks {
+		if len(s.counts) == 0 {
+			s.startHeight = b.BlockHeight
+		} else if next := s.startHeight + uint64(len(s.counts)); b.BlockHeight != next {
+			return fmt.Errorf("block %d does not continue ring index at %d", b.BlockHeight, next)
+		}
+
+		count := s.base
+		if len(s.counts) > 0 {
+			count = s.counts[len(s.counts)-1]
+		}
+		lowest := uint64(math.MaxUint64)
+		add := func(txHash Hash, version, unlockTime uint64, coinbase bool, outputs []TxOutput, outPk []Hash) error {
+			idx, ok := indices[txHash]
+			if version < 2 {
+				return nil // v1 выходы в RingCT кольца не попадают
+			}
+			if !ok || len(idx) != len(outputs) {
+				return fmt.Errorf("block %d: no global indices for tx %x", b.BlockHeight, txHash)
+			}
+			s.txs[txHash] = idx
+			for i, out := range outputs {
+				var mask Hash
+				if coinbase {
+					// открытая сумма, коммитмент zeroCommit(a) = G + aH
+					var err error
+					if mask, err = CalcCommitment(out.Amount, [32]byte{1}); err != nil {
+						return err
+					}
+				} else if i < len(outPk) {
+					mask = outPk[i]
+				} else {
+					return fmt.Errorf("block %d: tx %x without outPk for output %d", b.BlockHeight, txHash, i)
+				}
+				s.outputs[idx[i]] = RingMember{GlobalIndex: idx[i], Key: out.Target, Mask: mask, Height: b.BlockHeight}
+				s.unlockTimes[idx[i]] = ringUnlock{unlockTime, coinbase}
+				count = max(count, idx[i]+1)
+				lowest = min(lowest, idx[i])
+			}
+			return nil
+		}
+
+		if err := add(Hash(b.CalculateMinerTxHash()), b.MinerTx.Version, b.MinerTx.UnlockTime, true, b.MinerTx.Outs, nil); err != nil {
+			return err
+		}
+		for _, tx := range b.TXs {
+			var outPk []Hash
+			if tx.RctSignature != nil {
+				outPk = tx.RctSignature.OutPk
+			}
+			if err := add(tx.Hash, tx.Version, tx.UnlockTime, false, tx.Outputs, outPk); err != nil {
+				return err
+			}
+		}
+		if len(s.counts) == 0 && lowest != math.MaxUint64 {
+			s.base = lowest
+		}
+		s.counts = append(s.counts, count)
+		s.tipTime = b.Timestamp
+	}
+	return nil
+}
+
+func (s *LocalRingSource) tipHeight() uint64 {
+	return s.startHeight + uint64(len(s.counts)) - 1
+}
+
+// OutputDistribution: только с высоты начала индекса; более старые decoy
+// отбрасываются, поэтому для колец как у wallet2 нужен индекс хотя бы за год
+func (s *LocalRingSource) OutputDistribution() (*OutputDistribution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.counts) == 0 {
+		return nil, fmt.Errorf("ring index is empty")
+	}
+	return &OutputDistribution{StartHeight: s.startHeight, Base: s.base, Cumulative: slices.Clone(s.counts)}, nil
+}
+
+func (s *LocalRingSource) GlobalIndex(txHash Hash, outputIndex uint64) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx, ok := s.txs[txHash]
+	if !ok {
+		return 0, fmt.Errorf("tx %x is not in the ring index", txHash)
+	}
+	if outputIndex >= uint64(len(idx)) {
+		return 0, fmt.Errorf("tx %x has %d outputs, want %d", txHash, len(idx), outputIndex)
+	}
+	return idx[outputIndex], nil
+}
+
+func (s *LocalRingSource) Outputs(indices []uint64) ([]RingMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tip := s.tipHeight()
+	members := make([]RingMember, len(indices))
+	for i, idx := range indices {
+		m, ok := s.outputs[idx]
+		if !ok {
+			return nil, fmt.Errorf("output %d is not in the ring index (indexed from height %d)", idx, s.startHeight)
+		}
+		u := s.unlockTimes[idx]
+		m.Unlocked = IsUnlocked(&OwnedOutput{BlockHeight: m.Height, UnlockTime: u.unlockTime, Coinbase: u.coinbase}, tip, s.tipTime)
+		members[i] = m
+	}
+	return members, nil
+}
+
+/*--- для тестов ---*/
+
+// FakeRingSource: цепочка из count выходов со случайными ключами, кроме добавленных через Add.
+// Кольца из неё подписываются и проверяются, но в сети не примутся.
+type FakeRingSource struct {
+	mu      sync.Mutex
+	count   uint64
+	outputs map[uint64]RingMember
+	txs     map[outpoint]uint64
+}
+
+func NewFakeRingSource(count uint64) *FakeRingSource {
+	return &FakeRingSource{
+		count:   count,
+		outputs: make(map[uint64]RingMember),
+		txs:     make(map[outpoint]uint64),
+	}
+}
+
+// Add: настоящий выход с известным коммитментом по o.GlobalIndex, без индекса — следующим
+func (s *FakeRingSource) Add(o OwnedOutput, commitment Hash) *FakeRingSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := s.count
+	if o.GlobalIndex != nil {
+		idx = *o.GlobalIndex
+	}
+	s.outputs[idx] = RingMember{GlobalIndex: idx, Key: o.OutputKey, Mask: commitment, Height: o.BlockHeight, Unlocked: true}
+	s.txs[outpoint{o.TxHash, o.OutputIndex}] = idx
+	s.count = max(s.count, idx+1)
+	return s
+}
+
+// fakeOutputsPerBlock: примерно как в mainnet
+const fakeOutputsPerBlock = 60
+
+// OutputDistribution: выходы поровну по блокам с нулевой высоты
+func (s *FakeRingSource) OutputDistribution() (*OutputDistribution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return nil, fmt.Errorf("fake ring source is empty")
+	}
+
+	blocks := (s.count + fakeOutputsPerBlock - 1) / fakeOutputsPerBlock
+	d := &OutputDistribution{Cumulative: make([]uint64, blocks)}
+	for i := range d.Cumulative {
+		d.Cumulative[i] = min(uint64(i+1)*fakeOutputsPerBlock, s.count)
+	}
+	return d, nil
+}
+
+func (s *FakeRingSource) GlobalIndex(txHash Hash, outputIndex uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.txs[outpoint{txHash, outputIndex}]
+	if !ok {
+		return 0, fmt.Errorf("output %x:%d is unknown", txHash, outputIndex)
+	}
+	return idx, nil
+}
+
+func (s *FakeRingSource) Outputs(indices []uint64) ([]RingMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := make([]RingMember, len(indices))
+	for i, idx := range indices {
+		if idx >= s.count {
+			return nil, fmt.Errorf("output %d is out of range %d", idx, s.count)
+		}
+		m, ok := s.outputs[idx]
+		if !ok {
+			// случайная точка на ключ и коммитмент, одна и та же при повторных запросах
+			m = RingMember{
+				GlobalIndex: idx,
+				Key:         Hash(*RandomScalar().PubKey()),
+				Mask:        Hash(*RandomScalar().PubKey()),
+				Height:      idx / fakeOutputsPerBlock,
+				Unlocked:    true,
+			}
+			s.outputs[idx] = m
+		}
+		members[i] = m
+	}
+	return members, nil
+}
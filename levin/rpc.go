@@ -0,0 +1,208 @@
+package levin
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRPCTimeout = 30 * time.Second
+
+// DaemonRPC — клиент HTTP RPC monerod. URL без пути: "http://127.0.0.1:18081".
+// Username/Password — --rpc-login демона, он принимает только digest auth.
+type DaemonRPC struct {
+	URL      string
+	Username string
+	Password string
+	Timeout  time.Duration // 0 — 30 секунд
+	Client   *http.Client  // nil — http.Client с Timeout
+
+	mu         sync.Mutex
+	challenge  map[string]string // последний WWW-Authenticate: Digest
+	nonceCount uint32
+}
+
+func NewDaemonRPC(url string) *DaemonRPC {
+	return &DaemonRPC{URL: strings.TrimRight(url, "/")}
+}
+
+// JSONRPC: метод /json_rpc, ошибка демона возвращается как error
+func (d *DaemonRPC) JSONRPC(method string, params any, result any) error {
+	req := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      "0",
+		"method":  method,
+	}
+	if params != nil {
+		req["params"] = params
+	}
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := d.Call("/json_rpc", req, &resp); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s: daemon error %d: %s", method, resp.Error.Code, resp.Error.Message)
+	}
+	if len(resp.Result) == 0 {
+		return fmt.Errorf("%s: empty result", method)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("%s: failed to decode result: %w", method, err)
+	}
+	return nil
+}
+
+// Call: JSON эндпоинты вне json_rpc, например "/get_outs"
+func (d *DaemonRPC) Call(path string, reqBody any, respBody any) error {
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.post(path, data, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && d.Username != "" {
+		auth, err := d.digestAuth(resp.Header.Get("WWW-Authenticate"), path)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp, err = d.post(path, data, auth); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("daemon rpc %s: http %d: %s", path, resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(respBody); err != nil {
+		return fmt.Errorf("daemon rpc %s: failed to decode response: %w", path, err)
+	}
+	return nil
+}
+
+func (d *DaemonRPC) post(path string, data []byte, auth string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, d.URL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	client := d.Client
+	if client == nil {
+		timeout := d.Timeout
+		if timeout == 0 {
+			timeout = defaultRPCTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("daemon rpc %s: %w", path, err)
+	}
+	return resp, nil
+}
+
+// digestAuth: RFC 2617, MD5 и qop=auth — то, что предлагает epee
+func (d *DaemonRPC) digestAuth(header, path string) (string, error) {
+	scheme, params, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Digest") {
+		return "", fmt.Errorf("daemon rpc %s: unsupported auth challenge %q", path, header)
+	}
+	challenge := make(map[string]string)
+	for _, p := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		challenge[strings.ToLower(k)] = strings.Trim(v, `"`)
+	}
+	if alg := challenge["algorithm"]; alg != "" && !strings.EqualFold(alg, "MD5") {
+		return "", fmt.Errorf("daemon rpc %s: unsupported digest algorithm %s", path, alg)
+	}
+
+	d.mu.Lock()
+	if d.challenge == nil || d.challenge["nonce"] != challenge["nonce"] {
+		d.nonceCount = 0
+	}
+	d.challenge = challenge
+	d.nonceCount++
+	nc := fmt.Sprintf("%08x", d.nonceCount)
+	d.mu.Unlock()
+
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	cnonceBytes := make([]byte, 8)
+	rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+
+	ha1 := md5hex(d.Username + ":" + challenge["realm"] + ":" + d.Password)
+	ha2 := md5hex(http.MethodPost + ":" + path)
+	response := md5hex(ha1 + ":" + challenge["nonce"] + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=MD5, qop=auth, nc=%s, cnonce="%s", response="%s"`,
+		d.Username, challenge["realm"], challenge["nonce"], path, nc, cnonce, response)
+	if opaque, ok := challenge["opaque"]; ok {
+		auth += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return auth, nil
+}
+
+// GetTransaction: /get_transactions, полная транзакция и где она сейчас
+func (d *DaemonRPC) GetTransaction(hash Hash) (*Transaction, TxStatus, error) {
+	req := map[string]any{
+		"txs_hashes":     []string{hex.EncodeToString(hash[:])},
+		"decode_as_json": false,
+	}
+	var resp struct {
+		Status string `json:"status"`
+		Txs    []struct {
+			AsHex       string `json:"as_hex"`
+			InPool      bool   `json:"in_pool"`
+			BlockHeight uint64 `json:"block_height"`
+		} `json:"txs"`
+	}
+	if err := d.Call("/get_transactions", req, &resp); err != nil {
+		return nil, TxStatus{}, err
+	}
+	if resp.Status != "OK" {
+		return nil, TxStatus{}, fmt.Errorf("get_transactions: %s", resp.Status)
+	}
+	if len(resp.Txs) == 0 {
+		return nil, TxStatus{Hash: hash}, fmt.Errorf("get_transactions: tx %x not found", hash)
+	}
+
+	blob, err := hex.DecodeString(resp.Txs[0].AsHex)
+	if err != nil {
+		return nil, TxStatus{}, fmt.Errorf("get_transactions: %w", err)
+	}
+	tx := &Transaction{Raw: blob}
+	tx.ParseTx()
+	tx.ParseRctSig()
+	tx.CalcHash()
+	if tx.Hash != hash {
+		return nil, TxStatus{}, fmt.Errorf("get_transactions: asked %x, got %x", hash, tx.Hash)
+	}
+	return tx, TxStatus{Hash: hash, Known: true, InPool: resp.Txs[0].InPool, BlockHeight: resp.Txs[0].BlockHeight}, nil
+}
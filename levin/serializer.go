@@ -3,6 +3,7 @@ package levin
 import (
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 )
 
 type Hash [32]byte
@@ -15,6 +16,22 @@ func (h Hash) MarshalJSON() ([]byte, error) {
 	return json.Marshal(hexStr) // оборачиваем в кавычки
 }
 
+func (h *Hash) UnmarshalJSON(data []byte) error {
+	var hexStr string
+	if err := json.Unmarshal(data, &hexStr); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return err
+	}
+	if len(b) != len(h) {
+		return fmt.Errorf("hash must be %d bytes, got %d", len(h), len(b))
+	}
+	copy(h[:], b)
+	return nil
+}
+
 func (b HByte) MarshalJSON() ([]byte, error) {
 	hexStr := hex.EncodeToString([]byte{byte(b)})
 	return json.Marshal(hexStr)
@@ -34,6 +51,19 @@ func (h ByteArray) MarshalJSON() ([]byte, error) {
 	return json.Marshal(hexStr) // оборачиваем в кавычки
 }
 
+func (h *ByteArray) UnmarshalJSON(data []byte) error {
+	var hexStr string
+	if err := json.Unmarshal(data, &hexStr); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
 func (h HAmount) MarshalJSON() ([]byte, error) {
 	hexStr := hex.EncodeToString(h[:])
 	return json.Marshal(hexStr) // оборачиваем в кавычки
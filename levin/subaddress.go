@@ -0,0 +1,154 @@
+package levin
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// Окно субадресов по умолчанию, как в wallet2
+const (
+	SubaddressLookaheadMajor = 50
+	SubaddressLookaheadMinor = 200
+)
+
+// subaddressSecret: m = Hs("SubAddr\0" || a || major || minor)
+func subaddressSecret(viewKey *Key, idx SubaddressIndex) (*edwards25519.Scalar, error) {
+	data := make([]byte, 0, 8+KeyLength+8)
+	data = append(data, "SubAddr\x00"...)
+	data = append(data, viewKey[:]...)
+	data = binary.LittleEndian.AppendUint32(data, idx.Major)
+	data = binary.LittleEndian.AppendUint32(data, idx.Minor)
+
+	hash := make([]byte, 64)
+	copy(hash, keccak256(data))
+	return new(edwards25519.Scalar).SetUniformBytes(hash)
+}
+
+// SubaddressSpendKey: D = B + m*G, для (0, 0) — основной B
+func (a *Account) SubaddressSpendKey(idx SubaddressIndex) (Key, error) {
+	if idx == (SubaddressIndex{}) {
+		return a.PubSpend, nil
+	}
+
+	m, err := subaddressSecret(&a.viewKey, idx)
+	if err != nil {
+		return Key{}, err
+	}
+	B, err := new(edwards25519.Point).SetBytes(a.PubSpend[:])
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid public spend key: %w", err)
+	}
+
+	D := new(edwards25519.Point).ScalarBaseMult(m)
+	D.Add(D, B)
+	return Key(D.Bytes()), nil
+}
+
+// SubaddressKeys возвращает (D, C = a*D) субадреса
+func (a *Account) SubaddressKeys(idx SubaddressIndex) (spend Key, view Key, err error) {
+	if idx == (SubaddressIndex{}) {
+		return a.PubSpend, a.PubView, nil
+	}
+
+	spend, err = a.SubaddressSpendKey(idx)
+	if err != nil {
+		return
+	}
+
+	D, err := new(edwards25519.Point).SetBytes(spend[:])
+	if err != nil {
+		return
+	}
+	s, err := new(edwards25519.Scalar).SetCanonicalBytes(a.viewKey[:])
+	if err != nil {
+		return spend, view, fmt.Errorf("invalid private view key: %w", err)
+	}
+	view = Key(new(edwards25519.Point).ScalarMult(s, D).Bytes())
+	return
+}
+
+// SubaddressAddress: строка субадреса в сети основного адреса, (0, 0) — сам основной адрес
+func (a *Account) SubaddressAddress(idx SubaddressIndex) (string, error) {
+	if idx == (SubaddressIndex{}) {
+		return a.Address, nil
+	}
+	p, err := ParseAddress(a.Address)
+	if err != nil {
+		return "", err
+	}
+	spend, view, err := a.SubaddressKeys(idx)
+	if err != nil {
+		return "", err
+	}
+	return EncodeAddress(p.Network, AddressSubaddress, spend, view, nil)
+}
+
+// SetSubaddressLookahead задаёт окно: major аккаунтов и minor субадресов сверх последнего использованного
+func (a *Account) SetSubaddressLookahead(major, minor uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lookaheadMajor = major
+	a.lookaheadMinor = minor
+}
+
+// lookupSubaddress ищет субадрес по публичному spend ключу D
+func (a *Account) lookupSubaddress(D Key) (SubaddressIndex, bool) {
+	a.mu.RLock()
+	if a.subaddresses != nil {
+		idx, ok := a.subaddresses[D]
+		a.mu.RUnlock()
+		return idx, ok
+	}
+	a.mu.RUnlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.subaddresses == nil {
+		a.subaddresses = make(map[Key]SubaddressIndex)
+		a.extendSubaddresses(SubaddressIndex{})
+	}
+	idx, ok := a.subaddresses[D]
+	return idx, ok
+}
+
+// markSubaddressUsed сдвигает окно, если получатель близко к его краю
+func (a *Account) markSubaddressUsed(idx SubaddressIndex) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.subaddresses == nil {
+		a.subaddresses = make(map[Key]SubaddressIndex)
+	}
+	a.extendSubaddresses(idx)
+}
+
+// extendSubaddresses: таблица должна покрывать majors до used.Major+lookahead
+// и minors до used.Minor+lookahead. Вызывается под a.mu.
+func (a *Account) extendSubaddresses(used SubaddressIndex) {
+	majorEnd := used.Major + a.lookaheadMajor
+	if a.lookaheadMajor == 0 {
+		majorEnd = used.Major + 1
+	}
+	for major := uint32(0); major < majorEnd; major++ {
+		minorEnd := a.lookaheadMinor
+		if major == used.Major {
+			minorEnd += used.Minor
+		}
+		if minorEnd == 0 {
+			minorEnd = 1
+		}
+
+		for minor := a.minorEnd[major]; minor < minorEnd; minor++ {
+			idx := SubaddressIndex{Major: major, Minor: minor}
+			D, err := a.SubaddressSpendKey(idx)
+			if err != nil {
+				continue
+			}
+			a.subaddresses[D] = idx
+		}
+		if a.minorEnd[major] < minorEnd {
+			a.minorEnd[major] = minorEnd
+		}
+	}
+}
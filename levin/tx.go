@@ -14,6 +14,10 @@ type Transaction struct {
 	Hash [32]byte `json:"-"`
 	Raw  []byte   `json:"-"`
 
+	// Pruned: в Raw только префикс и RCT base, вместо prunable части — её хэш
+	Pruned       bool `json:"-"`
+	PrunableHash Hash `json:"-"`
+
 	Version        uint64          `json:"version"`
 	UnlockTime     uint64          `json:"unlock_time"`
 	VinCount       uint64          `json:"-"`
@@ -25,13 +29,16 @@ type Transaction struct {
 	RctSignature   *RctSignature   `json:"rct_signature"`
 	RctSigPrunable *RctSigPrunable `json:"rctsig_prunable"`
 
-	POutputs     []TxPrm                `json:"-"`
-	PInputs      []TxPrm                `json:"-"`
+	POutputs     []Destination          `json:"-"`
+	PInputs      []Input                `json:"-"`
 	SecretKey    Hash                   `json:"-"`
 	PublicKey    Hash                   `json:"-"`
 	BlindScalars []*edwards25519.Scalar `json:"-"`
 	InputScalars []*edwards25519.Scalar `json:"-"`
 	BlindAmounts []uint64               `json:"-"`
+
+	// по ключу на выход, если есть получатель-субадрес и ещё кто-то кроме сдачи
+	AdditionalSecretKeys []Hash `json:"-"`
 }
 
 type TxInput struct {
@@ -97,6 +104,10 @@ type RctSigPrunable struct {
 func (tx *Transaction) Serialize() []byte {
 	part1 := tx.CalculatePart1()
 	part2 := tx.CalculatePart2()
+
+	if tx.Pruned {
+		return append(part1, part2...)
+	}
 	part3 := tx.CalculatePart3()
 
 	// Step 2: Concatenate the parts
@@ -187,6 +198,13 @@ func (tx *Transaction) ParseRctSig() {
 		RctSignature.OutPk = append(RctSignature.OutPk, outPk)
 	}
 
+	// У pruned транзакции после outPk ничего нет
+	if tx.Pruned {
+		tx.RctSignature = RctSignature
+		tx.RctSigPrunable = nil
+		return
+	}
+
 	RctSigPrunable.Nbp, _ = ReadVarint(reader)
 	for i := 0; i < int(RctSigPrunable.Nbp); i++ {
 		bpp := Bpp{}
@@ -242,97 +260,41 @@ func (tx *Transaction) ParseRctSig() {
 	reader.Read(rest)
 }
 
-func (tx *Transaction) CheckOutputs(address string, privateViewKey string) (float64, uint64, error) {
-	pubSpendKey, pubViewKey, err := DecodeAddress(address) // correct ✅
+// CheckOutputs: сумма выходов на основной адрес кошелька и расшифрованный short
+// payment id (0, если нет). Общая derivation на транзакцию, выходы с чужим view
+// tag отбрасываются сразу. Субадреса не ищутся: таблица субадресов стоит сотни
+// миллисекунд, для многих транзакций и субадресов есть Watcher.
+func (tx *Transaction) CheckOutputs(address string, privateViewKey string) (Amount, uint64, error) {
+	account, err := NewAccount(address, privateViewKey)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to decode address: %w", err)
+		return 0, 0, err
 	}
+	account.SetSubaddressLookahead(0, 0)
 
-	privViewKeyBytes, err := hexTo32(privateViewKey) // correct ✅
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to decode private view key: %w", err)
-	}
-
-	if !verifyViewKeyPair(privViewKeyBytes, pubViewKey[:]) { // correct ✅
-		return 0, 0, fmt.Errorf("private view key does not match address")
-	}
-
-	// txPubKey, err := extractTxPubKey(tx.Extra) // correct ✅
-	txPubKey, _, _, encPID, err := parseTxExtra(tx.Extra)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to extract tx public key: %w", err)
-	}
-
-	// Check each output
-	var totalAmount float64
-	var foundOutputs int
-
-	for outputIndex, output := range tx.Outputs {
-		// Derive the one-time public key and view tag
-		viewTagByte, err := DeriveViewTag(txPubKey, privViewKeyBytes, uint64(outputIndex))
+	extra, err := tx.ParseExtra()
+	if extra.PubKey() == nil {
 		if err != nil {
-			continue
+			return 0, 0, fmt.Errorf("failed to extract tx public key: %w", err)
 		}
-
-		// If view tag exists and doesn't match, log it but don't immediately skip — fall back to full derived-key check
-		if byte(output.ViewTag) != viewTagByte {
-			fmt.Printf("Output %d: View tag mismatch (expected %02x, got %02x), will still check derived key\n", outputIndex, viewTagByte, byte(output.ViewTag))
-			continue
-		} else {
-			fmt.Println("Output view_tag is match✅")
-		}
-
-		derivedKey, err := DerivePublicKeyMy(txPubKey, privViewKeyBytes, pubSpendKey[:], uint64(outputIndex))
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to derive public key for output %d: %w", outputIndex, err)
-		}
-
-		// Compare derived key with output target (this is authoritative)
-		if !equalBytes(derivedKey, output.Target[:]) {
-			fmt.Printf("Output %d: Derived key %x does not match output target %x\n", outputIndex, derivedKey, output.Target)
-			continue // Not our output
-		} else {
-			fmt.Printf("Output %d: Derived key matches output target ✅\n", outputIndex)
-		}
-
-		// This output belongs to us!
-		foundOutputs++
-		var amount float64
-
-		// If it's an RCT transaction, decode the amount
-		if tx.RctSignature != nil && tx.RctSignature.Type > 0 {
-			if outputIndex < len(tx.RctSignature.EcdhInfo) {
-				amount, err = DecodeRctAmount(
-					txPubKey,
-					privViewKeyBytes,
-					uint64(outputIndex),
-					tx.RctSignature.EcdhInfo[outputIndex].Amount[:],
-				)
-				if err != nil {
-					return 0, 0, fmt.Errorf("failed to decode RCT amount for output %d: %w", outputIndex, err)
-				} else {
-					fmt.Printf("Output %d: Decoded RCT amount: %.12f\n", outputIndex, amount)
-				}
-			}
-		} else {
-			amount = 0
-		}
-
-		totalAmount += amount
+		return 0, 0, fmt.Errorf("tx public key not found in extra")
 	}
 
-	if foundOutputs == 0 {
+	t := txScanTarget(tx, 0)
+	owned := t.scan(account)
+	if len(owned) == 0 {
 		return 0, 0, fmt.Errorf("no outputs found for this address")
 	}
 
-	if encPID != nil {
-		id, _, err := decryptShortPaymentID(txPubKey, privViewKeyBytes, encPID)
-		if err != nil {
-			return 0, 0, err
-		}
-		return totalAmount, id, nil
+	var total Amount
+	for _, o := range owned {
+		total += o.Amount
 	}
-	return totalAmount, 0, nil
+
+	var id uint64
+	if pid := owned[0].PaymentID; len(pid) == 8 {
+		id = binary.LittleEndian.Uint64(pid)
+	}
+	return total, id, nil
 }
 
 func (tx *Transaction) CalculatePart1() []byte {
@@ -445,7 +407,7 @@ func (tx *Transaction) CalcHash() {
 	// Step 1: Hash the transaction parts
 	part1 := keccak256(tx.CalculatePart1())
 	part2 := keccak256(tx.CalculatePart2())
-	part3 := keccak256(tx.CalculatePart3())
+	part3 := tx.CalcPrunableHash()
 
 	// Step 2: Concatenate the parts
 	concat := append(part1, part2...)
@@ -458,6 +420,28 @@ func (tx *Transaction) CalcHash() {
 	copy(tx.Hash[:], finalHash)
 }
 
+// CalcPrunableHash: хэш prunable части (bpp, CLSAGs, pseudoOuts).
+// Для pruned транзакции возвращает хэш, пришедший от пира.
+func (tx *Transaction) CalcPrunableHash() []byte {
+	if tx.Pruned {
+		return bytes.Clone(tx.PrunableHash[:])
+	}
+	return keccak256(tx.CalculatePart3())
+}
+
+// Prune отбрасывает подписи, оставляя префикс, RCT base и хэш prunable части.
+// Хэш транзакции при этом не меняется.
+func (tx *Transaction) Prune() {
+	if tx.Pruned || tx.RctSigPrunable == nil {
+		return
+	}
+	copy(tx.PrunableHash[:], tx.CalcPrunableHash())
+	tx.Pruned = true
+	tx.RctSigPrunable = nil
+	tx.Raw = tx.Serialize()
+	tx.RctRaw = tx.CalculatePart2()
+}
+
 func DeriveViewTag(txPubKey []byte, privateViewKey []byte, index uint64) (byte, error) {
 	if len(txPubKey) != 32 || len(privateViewKey) != 32 {
 		return 0, fmt.Errorf("invalid key lengths")
@@ -511,28 +495,8 @@ func computeViewTag(txPubKey []byte, privateViewKey []byte, outputIndex uint64)
 	return hash[0]
 }
 
-// extractTxPubKey extracts the transaction public key from the Extra field
-// Format: 0x01 (tag) + 32 bytes (public key)
-func extractTxPubKey(extra []byte) ([]byte, error) {
-	if len(extra) < 33 {
-		return nil, fmt.Errorf("extra field too short")
-	}
-
-	// Look for tag 0x01 (TX_EXTRA_TAG_PUBKEY)
-	for i := 0; i < len(extra)-32; i++ {
-		if extra[i] == 0x01 {
-			// Found the tag, next 32 bytes are the public key
-			pubKey := make([]byte, 32)
-			copy(pubKey, extra[i+1:i+33])
-			return pubKey, nil
-		}
-	}
-
-	return nil, fmt.Errorf("tx public key not found in extra field")
-}
-
 // decodeRctAmount decodes an encrypted RCT amount
-func DecodeRctAmount(txPubKey []byte, privateViewKey []byte, outputIndex uint64, encryptedAmount []byte) (float64, error) {
+func DecodeRctAmount(txPubKey []byte, privateViewKey []byte, outputIndex uint64, encryptedAmount []byte) (Amount, error) {
 	if len(encryptedAmount) != 8 {
 		return 0, fmt.Errorf("invalid encrypted amount length: %d", len(encryptedAmount))
 	}
@@ -594,7 +558,7 @@ func DecodeRctAmount(txPubKey []byte, privateViewKey []byte, outputIndex uint64,
 		amount |= uint64(decrypted) << (8 * i)
 	}
 
-	return float64(amount) / 1e12, nil
+	return Amount(amount), nil
 }
 
 // decodeRctAmount decodes an encrypted RCT amount
@@ -647,9 +611,8 @@ func generateBulletproofPlusMask(txPubKey []byte, privateViewKey []byte, outputI
 	return mask, nil
 }
 
-func EncryptRctAmount(amount float64, pubViewKey []byte, txSecretKey []byte, outputIndex uint64) (HAmount, error) {
-	// Конвертируем amount в uint64 (предполагаем, что amount уже в atomic units)
-	amountAtomic := uint64(amount * 1e12)
+func EncryptRctAmount(amount Amount, pubViewKey []byte, txSecretKey []byte, outputIndex uint64) (HAmount, error) {
+	amountAtomic := uint64(amount)
 
 	// Получаем shared secret (shared = 8 * txSecretKey * pubViewKey)
 	shared, err := SharedSecret(pubViewKey, txSecretKey)
@@ -697,9 +660,8 @@ func EncryptRctAmount(amount float64, pubViewKey []byte, txSecretKey []byte, out
 // - x is the blinding factor (mask) derived from shared secret
 // - a is the amount in atomic units
 // - G is the base point, H is the second base point
-func CalcOutPk(amount float64, pubViewKey []byte, pubSpendKey []byte, txSecretKey []byte, outputIndex uint64) (*edwards25519.Scalar, Hash, error) {
-	// Convert amount to atomic units
-	amountAtomic := uint64(amount * 1e12)
+func CalcOutPk(amount Amount, pubViewKey []byte, pubSpendKey []byte, txSecretKey []byte, outputIndex uint64) (*edwards25519.Scalar, Hash, error) {
+	amountAtomic := uint64(amount)
 
 	// ВАЖНО: Сначала вычисляем shared secret правильно
 	// В Monero: shared_secret = r * A (где r - tx secret key, A - pub view key)
@@ -804,6 +766,57 @@ func CalcCommitment(amount uint64, mask [32]byte) (Hash, error) {
 	return Hash(commitment.Bytes()), nil
 }
 
+// VerifyBalance checks that a RingCT transaction creates no money:
+// sum(pseudoOuts) == sum(outPk) + fee*H
+func (tx *Transaction) VerifyBalance() error {
+	if tx.RctSignature == nil || tx.RctSignature.Type == uint64(RCTTypeNull) {
+		return nil
+	}
+	if tx.Pruned {
+		return fmt.Errorf("transaction is pruned: pseudoOuts are not available")
+	}
+	if tx.RctSigPrunable == nil {
+		return fmt.Errorf("rct signature prunable part is missing")
+	}
+	if len(tx.RctSigPrunable.PseudoOuts) != len(tx.Inputs) {
+		return fmt.Errorf("pseudoOuts count %d does not match inputs count %d", len(tx.RctSigPrunable.PseudoOuts), len(tx.Inputs))
+	}
+	if len(tx.RctSignature.OutPk) != len(tx.Outputs) {
+		return fmt.Errorf("outPk count %d does not match outputs count %d", len(tx.RctSignature.OutPk), len(tx.Outputs))
+	}
+
+	sumPseudoOuts := edwards25519.NewIdentityPoint()
+	for i, pseudoOut := range tx.RctSigPrunable.PseudoOuts {
+		P, err := new(edwards25519.Point).SetBytes(pseudoOut[:])
+		if err != nil {
+			return fmt.Errorf("invalid pseudoOut %d: %w", i, err)
+		}
+		sumPseudoOuts.Add(sumPseudoOuts, P)
+	}
+
+	// fee*H is a commitment to the fee with a zero mask
+	feeCommitment, err := CalcCommitment(tx.RctSignature.TxnFee, [32]byte{})
+	if err != nil {
+		return fmt.Errorf("failed to commit to fee: %w", err)
+	}
+	sumOutPk, err := new(edwards25519.Point).SetBytes(feeCommitment[:])
+	if err != nil {
+		return fmt.Errorf("invalid fee commitment: %w", err)
+	}
+	for i, outPk := range tx.RctSignature.OutPk {
+		P, err := new(edwards25519.Point).SetBytes(outPk[:])
+		if err != nil {
+			return fmt.Errorf("invalid outPk %d: %w", i, err)
+		}
+		sumOutPk.Add(sumOutPk, P)
+	}
+
+	if sumPseudoOuts.Equal(sumOutPk) != 1 {
+		return fmt.Errorf("amounts do not balance: sum(pseudoOuts) %x != sum(outPk) + fee*H %x (fee %d)", sumPseudoOuts.Bytes(), sumOutPk.Bytes(), tx.RctSignature.TxnFee)
+	}
+	return nil
+}
+
 func CalcScalars(scalars []*edwards25519.Scalar) (*edwards25519.Scalar, error) {
 	sum := edwards25519.NewScalar()
 	for _, scalar := range scalars {
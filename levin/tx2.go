@@ -2,43 +2,11 @@ package levin
 
 import (
 	"bytes"
-	"encoding/hex"
 	"fmt"
-	"math/rand"
-	"time"
 
 	"filippo.io/edwards25519"
 )
 
-type TxPrm map[string]interface{}
-
-const daemonURL = "https://xmr3.doggett.tech:18089/get_transactions"
-const currentBlockHeight = 3570154
-
-var mockOffset = []uint64{
-	143369868,
-	506667,
-	605704,
-	69702,
-	405731,
-	275890,
-	249503,
-	38215,
-	86866,
-	1588,
-	17,
-	266,
-	1281,
-	231,
-	223,
-	1401,
-}
-
-const (
-	txPublicKeyHex  = "772299cb00fae663173f9aeab9273da82f2500976e6556e16da22bf6ceed1d83"
-	txPrivateKeyHex = "fc1415ced071ae7de346a7ca0dd2b0f9b64cd64423d5ea73b971da135c54de05"
-)
-
 func NewEmptyTransaction() *Transaction {
 	// var err error
 	tx := &Transaction{
@@ -59,61 +27,57 @@ func NewEmptyTransaction() *Transaction {
 		},
 	}
 
-	// tx.SecretKey = RandomScalar().ToBytes()
-	if h, err := hexTo32(txPrivateKeyHex); err == nil {
-		tx.SecretKey = Hash(h)
-	}
+	// свой секретный ключ на каждую транзакцию, иначе получатели связывают их между собой
+	tx.SecretKey = Hash(*RandomScalar())
 
 	return tx
 }
 
-func (t *Transaction) WriteInput(prm TxPrm) {
-	t.PInputs = append(t.PInputs, prm)
-}
-
-func (t *Transaction) WriteOutput(prm TxPrm) {
-	t.POutputs = append(t.POutputs, prm)
-}
-
-func (t *Transaction) CalcExtra() error {
-	//Считаем количество выходов и вычисляем txPublicKey вместе с extra
-	outs := 0
-	pubSpendKey := [32]byte{}
-	pubViewKey := [32]byte{}
-	paymentId := []byte{}
-	err := error(nil)
-	for _, val := range t.POutputs {
-		if val2, ok := val["change_address"].(bool); !ok || !val2 {
-			addr := val["address"].(string)
-			if isSubAddress(addr) {
-				outs += 1
-			}
-
-			pubSpendKey, pubViewKey, err = DecodeAddress(addr) // correct ✅
-			if err != nil {
-				return fmt.Errorf("failed to decode address: %w", err)
-			}
-
-			paymentId, err = ExtractPaymentID(addr)
-			if err != nil {
-				return fmt.Errorf("failed to extract payment ID: %w", err)
-			}
-			if paymentId == nil {
-				paymentId = make([]byte, 8)
-			}
-
+// calcExtra: tx public key, дополнительные ключи и payment id, как
+// construct_tx_with_tx_key в wallet2. Получатели считаются по уникальным
+// адресам, сдача (и пустой выход) не считается.
+func (t *Transaction) calcExtra() error {
+	var (
+		std, sub   = map[string]bool{}, map[string]bool{}
+		single     *ParsedAddress // последний получатель, при одном адресе — единственный
+		paymentId  []byte
+		parsed     = make([]*ParsedAddress, len(t.POutputs))
+		changeOnly = true
+	)
+	for i, d := range t.POutputs {
+		p, err := ParseAddress(d.Address)
+		if err != nil {
+			return fmt.Errorf("failed to decode address: %w", err)
+		}
+		parsed[i] = p
+		if d.change {
+			continue
 		}
+		changeOnly = false
+		if p.Type == AddressSubaddress {
+			sub[d.Address] = true
+		} else {
+			std[d.Address] = true
+		}
+		if p.PaymentID != nil {
+			paymentId = p.PaymentID
+		}
+		single = p
+	}
+	if changeOnly {
+		return fmt.Errorf("no destinations besides change")
 	}
+	singleDest := len(std)+len(sub) == 1
 
 	s := new(edwards25519.Scalar)
 	if _, err := s.SetCanonicalBytes(t.SecretKey[:]); err != nil {
 		s.SetUniformBytes(append(t.SecretKey[:], t.SecretKey[:]...))
 	}
 
-	if outs == 1 {
+	if len(std) == 0 && len(sub) == 1 {
 		D := new(edwards25519.Point)
-		if _, err := D.SetBytes(pubSpendKey[:]); err != nil {
-			panic(err)
+		if _, err := D.SetBytes(single.PubSpend[:]); err != nil {
+			return fmt.Errorf("invalid destination spend key: %w", err)
 		}
 
 		sD := new(edwards25519.Point).ScalarMult(s, D) // s * D
@@ -123,119 +87,120 @@ func (t *Transaction) CalcExtra() error {
 		t.PublicKey = Hash(sG.Bytes())
 	}
 
-	var buf bytes.Buffer
+	extra := NewTxExtra().AddPubKey(t.PublicKey)
 
-	buf.WriteByte(0x01)
-	buf.Write(t.PublicKey[:])
-	buf.WriteByte(0x02) // Payment ID tag
-	buf.WriteByte(0x09) // Payment ID Length (16 bytes)
-	buf.WriteByte(0x01) // Encrypted Payment ID flag
-	encryptedPaymentId, err := encryptPaymentID(paymentId, pubViewKey[:], t.SecretKey[:])
-	if err != nil {
-		return err
+	// payment id шифруется view ключом получателя, так что он должен быть один.
+	// Без payment id в транзакции с двумя выходами пишем нулевой, как wallet2.
+	if paymentId == nil && singleDest && len(t.POutputs) == 2 {
+		paymentId = make([]byte, 8)
 	}
-	buf.Write(encryptedPaymentId[:])
-
-	t.Extra = ByteArray(buf.Bytes())
-
-	return nil
-}
-
-func (t *Transaction) CalcInputs() error {
-	for _, val := range t.PInputs {
-		if err := t.writeInput2(val); err != nil {
-			return err
+	if paymentId != nil {
+		if !singleDest {
+			return fmt.Errorf("payment id needs a single destination address")
 		}
-	}
-	return nil
-}
-
-func (t *Transaction) CalcOutputs() error {
-	for _, val := range t.POutputs {
-		if err := t.writeOutput2(val); err != nil {
+		encryptedPaymentId, err := encryptPaymentID(paymentId, single.PubView[:], t.SecretKey[:])
+		if err != nil {
 			return err
 		}
-	}
-	return nil
-}
-
-func (t *Transaction) writeInput2(prm TxPrm) error {
-	vout := prm["vout"].(int)
-	indx, err := getOutputIndex(prm["txId"].(string), vout)
-	if err != nil {
-		return fmt.Errorf("failed to get output index: %w", err)
+		extra.AddEncryptedPaymentID(encryptedPaymentId)
+	}
+
+	// R = rD работает только для одного получателя-субадреса, иначе ключ на каждый выход
+	t.AdditionalSecretKeys = nil
+	if len(sub) > 0 && (len(std) > 0 || len(sub) > 1) {
+		pubs := make([]Hash, len(t.POutputs))
+		for i, p := range parsed {
+			sec := RandomScalar()
+			t.AdditionalSecretKeys = append(t.AdditionalSecretKeys, Hash(*sec))
+			if p.Type == AddressSubaddress {
+				D := Key(p.PubSpend)
+				pubs[i] = Hash(ScalarMult(sec, &D))
+			} else {
+				pubs[i] = Hash(*sec.PubKey())
+			}
+		}
+		extra.AddAdditionalPubKeys(pubs)
 	}
 
-	maxIndx, err := getMaxGlobalIndex()
+	extraBytes, err := extra.Bytes()
 	if err != nil {
-		return fmt.Errorf("failed to get max global index: %w", err)
+		return err
 	}
+	t.Extra = ByteArray(extraBytes)
 
-	ring, err := SelectDecoys(rand.New(rand.NewSource(time.Now().UnixNano())), indx, maxIndx)
-	if err != nil {
-		panic(err)
-	}
+	return nil
+}
 
-	keyOffset, err := BuildKeyOffsets(ring)
-	if err != nil {
-		return fmt.Errorf("failed to build key offsets: %w", err)
-	}
-	keyOffset = mockOffset
+// writeInput2: кольцо уже выбрано (UnsignedInput), здесь key image и маска входа —
+// то, для чего нужен spend key
+func (t *Transaction) writeInput2(in Input, ui UnsignedInput) error {
+	out := in.Output
+	vout := out.OutputIndex
 
-	mixins, OrderIndx, err := GetMixins(keyOffset, indx)
-	if err != nil {
-		return fmt.Errorf("Get Mixins Error: %w", err)
+	indices := make([]uint64, len(ui.Ring))
+	mixins := make([]Mixin, len(ui.Ring))
+	for i, m := range ui.Ring {
+		indices[i] = m.GlobalIndex
+		mixins[i] = Mixin{Dest: m.Key, Mask: m.Mask}
 	}
-
-	privViewKeyBytes, err := hexTo32(prm["privateViewKey"].(string)) // correct ✅
+	keyOffset, err := BuildKeyOffsets(indices)
 	if err != nil {
-		return fmt.Errorf("failed to decode private view key: %w", err)
+		return fmt.Errorf("failed to build key offsets: %w", err)
 	}
 
-	pubSpendKey, _, err := DecodeAddress(prm["address"].(string)) // correct ✅
+	pubSpendKey, _, err := DecodeAddress(out.Address) // correct ✅
 	if err != nil {
 		return fmt.Errorf("failed to decode address: %w", err)
 	}
 
-	extra, err := hex.DecodeString(prm["extra"].(string)) // correct ✅
-	if err != nil {
-		return fmt.Errorf("failed to decode extra: %w", err)
-	}
-
-	txPubKey, _, _, _, err := parseTxExtra(extra)
-	if err != nil {
-		return fmt.Errorf("failed to extract tx public key: %w", err)
+	// выход на субадрес тратится ключом b + m, D = (b + m)G
+	mPubSpendKey := Key(pubSpendKey)
+	mSecSpendKey := in.SpendKey
+	if out.Subaddress != (SubaddressIndex{}) {
+		m, err := subaddressSecret(&in.ViewKey, out.Subaddress)
+		if err != nil {
+			return err
+		}
+		mKey := Key(m.Bytes())
+		ScAdd(&mSecSpendKey, &mSecSpendKey, &mKey)
+		mPubSpendKey = *mSecSpendKey.PubKey()
 	}
 
-	mPrivViewKey := Key(privViewKeyBytes)
-	mPubSpendKey := Key(pubSpendKey)
-	mTxPubKey := Key(txPubKey)
-	mSecSpendKey, err := ParseKeyFromHex(prm["privateSpendKey"].(string))
-	keyImage, derivedPriKey, err := CreateKeyImage(&mPubSpendKey, &mSecSpendKey, &mPrivViewKey, &mTxPubKey, uint64(vout))
+	mPrivViewKey := in.ViewKey
+	mTxPubKey := Key(out.TxPubKey)
+	keyImage, derivedPriKey, err := CreateKeyImage(&mPubSpendKey, &mSecSpendKey, &mPrivViewKey, &mTxPubKey, vout)
 	if err != nil {
 		return fmt.Errorf("failed to create key image using moneroutil: %w", err)
 	}
+	if out.OutputKey != (Hash{}) && *derivedPriKey.PubKey() != Key(out.OutputKey) {
+		return fmt.Errorf("output %x:%d: keys do not match output key", out.TxHash, vout)
+	}
 
-	inputMask, err := generateBulletproofPlusMask(txPubKey, privViewKeyBytes, uint64(vout))
+	inputMask, err := generateBulletproofPlusMask(out.TxPubKey[:], in.ViewKey[:], vout)
 	if err != nil {
 		return fmt.Errorf("failed to generate mask: %w", err)
 	}
 
-	val, ok := prm["amount"].(float64)
-	if !ok {
-		return fmt.Errorf("failed to get amount of input")
+	// кольцо пришло с онлайн машины: настоящий участник должен быть нашим выходом
+	if ui.RealIndex < 0 || ui.RealIndex >= len(ui.Ring) {
+		return fmt.Errorf("real index %d is out of ring of %d", ui.RealIndex, len(ui.Ring))
+	}
+	real := ui.Ring[ui.RealIndex]
+	if real.Key != Hash(*derivedPriKey.PubKey()) {
+		return fmt.Errorf("ring member %d is not output %x:%d", ui.RealIndex, out.TxHash, vout)
+	}
+	if commitment, err := CalcCommitment(uint64(out.Amount), inputMask); err != nil || commitment != real.Mask {
+		return fmt.Errorf("output %x:%d: commitment does not match amount %s", out.TxHash, vout, out.Amount)
 	}
-	_ = val
 
 	t.VinCount += 1
 	t.Inputs = append(t.Inputs, TxInput{
-		// Amount:     uint64(val * 1e12),
 		Type:       0x02,
 		KeyOffsets: keyOffset,
 		KeyImage:   keyImage.ToBytes(),
-		Address:    prm["address"].(string),
-		Mixins:     *mixins,
-		OrderIndx:  *OrderIndx,
+		Address:    out.Address,
+		Mixins:     mixins,
+		OrderIndx:  ui.RealIndex,
 		InSk: Mixin{
 			Dest: derivedPriKey.ToBytes(),
 			Mask: inputMask,
@@ -244,25 +209,36 @@ func (t *Transaction) writeInput2(prm TxPrm) error {
 	return nil
 }
 
-func (t *Transaction) writeOutput2(prm TxPrm) error {
-	// Implementation for writing output goes here
+// writeOutput2: derivation выхода. Сдача выводится через свой view ключ
+// (a*R — так её и найдёт наш скан), субадрес при дополнительных ключах — r_i*C,
+// остальные — r*A.
+func (t *Transaction) writeOutput2(d Destination) error {
 	currentIndex := t.VoutCount
 
-	pubSpendKey, pubViewKey, err := DecodeAddress(prm["address"].(string)) // correct ✅
+	p, err := ParseAddress(d.Address) // correct ✅
 	if err != nil {
 		return fmt.Errorf("failed to decode address: %w", err)
 	}
+	pubSpendKey := p.PubSpend
+
+	var pub, sec Key
+	switch {
+	case d.viewKey != nil:
+		pub, sec = Key(t.PublicKey), *d.viewKey
+	case p.Type == AddressSubaddress && len(t.AdditionalSecretKeys) > 0:
+		pub, sec = p.PubView, Key(t.AdditionalSecretKeys[currentIndex])
+	default:
+		pub, sec = p.PubView, Key(t.SecretKey)
+	}
 
-	viewTag, err := DeriveViewTag(pubViewKey[:], t.SecretKey[:], currentIndex) // correct ✅
+	viewTag, err := DeriveViewTag(pub[:], sec[:], currentIndex) // correct ✅
 	if err != nil {
 		return fmt.Errorf("failed to derive view tag: %w", err)
 	}
 
-	mPubViewKey := Key(pubViewKey)
-	mTxSecretKey := Key(t.SecretKey)
 	mPubSpendKey := Key(pubSpendKey)
 
-	derivation, ok := GenerateKeyDerivation(&mPubViewKey, &mTxSecretKey)
+	derivation, ok := GenerateKeyDerivation(&pub, &sec)
 	if !ok {
 		return fmt.Errorf("generate key derivation failed")
 	}
@@ -272,12 +248,12 @@ func (t *Transaction) writeOutput2(prm TxPrm) error {
 		return fmt.Errorf("derive public key failed")
 	}
 
-	amnt, err := EncryptRctAmount(prm["amount"].(float64), pubViewKey[:], t.SecretKey[:], currentIndex)
+	amnt, err := EncryptRctAmount(d.Amount, pub[:], sec[:], currentIndex)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt amount: %w", err)
 	}
 
-	blind, outPk, err := CalcOutPk(prm["amount"].(float64), pubViewKey[:], pubSpendKey[:], t.SecretKey[:], currentIndex)
+	blind, outPk, err := CalcOutPk(d.Amount, pub[:], pubSpendKey[:], sec[:], currentIndex)
 	if err != nil {
 		return fmt.Errorf("failed to calculate output public key: %w", err)
 	}
@@ -298,12 +274,12 @@ func (t *Transaction) writeOutput2(prm TxPrm) error {
 	})
 
 	t.BlindScalars = append(t.BlindScalars, blind)
-	t.BlindAmounts = append(t.BlindAmounts, uint64(prm["amount"].(float64)*1e12))
+	t.BlindAmounts = append(t.BlindAmounts, uint64(d.Amount))
 
 	return nil
 }
 
-func (t *Transaction) SignTransaction(tx1 Transaction) error {
+func (t *Transaction) SignTransaction() error {
 	Bpp, err := t.signBpp()
 	if err != nil {
 		return fmt.Errorf("failed to sign bpp: %w", err)
@@ -316,6 +292,10 @@ func (t *Transaction) SignTransaction(tx1 Transaction) error {
 	}
 	t.RctSigPrunable.PseudoOuts = PseudoOuts
 
+	if err := t.VerifyBalance(); err != nil {
+		return fmt.Errorf("transaction does not balance: %w", err)
+	}
+
 	CLSAGs, err := t.signCLSAGs()
 	if err != nil {
 		return fmt.Errorf("failed to sign CLSAGs: %w", err)
@@ -347,51 +327,3 @@ func (t *Transaction) PrefixHash() Hash {
 
 	return Hash(hash)
 }
-
-func SelectDecoys(rng *rand.Rand, realGlobalIndex uint64, maxGlobalIndex uint64) ([]uint64, error) {
-
-	ringSize := 16
-
-	selected := make(map[uint64]struct{})
-	selected[realGlobalIndex] = struct{}{}
-
-	for len(selected) < ringSize {
-		// 1. возраст
-		ageDays := sampleOutputAgeDays(rng)
-		ageBlocks := uint64(ageDays * BlocksPerDay)
-
-		if ageBlocks >= maxGlobalIndex {
-			continue
-		}
-
-		// 2. перевод возраста → индекс
-		target := maxGlobalIndex - ageBlocks
-
-		// 3. uniform смещение (ВАЖНО)
-		offset := uint64(rng.Int63n(int64(target + 1)))
-		gi := offset
-
-		// 4. проверки
-		if gi == realGlobalIndex {
-			continue
-		}
-		if _, exists := selected[gi]; exists {
-			continue
-		}
-
-		selected[gi] = struct{}{}
-	}
-
-	// 5. финальный массив
-	ring := make([]uint64, 0, ringSize)
-	for gi := range selected {
-		ring = append(ring, gi)
-	}
-
-	// 6. перемешать кольцо
-	rng.Shuffle(len(ring), func(i, j int) {
-		ring[i], ring[j] = ring[j], ring[i]
-	})
-
-	return ring, nil
-}
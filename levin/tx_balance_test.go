@@ -0,0 +1,85 @@
+package levin
+
+import (
+	"testing"
+
+	"filippo.io/edwards25519"
+)
+
+func TestVerifyBalanceDumps(t *testing.T) {
+	for _, tx := range testTxs(t) {
+		if err := tx.VerifyBalance(); err != nil {
+			t.Errorf("tx %x: %v", tx.Hash, err)
+		}
+	}
+}
+
+// balancedTx: входы 5+3, выходы 6+1, комиссия 1; маски псевдовыходов в сумме равны маскам выходов
+func balancedTx(t *testing.T) *Transaction {
+	t.Helper()
+	scalar := func(k *Key) *edwards25519.Scalar {
+		s, err := new(edwards25519.Scalar).SetCanonicalBytes(k[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	y1, y2, z1 := scalar(RandomScalar()), scalar(RandomScalar()), scalar(RandomScalar())
+	z2 := new(edwards25519.Scalar).Add(y1, y2)
+	z2.Subtract(z2, z1)
+
+	commit := func(amount uint64, mask *edwards25519.Scalar) Hash {
+		c, err := CalcCommitment(amount, [32]byte(mask.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tx := NewEmptyTransaction()
+	tx.Inputs = make([]TxInput, 2)
+	tx.Outputs = make([]TxOutput, 2)
+	tx.RctSignature.Type = uint64(RCTTypeBulletproofPlus)
+	tx.RctSignature.TxnFee = 1
+	tx.RctSignature.OutPk = []Hash{commit(6, y1), commit(1, y2)}
+	tx.RctSigPrunable = &RctSigPrunable{PseudoOuts: []Hash{commit(5, z1), commit(3, z2)}}
+	return tx
+}
+
+func TestVerifyBalance(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(tx *Transaction)
+		ok   bool
+	}{
+		{"balanced", func(tx *Transaction) {}, true},
+		{"fee", func(tx *Transaction) { tx.RctSignature.TxnFee = 2 }, false},
+		{"swapped outPk", func(tx *Transaction) {
+			tx.RctSignature.OutPk[0] = tx.RctSigPrunable.PseudoOuts[0]
+		}, false},
+		{"missing pseudoOut", func(tx *Transaction) {
+			tx.RctSigPrunable.PseudoOuts = tx.RctSigPrunable.PseudoOuts[:1]
+		}, false},
+		{"pruned", func(tx *Transaction) { tx.Pruned = true }, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tx := balancedTx(t)
+			tc.edit(tx)
+			err := tx.VerifyBalance()
+			if tc.ok != (err == nil) {
+				t.Fatalf("ok %v, err %v", tc.ok, err)
+			}
+		})
+	}
+}
+
+func TestVerifyBalanceTamperedDumpFee(t *testing.T) {
+	tx := *testTxs(t)[0]
+	rct := *tx.RctSignature
+	rct.TxnFee++
+	tx.RctSignature = &rct
+	if err := tx.VerifyBalance(); err == nil {
+		t.Fatalf("tx %x: balance holds with fee+1", tx.Hash)
+	}
+}
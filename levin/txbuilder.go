@@ -0,0 +1,384 @@
+package levin
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Input — наш выход, который тратим, и ключи кошелька-владельца
+type Input struct {
+	Output   OwnedOutput
+	SpendKey Key
+	ViewKey  Key
+}
+
+// Destination — получатель и сумма в атомарных единицах
+type Destination struct {
+	Address string
+	Amount  Amount
+
+	change  bool
+	viewKey *Key // приватный view ключ адреса сдачи, derivation = a*R
+}
+
+// максимум выходов в одном агрегированном Bulletproof+
+const maxTxOutputs = maxM
+
+// TxBuilder собирает и подписывает транзакцию из типизированных входов и получателей.
+// Все ошибки в параметрах возвращаются из Build, без паник.
+type TxBuilder struct {
+	inputs        []Input
+	destinations  []Destination
+	changeAddress string
+	fee           Amount
+	feeSource     FeeSource // nil — комиссия задана SetFee
+	priority      FeePriority
+	ringSource    RingMemberSource
+}
+
+func NewTxBuilder() *TxBuilder {
+	return &TxBuilder{}
+}
+
+func (b *TxBuilder) AddInput(in Input) *TxBuilder {
+	b.inputs = append(b.inputs, in)
+	return b
+}
+
+func (b *TxBuilder) AddDestination(address string, amount Amount) *TxBuilder {
+	b.destinations = append(b.destinations, Destination{Address: address, Amount: amount})
+	return b
+}
+
+// SetChangeAddress: куда вернуть остаток входов сверх получателей и комиссии
+func (b *TxBuilder) SetChangeAddress(address string) *TxBuilder {
+	b.changeAddress = address
+	return b
+}
+
+// SetFee: фиксированная комиссия, отменяет SetFeeSource
+func (b *TxBuilder) SetFee(fee Amount) *TxBuilder {
+	b.fee = fee
+	b.feeSource = nil
+	return b
+}
+
+// SetFeeSource: комиссия считается по весу транзакции и fee за байт из src
+func (b *TxBuilder) SetFeeSource(src FeeSource, priority FeePriority) *TxBuilder {
+	b.feeSource = src
+	b.priority = priority
+	return b
+}
+
+// SetRingMemberSource: откуда брать глобальные индексы и участников колец
+func (b *TxBuilder) SetRingMemberSource(src RingMemberSource) *TxBuilder {
+	b.ringSource = src
+	return b
+}
+
+// сколько раз пересчитываем комиссию и сдачу, пока они не перестанут меняться
+const maxFeeIterations = 8
+
+// Validate проверяет адреса, ключи и баланс и возвращает получателей вместе со сдачей
+// и комиссию. С источником комиссии она подбирается по оценке веса транзакции.
+func (b *TxBuilder) Validate() ([]Destination, Amount, error) {
+	est, err := b.feeEstimate()
+	if err != nil {
+		return nil, 0, err
+	}
+	return b.validateFee(est)
+}
+
+func (b *TxBuilder) validateFee(est *FeeEstimate) ([]Destination, Amount, error) {
+	if est == nil {
+		destinations, err := b.validate(b.fee)
+		return destinations, b.fee, err
+	}
+	return b.settleFee(*est, 0)
+}
+
+func (b *TxBuilder) feeEstimate() (*FeeEstimate, error) {
+	if b.feeSource == nil {
+		return nil, nil
+	}
+	est, err := b.feeSource.FeeEstimate()
+	if err != nil {
+		return nil, fmt.Errorf("fee estimate: %w", err)
+	}
+	return &est, nil
+}
+
+// settleFee: комиссия зависит от числа выходов (есть ли сдача), сдача — от комиссии.
+// Комиссия только растёт, так что цикл сходится за пару итераций.
+func (b *TxBuilder) settleFee(est FeeEstimate, fee Amount) ([]Destination, Amount, error) {
+	for range maxFeeIterations {
+		destinations, err := b.validate(fee)
+		if err != nil {
+			return nil, 0, err
+		}
+		needed, err := est.Fee(estimateWeight(len(b.inputs), destinations), b.priority)
+		if err != nil {
+			return nil, 0, err
+		}
+		if needed <= fee {
+			return destinations, fee, nil
+		}
+		fee = needed
+	}
+	return nil, 0, fmt.Errorf("fee did not settle after %d iterations", maxFeeIterations)
+}
+
+// estimateWeight: вес до построения; extra — pubkey, payment id и дополнительные ключи
+func estimateWeight(inputs int, destinations []Destination) uint64 {
+	outputs := max(len(destinations), 2)
+
+	std, sub := map[string]bool{}, map[string]bool{}
+	for _, d := range destinations {
+		if d.change {
+			continue
+		}
+		if p, err := ParseAddress(d.Address); err == nil && p.Type == AddressSubaddress {
+			sub[d.Address] = true
+		} else {
+			std[d.Address] = true
+		}
+	}
+	extra := 33 + 11 // pubkey, зашифрованный payment id
+	if len(sub) > 0 && (len(std) > 0 || len(sub) > 1) {
+		extra += 2 + 32*outputs
+	}
+	return EstimateTxWeight(inputs, outputs, DefaultRingSize, extra)
+}
+
+// validate: сдача должна принадлежать кошельку входов, её выход выводится из его view ключа
+func (b *TxBuilder) validate(fee Amount) ([]Destination, error) {
+	if len(b.inputs) == 0 {
+		return nil, fmt.Errorf("no inputs")
+	}
+	if b.ringSource == nil {
+		return nil, fmt.Errorf("no ring member source")
+	}
+	if len(b.destinations) == 0 {
+		return nil, fmt.Errorf("no destinations")
+	}
+
+	var in Amount
+	seen := make(map[outpoint]bool, len(b.inputs))
+	for i, input := range b.inputs {
+		o := input.Output
+		op := outpoint{o.TxHash, o.OutputIndex}
+		if seen[op] {
+			return nil, fmt.Errorf("input %d: output %x:%d is spent twice", i, o.TxHash, o.OutputIndex)
+		}
+		seen[op] = true
+
+		if o.Amount == 0 {
+			return nil, fmt.Errorf("input %d: zero amount", i)
+		}
+		if o.TxPubKey == (Hash{}) {
+			return nil, fmt.Errorf("input %d: tx public key is missing", i)
+		}
+		p, err := ParseAddress(o.Address)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		if p.Type != AddressStandard {
+			return nil, fmt.Errorf("input %d: owner must be the main wallet address, got %s", i, p.Type)
+		}
+		if *input.ViewKey.PubKey() != p.PubView {
+			return nil, fmt.Errorf("input %d: private view key does not match %s", i, o.Address)
+		}
+		// без spend key — view-only кошелёк, подпись будет офлайн
+		if input.SpendKey != (Key{}) && *input.SpendKey.PubKey() != p.PubSpend {
+			return nil, fmt.Errorf("input %d: private spend key does not match %s", i, o.Address)
+		}
+
+		var ok bool
+		if in, ok = in.Add(o.Amount); !ok {
+			return nil, fmt.Errorf("inputs amount overflows")
+		}
+	}
+
+	out := fee
+	var paymentID []byte
+	addresses := make(map[string]bool)
+	for i, d := range b.destinations {
+		if d.Amount == 0 {
+			return nil, fmt.Errorf("destination %d: zero amount", i)
+		}
+		p, err := ParseAddress(d.Address)
+		if err != nil {
+			return nil, fmt.Errorf("destination %d: %w", i, err)
+		}
+		addresses[d.Address] = true
+		if p.Type == AddressIntegrated {
+			paymentID = p.PaymentID
+		}
+
+		var ok bool
+		if out, ok = out.Add(d.Amount); !ok {
+			return nil, fmt.Errorf("destinations amount overflows")
+		}
+	}
+	// payment id шифруется view ключом получателя, второго получателя в транзакции быть не может
+	if paymentID != nil && len(addresses) > 1 {
+		return nil, fmt.Errorf("an integrated address must be the only destination")
+	}
+	if in < out {
+		return nil, fmt.Errorf("insufficient inputs: have %s, need %s (fee %s) XMR", in, out, fee)
+	}
+
+	destinations := append([]Destination(nil), b.destinations...)
+	if change := in - out; change > 0 {
+		if b.changeAddress == "" {
+			return nil, fmt.Errorf("inputs exceed destinations and fee by %s XMR but no change address is set", change)
+		}
+		viewKey := b.inputs[0].ViewKey
+		if err := checkOwnAddress(b.changeAddress, viewKey); err != nil {
+			return nil, fmt.Errorf("change address: %w", err)
+		}
+		destinations = append(destinations, Destination{Address: b.changeAddress, Amount: change, change: true, viewKey: &viewKey})
+	}
+	if len(destinations) > maxTxOutputs {
+		return nil, fmt.Errorf("too many outputs: %d, max %d", len(destinations), maxTxOutputs)
+	}
+	return destinations, nil
+}
+
+// Build собирает и подписывает транзакцию со свежим случайным tx ключом.
+// С источником комиссии сверяет её с весом готовой транзакции и при нехватке пересобирает.
+func (b *TxBuilder) Build() (*Transaction, error) {
+	est, err := b.feeEstimate()
+	if err != nil {
+		return nil, err
+	}
+	destinations, fee, err := b.validateFee(est)
+	if err != nil {
+		return nil, err
+	}
+	picker, err := b.picker()
+	if err != nil {
+		return nil, err
+	}
+	if est == nil {
+		return b.build(destinations, fee, picker)
+	}
+
+	for range maxFeeIterations {
+		tx, err := b.build(destinations, fee, picker)
+		if err != nil {
+			return nil, err
+		}
+		needed, err := est.Fee(tx.Weight(), b.priority)
+		if err != nil {
+			return nil, err
+		}
+		if needed <= fee {
+			return tx, nil
+		}
+		if destinations, fee, err = b.settleFee(*est, needed); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("fee did not settle after %d iterations", maxFeeIterations)
+}
+
+// BuildUnsigned: входы, кольца, получатели и комиссия для офлайн подписи
+// (UnsignedTx.Sign). Spend key во входах не нужен. Комиссия — по оценке веса,
+// готовую транзакцию сверить не с чем.
+func (b *TxBuilder) BuildUnsigned() (*UnsignedTx, error) {
+	destinations, fee, err := b.Validate()
+	if err != nil {
+		return nil, err
+	}
+	picker, err := b.picker()
+	if err != nil {
+		return nil, err
+	}
+	return b.unsigned(destinations, fee, picker)
+}
+
+func (b *TxBuilder) picker() (*GammaPicker, error) {
+	dist, err := b.ringSource.OutputDistribution()
+	if err != nil {
+		return nil, fmt.Errorf("output distribution: %w", err)
+	}
+	return NewGammaPicker(dist, nil)
+}
+
+func (b *TxBuilder) build(destinations []Destination, fee Amount, picker *GammaPicker) (*Transaction, error) {
+	u, err := b.unsigned(destinations, fee, picker)
+	if err != nil {
+		return nil, err
+	}
+	return u.sign(b.inputs)
+}
+
+// unsigned: всё, что не требует spend key
+func (b *TxBuilder) unsigned(destinations []Destination, fee Amount, picker *GammaPicker) (*UnsignedTx, error) {
+	u := &UnsignedTx{Version: UnsignedTxVersion, Fee: fee}
+	for i, in := range b.inputs {
+		ui, err := selectInputRing(in.Output, picker, b.ringSource)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		u.Inputs = append(u.Inputs, ui)
+	}
+	for _, d := range destinations {
+		u.Destinations = append(u.Destinations, UnsignedDestination{Address: d.Address, Amount: d.Amount, Change: d.change})
+	}
+	return u, nil
+}
+
+// sortInputs: консенсус требует входы по убыванию key image
+func (t *Transaction) sortInputs() {
+	order := make([]int, len(t.Inputs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return bytes.Compare(t.Inputs[order[i]].KeyImage[:], t.Inputs[order[j]].KeyImage[:]) > 0
+	})
+
+	inputs := make([]TxInput, len(order))
+	pinputs := make([]Input, len(order))
+	for i, o := range order {
+		inputs[i], pinputs[i] = t.Inputs[o], t.PInputs[o]
+	}
+	t.Inputs, t.PInputs = inputs, pinputs
+}
+
+// checkOwnAddress: адрес — основной или субадрес кошелька с view ключом a
+func checkOwnAddress(address string, viewKey Key) error {
+	p, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	switch p.Type {
+	case AddressStandard:
+		if *viewKey.PubKey() == p.PubView {
+			return nil
+		}
+	case AddressSubaddress:
+		D := p.PubSpend
+		if ScalarMult(&viewKey, &D) == p.PubView {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s does not belong to the inputs wallet", address)
+}
+
+// dummyDestination: нулевой выход на одноразовый адрес в сети получателя
+func dummyDestination(address string) (Destination, error) {
+	p, err := ParseAddress(address)
+	if err != nil {
+		return Destination{}, err
+	}
+	spend, view := RandomScalar(), RandomScalar()
+	addr, err := EncodeAddress(p.Network, AddressStandard, *spend.PubKey(), *view.PubKey(), nil)
+	if err != nil {
+		return Destination{}, err
+	}
+	return Destination{Address: addr, change: true, viewKey: view}, nil
+}
@@ -0,0 +1,424 @@
+package levin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"filippo.io/edwards25519"
+)
+
+// Доказательства платежа как в wallet2: get_tx_proof / check_tx_proof / check_tx_key.
+// OutProof подписывает отправитель секретным ключом транзакции r, InProof —
+// получатель своим view ключом a. Оба доказывают общий секрет r*A = a*R, из
+// которого проверяющий сам расшифровывает суммы выходов на адрес.
+
+const (
+	OutProofHeader   = "OutProofV2"
+	InProofHeader    = "InProofV2"
+	outProofHeaderV1 = "OutProofV1"
+	inProofHeaderV1  = "InProofV1"
+
+	txProofSecretLen = 44 // base58 от 32 байт
+	txProofSigLen    = 88 // base58 от (c, r)
+)
+
+// TxProofResult — итог проверки доказательства или tx ключа
+type TxProofResult struct {
+	Outbound bool   // OutProof: подписал отправитель
+	Received Amount // сумма выходов tx на адрес
+	Outputs  []uint64
+}
+
+// GetOutProof: доказательство отправителя. txKey и additionalKeys — секретные ключи
+// транзакции (Transaction.SecretKey и AdditionalSecretKeys, строка tx_key в SignedTx).
+func GetOutProof(txHash Hash, address, message string, txKey Key, additionalKeys []Key) (string, error) {
+	p, err := ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	prefixHash := txProofPrefixHash(txHash, message)
+	A := p.PubView
+	var B *Key
+	if p.Type == AddressSubaddress {
+		B = &p.PubSpend
+	}
+
+	var sb strings.Builder
+	sb.WriteString(OutProofHeader)
+	for i, r := range append([]Key{txKey}, additionalKeys...) {
+		// R = rG, для субадреса R = rD
+		var R Key
+		if B != nil {
+			R = ScalarMult(&r, B)
+		} else {
+			R = *r.PubKey()
+		}
+		shared := ScalarMult(&r, &A)
+		sig, err := generateTxProof(prefixHash, R, A, B, shared, r)
+		if err != nil {
+			return "", fmt.Errorf("tx key %d: %w", i, err)
+		}
+		sb.WriteString(encodeMoneroBase58(shared[:]))
+		sb.WriteString(encodeMoneroBase58(sig[:]))
+	}
+	return sb.String(), nil
+}
+
+// GetInProof: доказательство получателя. viewKey — приватный view ключ кошелька,
+// которому принадлежит address (основной адрес или субадрес).
+func GetInProof(tx *Transaction, address, message string, viewKey Key) (string, error) {
+	p, err := ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	if err := checkOwnAddress(address, viewKey); err != nil {
+		return "", err
+	}
+	txPubKeys, err := txProofPubKeys(tx)
+	if err != nil {
+		return "", err
+	}
+	prefixHash := txProofPrefixHash(tx.Hash, message)
+	var B *Key
+	if p.Type == AddressSubaddress {
+		B = &p.PubSpend
+	}
+
+	var sb strings.Builder
+	sb.WriteString(InProofHeader)
+	for i, R := range txPubKeys {
+		// роли R и A меняются местами: доказывается знание a, C = aG или aD
+		shared := ScalarMult(&viewKey, &R)
+		sig, err := generateTxProof(prefixHash, p.PubView, R, B, shared, viewKey)
+		if err != nil {
+			return "", fmt.Errorf("tx public key %d: %w", i, err)
+		}
+		sb.WriteString(encodeMoneroBase58(shared[:]))
+		sb.WriteString(encodeMoneroBase58(sig[:]))
+	}
+	return sb.String(), nil
+}
+
+// CheckTxProof проверяет OutProof или InProof (V1 и V2) и возвращает, сколько
+// address получил в tx. Ошибка — доказательство не сходится.
+func CheckTxProof(tx *Transaction, address, message, proof string) (*TxProofResult, error) {
+	p, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	proof = strings.TrimSpace(proof)
+
+	var outbound bool
+	version := 2
+	header := ""
+	for _, h := range []struct {
+		header   string
+		outbound bool
+		version  int
+	}{
+		{OutProofHeader, true, 2},
+		{InProofHeader, false, 2},
+		{outProofHeaderV1, true, 1},
+		{inProofHeaderV1, false, 1},
+	} {
+		if strings.HasPrefix(proof, h.header) {
+			header, outbound, version = h.header, h.outbound, h.version
+			break
+		}
+	}
+	if header == "" {
+		return nil, fmt.Errorf("proof must start with %s or %s", OutProofHeader, InProofHeader)
+	}
+
+	txPubKeys, err := txProofPubKeys(tx)
+	if err != nil {
+		return nil, err
+	}
+	body := proof[len(header):]
+	const sigLen = txProofSecretLen + txProofSigLen
+	if len(body) != sigLen*len(txPubKeys) {
+		return nil, fmt.Errorf("proof has %d bytes of signatures, expected %d for %d tx public keys", len(body), sigLen*len(txPubKeys), len(txPubKeys))
+	}
+
+	prefixHash := txProofPrefixHash(tx.Hash, message)
+	var B *Key
+	if p.Type == AddressSubaddress {
+		B = &p.PubSpend
+	}
+
+	// общий секрет S даёт derivation 8*S, то есть пара (S, 1) для DecodeRctAmount
+	one := Key{1}
+	keys := make([]*derivationKeys, len(txPubKeys))
+	var good bool
+	for i, R := range txPubKeys {
+		chunk := body[i*sigLen : (i+1)*sigLen]
+		sharedBytes, err := decodeMoneroBase58(chunk[:txProofSecretLen])
+		if err != nil || len(sharedBytes) != 32 {
+			return nil, fmt.Errorf("signature %d: invalid shared secret encoding", i)
+		}
+		sigBytes, err := decodeMoneroBase58(chunk[txProofSecretLen:])
+		if err != nil || len(sigBytes) != 64 {
+			return nil, fmt.Errorf("signature %d: invalid signature encoding", i)
+		}
+		shared, sig := Key(sharedBytes), [64]byte(sigBytes)
+
+		var ok bool
+		if outbound {
+			ok = checkTxProof(prefixHash, R, p.PubView, B, shared, sig, version)
+		} else {
+			ok = checkTxProof(prefixHash, p.PubView, R, B, shared, sig, version)
+		}
+		if ok {
+			keys[i] = &derivationKeys{pub: shared, sec: one}
+			good = true
+		}
+	}
+	if !good {
+		return nil, fmt.Errorf("proof signature is invalid")
+	}
+
+	received, outputs, err := txReceived(tx, p, keys[0], keys[1:])
+	if err != nil {
+		return nil, err
+	}
+	return &TxProofResult{Outbound: outbound, Received: received, Outputs: outputs}, nil
+}
+
+// CheckTxKey: сколько address получил в tx, по секретным ключам транзакции.
+// Ключи не подписаны, так что это проверка для отправителя, а не доказательство.
+func CheckTxKey(tx *Transaction, address string, txKey Key, additionalKeys []Key) (*TxProofResult, error) {
+	p, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := tx.ParseExtra()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse extra: %w", err)
+	}
+	if n := len(extra.AdditionalPubKeys()); n != len(additionalKeys) {
+		return nil, fmt.Errorf("tx has %d additional public keys, got %d additional tx keys", n, len(additionalKeys))
+	}
+
+	// derivation = 8*r*A = 8*a*R
+	main := &derivationKeys{pub: p.PubView, sec: txKey}
+	additional := make([]*derivationKeys, len(additionalKeys))
+	for i, r := range additionalKeys {
+		additional[i] = &derivationKeys{pub: p.PubView, sec: r}
+	}
+	received, outputs, err := txReceived(tx, p, main, additional)
+	if err != nil {
+		return nil, err
+	}
+	return &TxProofResult{Outbound: true, Received: received, Outputs: outputs}, nil
+}
+
+// FormatTxKey: r и дополнительные ключи одной hex строкой, как get_tx_key в wallet2
+func FormatTxKey(txKey Hash, additionalKeys []Hash) string {
+	var sb strings.Builder
+	sb.WriteString(hex.EncodeToString(txKey[:]))
+	for _, k := range additionalKeys {
+		sb.WriteString(hex.EncodeToString(k[:]))
+	}
+	return sb.String()
+}
+
+// ParseTxKey: обратное к FormatTxKey
+func ParseTxKey(s string) (Key, []Key, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || len(s)%64 != 0 {
+		return Key{}, nil, fmt.Errorf("tx key must be a multiple of 64 hex characters, got %d", len(s))
+	}
+	var keys []Key
+	for i := 0; i < len(s); i += 64 {
+		k, err := ParseKeyFromHex(s[i : i+64])
+		if err != nil {
+			return Key{}, nil, fmt.Errorf("tx key %d: %w", i/64, err)
+		}
+		if _, err := new(edwards25519.Scalar).SetCanonicalBytes(k[:]); err != nil {
+			return Key{}, nil, fmt.Errorf("tx key %d is not a scalar: %w", i/64, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys[0], keys[1:], nil
+}
+
+/*--- internals ---*/
+
+// derivationKeys: derivation = 8*sec*pub
+type derivationKeys struct {
+	pub, sec Key
+}
+
+// txReceived — check_tx_key_helper: выходы tx на адрес p и их сумма. Сумма
+// сверяется с коммитментом, иначе отправитель мог подменить зашифрованную сумму.
+// main или additional[i] равны nil, если их подпись не сошлась.
+func txReceived(tx *Transaction, p *ParsedAddress, main *derivationKeys, additional []*derivationKeys) (Amount, []uint64, error) {
+	var (
+		received Amount
+		outputs  []uint64
+	)
+	for n, out := range tx.Outputs {
+		index := uint64(n)
+		var found *derivationKeys
+		for _, k := range []*derivationKeys{main, at(additional, n)} {
+			if k == nil {
+				continue
+			}
+			derivation, ok := GenerateKeyDerivation(&k.pub, &k.sec)
+			if !ok {
+				continue
+			}
+			if P, ok := DerivePublicKey(&derivation, index, &p.PubSpend); ok && Hash(P) == out.Target {
+				found = k
+				break
+			}
+		}
+		if found == nil {
+			continue
+		}
+
+		amount := Amount(out.Amount)
+		if tx.Version >= 2 {
+			if tx.RctSignature == nil || n >= len(tx.RctSignature.EcdhInfo) || n >= len(tx.RctSignature.OutPk) {
+				return 0, nil, fmt.Errorf("output %d: no RingCT data", n)
+			}
+			var err error
+			if amount, err = DecodeRctAmount(found.pub[:], found.sec[:], index, tx.RctSignature.EcdhInfo[n].Amount[:]); err != nil {
+				return 0, nil, fmt.Errorf("output %d: %w", n, err)
+			}
+			mask, err := generateBulletproofPlusMask(found.pub[:], found.sec[:], index)
+			if err != nil {
+				return 0, nil, fmt.Errorf("output %d: %w", n, err)
+			}
+			if C, err := CalcCommitment(uint64(amount), mask); err != nil || C != tx.RctSignature.OutPk[n] {
+				return 0, nil, fmt.Errorf("output %d: decoded amount %s XMR does not match its commitment", n, amount)
+			}
+		}
+
+		var ok bool
+		if received, ok = received.Add(amount); !ok {
+			return 0, nil, fmt.Errorf("received amount overflows")
+		}
+		outputs = append(outputs, index)
+	}
+	return received, outputs, nil
+}
+
+func at(keys []*derivationKeys, i int) *derivationKeys {
+	if i < len(keys) {
+		return keys[i]
+	}
+	return nil
+}
+
+// txProofPubKeys: R и дополнительные ключи из extra, по подписи на каждый
+func txProofPubKeys(tx *Transaction) ([]Key, error) {
+	extra, err := tx.ParseExtra()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse extra: %w", err)
+	}
+	R := extra.PubKey()
+	if R == nil {
+		return nil, fmt.Errorf("tx %x has no public key", tx.Hash)
+	}
+	keys := []Key{Key(R)}
+	for _, k := range extra.AdditionalPubKeys() {
+		keys = append(keys, Key(k))
+	}
+	return keys, nil
+}
+
+// txProofPrefixHash: H(txid || message)
+func txProofPrefixHash(txHash Hash, message string) Hash {
+	return Hash(keccak256(append(txHash[:], message...)))
+}
+
+// txProofV2Sep: cn_fast_hash("TXPROOF_V2"), разделитель домена в V2
+var txProofV2Sep = keccak256([]byte("TXPROOF_V2"))
+
+// txProofChallenge: V2 — Hs(msg || D || X || Y || sep || R || A || B), V1 — Hs(msg || D || X || Y)
+func txProofChallenge(prefixHash Hash, R, A Key, B *Key, D Key, X, Y *edwards25519.Point, version int) *edwards25519.Scalar {
+	data := [][]byte{prefixHash[:], D[:], X.Bytes(), Y.Bytes()}
+	if version == 2 {
+		var b Key // null_pkey без B
+		if B != nil {
+			b = *B
+		}
+		data = append(data, txProofV2Sep, R[:], A[:], b[:])
+	}
+	c := HashToScalar(data...)
+	s, _ := new(edwards25519.Scalar).SetCanonicalBytes(c[:])
+	return s
+}
+
+// generateTxProof — crypto::generate_tx_proof V2: знание r, при котором
+// R = rG (или rB) и D = rA. Подпись — (c, r') по 32 байта.
+func generateTxProof(prefixHash Hash, R, A Key, B *Key, D Key, secret Key) ([64]byte, error) {
+	var sig [64]byte
+	r, err := new(edwards25519.Scalar).SetCanonicalBytes(secret[:])
+	if err != nil {
+		return sig, fmt.Errorf("invalid secret key: %w", err)
+	}
+	Ap, err := new(edwards25519.Point).SetBytes(A[:])
+	if err != nil {
+		return sig, fmt.Errorf("invalid public key A: %w", err)
+	}
+	base := edwards25519.NewGeneratorPoint()
+	if B != nil {
+		if base, err = new(edwards25519.Point).SetBytes(B[:]); err != nil {
+			return sig, fmt.Errorf("invalid public key B: %w", err)
+		}
+	}
+	if Key(new(edwards25519.Point).ScalarMult(r, base).Bytes()) != R {
+		return sig, fmt.Errorf("secret key does not match R")
+	}
+	if Key(new(edwards25519.Point).ScalarMult(r, Ap).Bytes()) != D {
+		return sig, fmt.Errorf("secret key does not match D")
+	}
+
+	kKey := RandomScalar()
+	k, _ := new(edwards25519.Scalar).SetCanonicalBytes(kKey[:])
+	X := new(edwards25519.Point).ScalarMult(k, base)
+	Y := new(edwards25519.Point).ScalarMult(k, Ap)
+
+	c := txProofChallenge(prefixHash, R, A, B, D, X, Y, 2)
+	// r' = k - c*r
+	rr := new(edwards25519.Scalar).Subtract(k, new(edwards25519.Scalar).Multiply(c, r))
+	copy(sig[:32], c.Bytes())
+	copy(sig[32:], rr.Bytes())
+	return sig, nil
+}
+
+// checkTxProof — crypto::check_tx_proof: X = cR + r'G (или r'B), Y = cD + r'A, c == Hs(...)
+func checkTxProof(prefixHash Hash, R, A Key, B *Key, D Key, sig [64]byte, version int) bool {
+	points := make([]*edwards25519.Point, 0, 4)
+	for _, k := range []*Key{&R, &A, &D, B} {
+		if k == nil {
+			continue
+		}
+		p, err := new(edwards25519.Point).SetBytes(k[:])
+		if err != nil {
+			return false
+		}
+		points = append(points, p)
+	}
+	Rp, Ap, Dp := points[0], points[1], points[2]
+	base := edwards25519.NewGeneratorPoint()
+	if B != nil {
+		base = points[3]
+	}
+
+	c, err := new(edwards25519.Scalar).SetCanonicalBytes(sig[:32])
+	if err != nil {
+		return false
+	}
+	rr, err := new(edwards25519.Scalar).SetCanonicalBytes(sig[32:])
+	if err != nil {
+		return false
+	}
+
+	X := new(edwards25519.Point).Add(new(edwards25519.Point).ScalarMult(c, Rp), new(edwards25519.Point).ScalarMult(rr, base))
+	Y := new(edwards25519.Point).Add(new(edwards25519.Point).ScalarMult(c, Dp), new(edwards25519.Point).ScalarMult(rr, Ap))
+	return txProofChallenge(prefixHash, R, A, B, D, X, Y, version).Equal(c) == 1
+}
@@ -0,0 +1,155 @@
+package levin
+
+import (
+	"strings"
+	"testing"
+)
+
+type proofCase struct {
+	name      string
+	recipient *Account
+	address   string
+	amount    Amount
+	tx        *Transaction
+}
+
+// proofCases: стандартный адрес, субадрес с дополнительными ключами и
+// единственный получатель-субадрес (R = rD)
+func proofCases(t *testing.T) []proofCase {
+	t.Helper()
+	newAccount := func() *Account {
+		a, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	recipient, other := newAccount(), newAccount()
+	sub, err := recipient.SubaddressAddress(SubaddressIndex{Major: 0, Minor: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const amount = 3*XMR + 12345
+	return []proofCase{
+		{"standard", recipient, recipient.Address, amount, outputsTx(t,
+			Destination{Address: other.Address, Amount: XMR}, Destination{Address: recipient.Address, Amount: amount})},
+		{"subaddress", recipient, sub, amount, outputsTx(t,
+			Destination{Address: sub, Amount: amount}, Destination{Address: other.Address, Amount: XMR})},
+		{"single subaddress", recipient, sub, amount, outputsTx(t,
+			Destination{Address: sub, Amount: amount})},
+	}
+}
+
+func txKeys(tx *Transaction) (Key, []Key) {
+	var additional []Key
+	for _, k := range tx.AdditionalSecretKeys {
+		additional = append(additional, Key(k))
+	}
+	return Key(tx.SecretKey), additional
+}
+
+func TestTxProofRoundTrip(t *testing.T) {
+	const message = "invoice 42"
+	for _, tc := range proofCases(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			r, additional := txKeys(tc.tx)
+			outProof, err := GetOutProof(tc.tx.Hash, tc.address, message, r, additional)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inProof, err := GetInProof(tc.tx, tc.address, message, tc.recipient.ViewKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, p := range []struct {
+				proof    string
+				outbound bool
+			}{{outProof, true}, {inProof, false}} {
+				res, err := CheckTxProof(tc.tx, tc.address, message, p.proof)
+				if err != nil {
+					t.Fatalf("%.10s: %v", p.proof, err)
+				}
+				if res.Outbound != p.outbound || res.Received != tc.amount || len(res.Outputs) != 1 {
+					t.Fatalf("%.10s: %+v", p.proof, res)
+				}
+			}
+
+			res, err := CheckTxKey(tc.tx, tc.address, r, additional)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Received != tc.amount {
+				t.Fatalf("CheckTxKey received %s, expected %s", res.Received, tc.amount)
+			}
+		})
+	}
+}
+
+func TestTxProofRejects(t *testing.T) {
+	const message = "invoice 42"
+	tc := proofCases(t)[0]
+	r, additional := txKeys(tc.tx)
+	outProof, err := GetOutProof(tc.tx.Hash, tc.address, message, r, additional)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inProof, err := GetInProof(tc.tx, tc.address, message, tc.recipient.ViewKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := NewAccountFromSpendKey(*RandomScalar(), Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name, address, message, proof string
+	}{
+		{"wrong message", tc.address, "invoice 43", outProof},
+		{"wrong address", stranger.Address, message, outProof},
+		{"wrong address in", stranger.Address, message, inProof},
+		{"flipped signature", tc.address, message, flipTxProofSig(t, outProof, OutProofHeader)},
+		{"flipped shared secret", tc.address, message, flipTxProofShared(t, inProof, InProofHeader)},
+		{"V1 header", tc.address, message, outProofHeaderV1 + strings.TrimPrefix(outProof, OutProofHeader)},
+		{"out as in", tc.address, message, InProofHeader + strings.TrimPrefix(outProof, OutProofHeader)},
+		{"truncated", tc.address, message, outProof[:len(outProof)-1]},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if res, err := CheckTxProof(tc.tx, c.address, c.message, c.proof); err == nil {
+				t.Fatalf("accepted: %+v", res)
+			}
+		})
+	}
+
+	// ключ другой транзакции ничего не находит
+	if res, err := CheckTxKey(tc.tx, tc.address, *RandomScalar(), nil); err != nil || res.Received != 0 {
+		t.Fatalf("random tx key: %+v, %v", res, err)
+	}
+}
+
+// flipTxProofSig / flipTxProofShared: один бит первой подписи или общего секрета,
+// с перекодированием, чтобы base58 оставался корректным
+func flipTxProofSig(t *testing.T, proof, header string) string {
+	return flipTxProofChunk(t, proof, len(header)+txProofSecretLen, txProofSigLen)
+}
+
+func flipTxProofShared(t *testing.T, proof, header string) string {
+	return flipTxProofChunk(t, proof, len(header), txProofSecretLen)
+}
+
+func flipTxProofChunk(t *testing.T, proof string, offset, length int) string {
+	t.Helper()
+	data, err := decodeMoneroBase58(proof[offset : offset+length])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	encoded := encodeMoneroBase58(data)
+	if len(encoded) != length {
+		t.Fatalf("re-encoded length %d", len(encoded))
+	}
+	return proof[:offset] + encoded + proof[offset+length:]
+}
@@ -0,0 +1,186 @@
+package levin
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Правила разблокировки из cryptonote_config.h / wallet2
+const (
+	CryptonoteDefaultTxSpendableAge  = 10        // обычный выход тратится через 10 блоков
+	CryptonoteMinedMoneyUnlockWindow = 60        // coinbase — через 60
+	CryptonoteMaxBlockNumber         = 500000000 // unlock_time меньше — высота, иначе unix time
+	LockedTxAllowedDeltaBlocks       = 1
+	LockedTxAllowedDeltaSeconds      = 120 * LockedTxAllowedDeltaBlocks // DIFFICULTY_TARGET_V2 * delta
+)
+
+type OutputState int
+
+const (
+	OutputSeen      OutputState = iota // в блоке, подтверждений меньше требуемых
+	OutputConfirmed                    // подтверждений достаточно, но выход ещё заблокирован
+	OutputUnlocked                     // можно тратить
+)
+
+func (s OutputState) String() string {
+	switch s {
+	case OutputSeen:
+		return "seen"
+	case OutputConfirmed:
+		return "confirmed"
+	case OutputUnlocked:
+		return "unlocked"
+	}
+	return fmt.Sprintf("OutputState(%d)", int(s))
+}
+
+func (s OutputState) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// OutputTransition — смена состояния или числа подтверждений выхода
+type OutputTransition struct {
+	Output        OwnedOutput `json:"output"`
+	From          OutputState `json:"from"`
+	To            OutputState `json:"to"`
+	Confirmations uint64      `json:"confirmations"`
+	Height        uint64      `json:"height"` // высота tip, на которой произошёл переход
+}
+
+// Confirmations: блок с выходом считается первым подтверждением
+func Confirmations(blockHeight, tipHeight uint64) uint64 {
+	if tipHeight < blockHeight {
+		return 0
+	}
+	return tipHeight - blockHeight + 1
+}
+
+// IsUnlocked повторяет wallet2::is_transfer_unlocked. tipTime — timestamp
+// последнего блока, чтобы результат не зависел от локальных часов.
+func IsUnlocked(o *OwnedOutput, tipHeight, tipTime uint64) bool {
+	if tipHeight < o.BlockHeight {
+		return false
+	}
+	chainHeight := tipHeight + 1 // get_blockchain_current_height
+
+	if o.BlockHeight+CryptonoteDefaultTxSpendableAge > chainHeight {
+		return false
+	}
+	if o.Coinbase && o.BlockHeight+CryptonoteMinedMoneyUnlockWindow > chainHeight {
+		return false
+	}
+
+	if o.UnlockTime < CryptonoteMaxBlockNumber {
+		return chainHeight-1+LockedTxAllowedDeltaBlocks >= o.UnlockTime
+	}
+	return tipTime+LockedTxAllowedDeltaSeconds >= o.UnlockTime
+}
+
+func (w *Watcher) outputState(o *OwnedOutput, tipHeight, tipTime uint64) (OutputState, uint64) {
+	confirmations := Confirmations(o.BlockHeight, tipHeight)
+	switch {
+	case IsUnlocked(o, tipHeight, tipTime):
+		return OutputUnlocked, confirmations
+	case confirmations >= w.requiredConfirmations:
+		return OutputConfirmed, confirmations
+	}
+	return OutputSeen, confirmations
+}
+
+// SetRequiredConfirmations: сколько подтверждений нужно для OutputConfirmed
+func (w *Watcher) SetRequiredConfirmations(n uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.requiredConfirmations = max(n, 1)
+}
+
+// Advance сдвигает tip и возвращает переходы непотраченных выходов. Пока выход
+// не набрал требуемые подтверждения, переход отдаётся на каждый новый блок;
+// дальше — только смена состояния.
+func (w *Watcher) Advance(tipHeight, tipTime uint64) []OutputTransition {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.tipHeight, w.tipTime = tipHeight, tipTime
+
+	var transitions []OutputTransition
+	for _, t := range w.outputs {
+		if t.spent != nil || t.BlockHeight == 0 {
+			continue
+		}
+		state, confirmations := w.outputState(&t.OwnedOutput, tipHeight, tipTime)
+		if state == t.state && (confirmations == t.confirmations || confirmations > w.requiredConfirmations) {
+			continue
+		}
+		transitions = append(transitions, OutputTransition{
+			Output:        t.OwnedOutput,
+			From:          t.state,
+			To:            state,
+			Confirmations: confirmations,
+			Height:        tipHeight,
+		})
+		t.state, t.confirmations = state, confirmations
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		a, b := &transitions[i].Output, &transitions[j].Output
+		if a.BlockHeight != b.BlockHeight {
+			return a.BlockHeight < b.BlockHeight
+		}
+		if c := bytes.Compare(a.TxHash[:], b.TxHash[:]); c != 0 {
+			return c < 0
+		}
+		return a.OutputIndex < b.OutputIndex
+	})
+	return transitions
+}
+
+// OutputState: текущее состояние выхода относительно последнего Advance
+func (w *Watcher) OutputState(txHash Hash, outputIndex uint64) (OutputState, uint64, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	t, ok := w.outputs[outpoint{txHash, outputIndex}]
+	if !ok {
+		return 0, 0, false
+	}
+	return t.state, t.confirmations, true
+}
+
+// UnlockedBalance: непотраченные и уже разблокированные выходы
+func (w *Watcher) UnlockedBalance(address string) Amount {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var balance Amount
+	for _, t := range w.outputs {
+		if t.Address == address && t.spent == nil && IsUnlocked(&t.OwnedOutput, w.tipHeight, w.tipTime) {
+			balance += t.Amount
+		}
+	}
+	return balance
+}
+
+// SpendableOutputs: непотраченные разблокированные выходы — то, что можно положить
+// во вход транзакции. Глобальный индекс может быть неизвестен, его найдёт
+// RingMemberSource. Key image обязателен, только если известен spend key: у
+// view-only кошелька без импортированных key images траты не видны, и такой
+// выход может оказаться уже потраченным — это проверит демон при отправке.
+func (w *Watcher) SpendableOutputs(address string) []OwnedOutput {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	a := w.accounts[address]
+	needKeyImage := a == nil || a.spendKey != nil
+
+	var spendable []OwnedOutput
+	for _, t := range w.outputs {
+		if t.Address != address || t.spent != nil || (needKeyImage && t.KeyImage == nil) {
+			continue
+		}
+		if IsUnlocked(&t.OwnedOutput, w.tipHeight, w.tipTime) {
+			spendable = append(spendable, t.OwnedOutput)
+		}
+	}
+	return spendable
+}
@@ -0,0 +1,606 @@
+package levin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"sort"
+	"sync"
+
+	"filippo.io/edwards25519"
+)
+
+// SubaddressIndex: (0, 0) — основной адрес
+type SubaddressIndex struct {
+	Major uint32 `json:"major"`
+	Minor uint32 `json:"minor"`
+}
+
+// OwnedOutput — найденный выход одного из наблюдаемых кошельков
+type OwnedOutput struct {
+	Address     string          `json:"address"`
+	TxHash      Hash            `json:"tx_hash"`
+	OutputIndex uint64          `json:"output_index"`
+	GlobalIndex *uint64         `json:"global_index,omitempty"` // nil, пока индекс неизвестен; 0 — настоящий индекс
+	Amount      Amount          `json:"amount"`
+	BlockHeight uint64          `json:"block_height"`
+	PaymentID   ByteArray       `json:"payment_id,omitempty"` // 8 байт (расшифрованный) или 32 байта
+	Subaddress  SubaddressIndex `json:"subaddress"`
+	OutputKey   Hash            `json:"output_key"`
+	TxPubKey    Hash            `json:"tx_pub_key"`
+	KeyImage    *Hash           `json:"key_image,omitempty"` // есть spend key или импортирован
+	UnlockTime  uint64          `json:"unlock_time"`
+	Coinbase    bool            `json:"coinbase"`
+}
+
+// SpentOutput — наш выход, потраченный транзакцией TxHash
+type SpentOutput struct {
+	Output      OwnedOutput `json:"output"`
+	TxHash      Hash        `json:"tx_hash"`
+	BlockHeight uint64      `json:"block_height"`
+}
+
+type outpoint struct {
+	tx    Hash
+	index uint64
+}
+
+type trackedOutput struct {
+	OwnedOutput
+	spent *SpentOutput
+
+	state         OutputState
+	confirmations uint64
+}
+
+// Watcher сканирует блоки на выходы зарегистрированных кошельков и по key images
+// следит за их тратой
+type Watcher struct {
+	mu       sync.RWMutex
+	accounts map[string]*Account
+
+	outputs          map[outpoint]*trackedOutput
+	keyImages        map[Hash]*trackedOutput
+	pendingKeyImages map[outpoint]Hash // импортированы раньше, чем выход найден
+
+	tipHeight             uint64
+	tipTime               uint64
+	requiredConfirmations uint64
+	workers               int
+}
+
+func NewWatcher() *Watcher {
+	return &Watcher{
+		accounts:         make(map[string]*Account),
+		outputs:          make(map[outpoint]*trackedOutput),
+		keyImages:        make(map[Hash]*trackedOutput),
+		pendingKeyImages: make(map[outpoint]Hash),
+
+		requiredConfirmations: CryptonoteDefaultTxSpendableAge,
+		workers:               runtime.NumCPU(),
+	}
+}
+
+func (w *Watcher) AddAccount(a *Account) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.accounts[a.Address] = a
+}
+
+func (w *Watcher) RemoveAccount(address string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.accounts, address)
+}
+
+func (w *Watcher) Accounts() []*Account {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	accounts := make([]*Account, 0, len(w.accounts))
+	for _, a := range w.accounts {
+		accounts = append(accounts, a)
+	}
+	return accounts
+}
+
+// ScanBlock: miner tx и все транзакции блока. Блок должен быть разобран
+// (FullfillBlockHeader), транзакции без префикса разбираются здесь.
+func (w *Watcher) ScanBlock(b *Block) ([]OwnedOutput, []SpentOutput) {
+	return w.ScanBlocks([]*Block{b})
+}
+
+// ScanBlocks сканирует пачку блоков. Кошельки делятся между воркерами,
+// траты и учёт выходов идут последовательно в порядке цепочки.
+func (w *Watcher) ScanBlocks(blocks []*Block) ([]OwnedOutput, []SpentOutput) {
+	return w.ScanIndexedBlocks(blocks, nil)
+}
+
+// ScanIndexedBlocks: то же, найденным выходам проставляется GlobalIndex из indices
+// (см. OutputIndexer.IndexBlocks)
+func (w *Watcher) ScanIndexedBlocks(blocks []*Block, indices GlobalIndices) ([]OwnedOutput, []SpentOutput) {
+	accounts := w.Accounts()
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+
+	var (
+		targets []scanTarget
+		txs     []*Transaction // nil для coinbase
+	)
+	for _, b := range blocks {
+		minerHash := Hash(b.CalculateMinerTxHash())
+		targets = append(targets, scanTarget{
+			hash:        minerHash,
+			height:      b.BlockHeight,
+			unlockTime:  b.MinerTx.UnlockTime,
+			extra:       b.MinerTx.Extra,
+			outputs:     b.MinerTx.Outs,
+			coinbase:    true,
+			globalIndex: indices[minerHash],
+		})
+		txs = append(txs, nil)
+
+		for _, tx := range b.TXs {
+			if tx.Raw == nil {
+				continue
+			}
+			if tx.Outputs == nil {
+				tx.ParseTx()
+				tx.ParseRctSig()
+			}
+			t := txScanTarget(tx, b.BlockHeight)
+			t.globalIndex = indices[tx.Hash]
+			targets = append(targets, t)
+			txs = append(txs, tx)
+		}
+	}
+
+	return w.apply(targets, txs, w.scanTargets(accounts, targets))
+}
+
+// ScanTx: одна транзакция (например, из мемпула, height = 0)
+func (w *Watcher) ScanTx(tx *Transaction, height uint64) ([]OwnedOutput, []SpentOutput) {
+	targets := []scanTarget{txScanTarget(tx, height)}
+	return w.apply(targets, []*Transaction{tx}, w.scanTargets(w.Accounts(), targets))
+}
+
+func txScanTarget(tx *Transaction, height uint64) scanTarget {
+	return scanTarget{
+		hash:       tx.Hash,
+		height:     height,
+		unlockTime: tx.UnlockTime,
+		extra:      tx.Extra,
+		outputs:    tx.Outputs,
+		rct:        tx.RctSignature,
+	}
+}
+
+// apply: траты проверяются до учёта выходов той же транзакции, как при последовательном скане
+func (w *Watcher) apply(targets []scanTarget, txs []*Transaction, found [][]OwnedOutput) ([]OwnedOutput, []SpentOutput) {
+	var (
+		received []OwnedOutput
+		spent    []SpentOutput
+	)
+	for i := range targets {
+		if txs[i] != nil {
+			spent = append(spent, w.matchKeyImages(txs[i], targets[i].height)...)
+		}
+		w.track(found[i])
+		received = append(received, found[i]...)
+	}
+	return received, spent
+}
+
+// SetWorkers: число горутин для скана, по умолчанию runtime.NumCPU()
+func (w *Watcher) SetWorkers(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.workers = max(n, 1)
+}
+
+type scanHit struct {
+	target  int
+	account int
+	outputs []OwnedOutput
+}
+
+// scanTargets возвращает найденные выходы по каждой цели. Один кошелёк
+// целиком обрабатывается одним воркером, поэтому окно субадресов
+// сдвигается в том же порядке, что и без пула.
+func (w *Watcher) scanTargets(accounts []*Account, targets []scanTarget) [][]OwnedOutput {
+	found := make([][]OwnedOutput, len(targets))
+	if len(accounts) == 0 || len(targets) == 0 {
+		return found
+	}
+
+	for i := range targets {
+		targets[i].prepare() // до воркеров: дальше targets только читаются
+	}
+
+	w.mu.RLock()
+	workers := min(w.workers, len(accounts))
+	w.mu.RUnlock()
+
+	scanAccount := func(ai int, hits []scanHit) []scanHit {
+		for ti := range targets {
+			if outs := targets[ti].scan(accounts[ai]); outs != nil {
+				hits = append(hits, scanHit{ti, ai, outs})
+			}
+		}
+		return hits
+	}
+
+	var hits []scanHit
+	if workers <= 1 {
+		for ai := range accounts {
+			hits = scanAccount(ai, hits)
+		}
+	} else {
+		jobs := make(chan int)
+		results := make([][]scanHit, workers)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for ai := range jobs {
+					results[i] = scanAccount(ai, results[i])
+				}
+			}(i)
+		}
+		for ai := range accounts {
+			jobs <- ai
+		}
+		close(jobs)
+		wg.Wait()
+
+		for _, r := range results {
+			hits = append(hits, r...)
+		}
+		sort.Slice(hits, func(i, j int) bool {
+			if hits[i].target != hits[j].target {
+				return hits[i].target < hits[j].target
+			}
+			return hits[i].account < hits[j].account
+		})
+	}
+
+	for _, h := range hits {
+		found[h.target] = append(found[h.target], h.outputs...)
+	}
+	return found
+}
+
+// matchKeyImages ищет среди входов key images наших выходов
+func (w *Watcher) matchKeyImages(tx *Transaction, height uint64) []SpentOutput {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var spent []SpentOutput
+	for _, in := range tx.Inputs {
+		out, ok := w.keyImages[in.KeyImage]
+		if !ok {
+			continue
+		}
+		s := &SpentOutput{
+			Output:      out.OwnedOutput,
+			TxHash:      tx.Hash,
+			BlockHeight: height,
+		}
+		out.spent = s
+		spent = append(spent, *s)
+	}
+	return spent
+}
+
+func (w *Watcher) track(outputs []OwnedOutput) {
+	if len(outputs) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range outputs {
+		w.trackLocked(&outputs[i])
+	}
+}
+
+// trackLocked: импортированный заранее key image проставляется и в o
+func (w *Watcher) trackLocked(o *OwnedOutput) {
+	op := outpoint{o.TxHash, o.OutputIndex}
+	if ki, ok := w.pendingKeyImages[op]; ok && o.KeyImage == nil {
+		o.KeyImage = &ki
+		delete(w.pendingKeyImages, op)
+	}
+
+	t, ok := w.outputs[op]
+	if !ok {
+		t = &trackedOutput{}
+		w.outputs[op] = t
+	}
+	if o.KeyImage == nil {
+		o.KeyImage = t.KeyImage // повторный скан того же выхода
+	}
+	if o.GlobalIndex == nil {
+		o.GlobalIndex = t.GlobalIndex
+	}
+	t.OwnedOutput = *o
+	if o.KeyImage != nil {
+		w.keyImages[*o.KeyImage] = t
+	}
+}
+
+// TrackOutput добавляет ранее найденный выход (например, из базы после рестарта)
+func (w *Watcher) TrackOutput(o OwnedOutput) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.trackLocked(&o)
+}
+
+// ImportKeyImage для view-only кошельков: key image выхода, посчитанный там, где лежит spend key.
+// Траты, отсканированные до импорта, не находятся — нужен rescan.
+func (w *Watcher) ImportKeyImage(txHash Hash, outputIndex uint64, keyImage Hash) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	op := outpoint{txHash, outputIndex}
+	t, ok := w.outputs[op]
+	if !ok {
+		w.pendingKeyImages[op] = keyImage
+		return
+	}
+	t.KeyImage = &keyImage
+	w.keyImages[keyImage] = t
+}
+
+// UnspentOutputs: непотраченные выходы кошелька. Для view-only без
+// импортированных key images сюда попадают и потраченные.
+func (w *Watcher) UnspentOutputs(address string) []OwnedOutput {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var unspent []OwnedOutput
+	for _, t := range w.outputs {
+		if t.Address == address && t.spent == nil {
+			unspent = append(unspent, t.OwnedOutput)
+		}
+	}
+	return unspent
+}
+
+// Balance: сумма непотраченных выходов в атомарных единицах
+func (w *Watcher) Balance(address string) Amount {
+	var balance Amount
+	for _, o := range w.UnspentOutputs(address) {
+		balance += o.Amount
+	}
+	return balance
+}
+
+// scanTarget — общее у обычной и miner транзакции
+type scanTarget struct {
+	hash       Hash
+	height     uint64
+	unlockTime uint64
+	extra      []byte
+	outputs    []TxOutput
+	rct        *RctSignature // nil или RCTTypeNull — суммы открыты
+	coinbase   bool
+
+	globalIndex []uint64 // nil — индексы неизвестны
+
+	// общее для всех кошельков, заполняется в prepare
+	prepared   bool
+	fields     *TxExtra
+	R          Key
+	rPoint     *edwards25519.Point
+	additional []*edwards25519.Point // по одному на выход или nil
+}
+
+// prepare разбирает extra и распаковывает pubkeys один раз на транзакцию
+func (t *scanTarget) prepare() {
+	if t.prepared {
+		return
+	}
+	t.prepared = true
+
+	t.fields, _ = ParseTxExtra(t.extra)
+	txPubKey := t.fields.PubKey()
+	if txPubKey == nil {
+		return
+	}
+	copy(t.R[:], txPubKey)
+	p, err := new(edwards25519.Point).SetBytes(t.R[:])
+	if err != nil {
+		return
+	}
+	t.rPoint = p
+
+	// additional pubkeys: по одному на выход, когда среди получателей есть субадреса
+	if keys := t.fields.AdditionalPubKeys(); len(keys) == len(t.outputs) {
+		additional := make([]*edwards25519.Point, len(keys))
+		for i, k := range keys {
+			if additional[i], err = new(edwards25519.Point).SetBytes(k); err != nil {
+				return
+			}
+		}
+		t.additional = additional
+	}
+}
+
+// keyDerivation: 8*a*R, то же, что GenerateKeyDerivation, но без распаковки R
+func keyDerivation(R *edwards25519.Point, a *edwards25519.Scalar) Key {
+	p := new(edwards25519.Point).ScalarMult(a, R)
+	p.MultByCofactor(p)
+	return Key(p.Bytes())
+}
+
+func (t *scanTarget) scan(a *Account) []OwnedOutput {
+	t.prepare()
+	if t.rPoint == nil {
+		return nil
+	}
+	extra := t.fields
+	R := t.R
+	derivation := keyDerivation(t.rPoint, a.viewScalar)
+
+	var additional []Key
+	if t.additional != nil {
+		additional = make([]Key, len(t.additional))
+		for i, Ri := range t.additional {
+			additional[i] = keyDerivation(Ri, a.viewScalar)
+		}
+	}
+
+	var owned []OwnedOutput
+	for i, out := range t.outputs {
+		index := uint64(i)
+
+		candidates := []*Key{&derivation}
+		if additional != nil {
+			candidates = append(candidates, &additional[i])
+		}
+
+		var (
+			found  bool
+			subIdx SubaddressIndex
+			used   *Key // derivation, которой адресован выход
+			outR   = R
+		)
+		for c, d := range candidates {
+			if out.Type == TxOutToTaggedKey && derivationViewTag(d, index) != byte(out.ViewTag) {
+				continue
+			}
+			D, ok := outputSpendKey(d, index, Key(out.Target))
+			if !ok {
+				continue
+			}
+			if subIdx, found = a.lookupSubaddress(D); found {
+				used = d
+				if c == 1 {
+					copy(outR[:], extra.AdditionalPubKeys()[i])
+				}
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		a.markSubaddressUsed(subIdx)
+
+		var keyImage *Hash
+		if a.spendKey != nil {
+			if ki, err := a.outputKeyImage(used, index, subIdx, out.Target); err == nil {
+				keyImage = &ki
+			}
+		}
+
+		// сумма из ecdhInfo ничем не подтверждена: отправитель может зашифровать
+		// любую. Выход, чей коммитмент с ней не сходится, не наш платёж.
+		amount := Amount(out.Amount)
+		if t.rct != nil && t.rct.Type != uint64(RCTTypeNull) {
+			if i >= len(t.rct.EcdhInfo) || i >= len(t.rct.OutPk) {
+				continue
+			}
+			amount = Amount(decodeCompactAmount(used, index, t.rct.EcdhInfo[i].Amount))
+			if !checkCommitment(used, index, amount, t.rct.OutPk[i]) {
+				continue
+			}
+		}
+
+		var globalIndex *uint64
+		if i < len(t.globalIndex) {
+			gi := t.globalIndex[i]
+			globalIndex = &gi
+		}
+
+		owned = append(owned, OwnedOutput{
+			Address:     a.Address,
+			TxHash:      t.hash,
+			OutputIndex: index,
+			GlobalIndex: globalIndex,
+			Amount:      amount,
+			BlockHeight: t.height,
+			Subaddress:  subIdx,
+			OutputKey:   out.Target,
+			TxPubKey:    Hash(outR),
+			KeyImage:    keyImage,
+			UnlockTime:  t.unlockTime,
+			Coinbase:    t.coinbase,
+		})
+	}
+
+	if len(owned) == 0 {
+		return nil
+	}
+
+	var paymentID []byte
+	if pid := extra.PaymentID(); pid != nil {
+		paymentID = pid
+	} else if encPID := extra.EncryptedPaymentID(); encPID != nil {
+		paymentID = decryptPaymentID(&derivation, encPID)
+	}
+	for i := range owned {
+		owned[i].PaymentID = paymentID
+	}
+	return owned
+}
+
+// outputSpendKey: D = P - Hs(derivation || index)*G
+func outputSpendKey(derivation *Key, index uint64, P Key) (Key, bool) {
+	point, err := new(edwards25519.Point).SetBytes(P[:])
+	if err != nil {
+		return Key{}, false
+	}
+	scalar := derivationToScalar(derivation, index)
+	hs, err := new(edwards25519.Scalar).SetCanonicalBytes(scalar[:])
+	if err != nil {
+		return Key{}, false
+	}
+
+	point.Subtract(point, new(edwards25519.Point).ScalarBaseMult(hs))
+	return Key(point.Bytes()), true
+}
+
+// derivationViewTag: H["view_tag" || derivation || varint(index)][0]
+func derivationViewTag(derivation *Key, index uint64) byte {
+	data := make([]byte, 0, 8+KeyLength+10)
+	data = append(data, "view_tag"...)
+	data = append(data, derivation[:]...)
+	data = append(data, encodeVarint(index)...)
+	return keccak256(data)[0]
+}
+
+// decodeCompactAmount: amount XOR H["amount" || Hs(derivation || varint(index))][:8]
+func decodeCompactAmount(derivation *Key, index uint64, encrypted HAmount) uint64 {
+	scalar := derivationToScalar(derivation, index)
+	mask := keccak256(append([]byte("amount"), scalar[:]...))
+
+	var amount [8]byte
+	for i := range amount {
+		amount[i] = encrypted[i] ^ mask[i]
+	}
+	return binary.LittleEndian.Uint64(amount[:])
+}
+
+// checkCommitment: outPk == amount*H + mask*G, mask = Hs("commitment_mask" || Hs(derivation || varint(index)))
+func checkCommitment(derivation *Key, index uint64, amount Amount, outPk Hash) bool {
+	scalar := derivationToScalar(derivation, index)
+	mask := Key(keccak256(append([]byte("commitment_mask"), scalar[:]...)))
+	ScReduce32(&mask)
+
+	C, err := CalcCommitment(uint64(amount), mask)
+	return err == nil && C == outPk
+}
+
+// decryptPaymentID: pid XOR H[derivation || 0x8d][:8]
+func decryptPaymentID(derivation *Key, encPID []byte) []byte {
+	mask := keccak256(append(bytes.Clone(derivation[:]), 0x8d))
+
+	pid := make([]byte, len(encPID))
+	for i := range pid {
+		pid[i] = encPID[i] ^ mask[i]
+	}
+	return pid
+}
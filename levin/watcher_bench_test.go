@@ -0,0 +1,105 @@
+package levin
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// Скан дампов из ../blocks на многих кошельках с лукахедом субадресов по
+// умолчанию, как в продакшене. Таблицы субадресов строятся до таймера:
+// это разовая цена добавления кошелька, а не скана.
+//
+//	go test ./levin -run '^$' -bench . -benchtime 3x
+
+var benchAccounts = map[int][]*Account{}
+
+func benchmarkAccounts(b *testing.B, n int) []*Account {
+	b.Helper()
+	if accounts, ok := benchAccounts[n]; ok {
+		return accounts
+	}
+
+	a, err := NewAccount(testAddress, testViewKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	accounts := []*Account{a}
+	for len(accounts) < n {
+		spend, view := RandomScalar(), RandomScalar()
+		address, err := EncodeAddress(Mainnet, AddressStandard, *spend.PubKey(), *view.PubKey(), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		a, err := NewAccount(address, view.String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		accounts = append(accounts, a)
+	}
+	for _, a := range accounts {
+		a.lookupSubaddress(Key{})
+	}
+	benchAccounts[n] = accounts
+	return accounts
+}
+
+func BenchmarkScanBlocks(b *testing.B) {
+	blocks := loadTestBlocks(b)
+	var txs, outputs int
+	for _, blk := range blocks {
+		txs += 1 + len(blk.TXs)
+		outputs += len(blk.MinerTx.Outs)
+		for _, tx := range blk.TXs {
+			outputs += len(tx.Outputs)
+		}
+	}
+
+	workerCounts := slices.Compact([]int{1, runtime.NumCPU()})
+	for _, n := range []int{1, 10, 100} {
+		for _, workers := range workerCounts {
+			b.Run(fmt.Sprintf("accounts=%d/workers=%d", n, workers), func(b *testing.B) {
+				accounts := benchmarkAccounts(b, n)
+				w := NewWatcher()
+				w.SetWorkers(workers)
+				for _, a := range accounts {
+					w.AddAccount(a)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if owned, _ := w.ScanBlocks(blocks); len(owned) == 0 {
+						b.Fatal("test wallet outputs not found")
+					}
+				}
+				perScan := b.Elapsed().Seconds() / float64(b.N)
+				b.ReportMetric(float64(outputs*n)/perScan, "checks/s")
+				b.ReportMetric(perScan*1e6/float64(txs*n), "µs/tx/account")
+			})
+		}
+	}
+}
+
+func BenchmarkCheckOutputs(b *testing.B) {
+	var owned []*Transaction
+	w := NewWatcher()
+	w.AddAccount(benchmarkAccounts(b, 1)[0])
+	for _, blk := range loadTestBlocks(b) {
+		for _, tx := range blk.TXs {
+			if found, _ := w.ScanTx(tx, blk.BlockHeight); len(found) > 0 {
+				owned = append(owned, tx)
+			}
+		}
+	}
+	if len(owned) == 0 {
+		b.Skip("no outputs of the test wallet in the dumps")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := owned[i%len(owned)].CheckOutputs(testAddress, testViewKey); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
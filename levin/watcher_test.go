@@ -0,0 +1,68 @@
+package levin
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// тестовый кошелёк с выходами в дампах из ../blocks
+const (
+	testAddress = "42LPBD4x3hv2fy2CeYPhyjUjTYSNnkvg1a2zj2F6YuSsSQCWac6Pp22RAfCG7djHbM3imHtizTwwoZW4TwFEdY97BRAyDxq"
+	testViewKey = "98540b36f09f5e5439f98f048e81e32fbbf19f836c962fef1510d3af605f0102"
+)
+
+func testWatcher(t *testing.T) *Watcher {
+	t.Helper()
+	a, err := NewAccount(testAddress, testViewKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetSubaddressLookahead(0, 0)
+	w := NewWatcher()
+	w.AddAccount(a)
+	return w
+}
+
+// ownedTx: первая транзакция дампов с выходом тестового кошелька
+func ownedTx(t *testing.T) (*Transaction, OwnedOutput) {
+	t.Helper()
+	w := testWatcher(t)
+	for _, b := range loadTestBlocks(t) {
+		for _, tx := range b.TXs {
+			if owned, _ := w.ScanTx(tx, b.BlockHeight); len(owned) > 0 {
+				return tx, owned[0]
+			}
+		}
+	}
+	t.Skip("no outputs of the test wallet in the dumps")
+	return nil, OwnedOutput{}
+}
+
+func TestWatcherScanDumps(t *testing.T) {
+	_, out := ownedTx(t)
+	if out.Address != testAddress || out.Amount == 0 {
+		t.Fatalf("owned %+v", out)
+	}
+}
+
+// отправитель шифрует в ecdhInfo 1000 XMR поверх коммитмента к другой сумме
+func TestWatcherRejectsForgedAmount(t *testing.T) {
+	tx, out := ownedTx(t)
+
+	forged := *tx
+	rct := *tx.RctSignature
+	rct.EcdhInfo = append(rct.EcdhInfo[:0:0], rct.EcdhInfo...)
+	var delta [8]byte
+	binary.LittleEndian.PutUint64(delta[:], uint64(out.Amount)^uint64(1000*XMR))
+	for i := range delta {
+		rct.EcdhInfo[out.OutputIndex].Amount[i] ^= delta[i]
+	}
+	forged.RctSignature = &rct
+
+	owned, _ := testWatcher(t).ScanTx(&forged, out.BlockHeight)
+	for _, o := range owned {
+		if o.OutputIndex == out.OutputIndex {
+			t.Fatalf("forged output credited with %s XMR", o.Amount)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"xmr_scanner/levin"
+)
+
+const (
+	rescanBatch           = 100  // блоков за запрос
+	rescanCheckpointEvery = 1000 // как часто сохранять прогресс
+	rescanRetryDelay      = 10 * time.Second
+)
+
+// RescanCheckpoint — прогресс восстановления кошелька: блоки до Height (включительно) отсканированы
+type RescanCheckpoint struct {
+	Address       string `json:"address"`
+	RestoreHeight uint64 `json:"restore_height"`
+	Height        uint64 `json:"height"`
+	Hash          string `json:"hash"` // хэш блока Height, пусто — ещё не известен
+	Done          bool   `json:"done"`
+}
+
+// SetBlockStore: каталог с levin дампами, откуда rescan берёт блоки раньше сети
+func (p *ScannerXMR) SetBlockStore(dir string) {
+	p.blockStore = dir
+}
+
+// Rescan восстанавливает кошелёк с высоты restoreHeight. Кошелёк сканируется
+// отдельным Watcher'ом и отдельным соединением; когда rescan догоняет основной
+// скан, выходы и сам кошелёк передаются в p.watcher.
+func (p *ScannerXMR) Rescan(account *levin.Account, restoreHeight uint64) {
+	cp := RescanCheckpoint{
+		Address:       account.Address,
+		RestoreHeight: restoreHeight,
+	}
+	if restoreHeight > 0 {
+		cp.Height = restoreHeight - 1
+	} else {
+		cp.Hash = levin.MainnetGenesisTx
+	}
+	p.ResumeRescan(account, cp)
+}
+
+// ResumeRescan продолжает rescan с сохранённого checkpoint
+func (p *ScannerXMR) ResumeRescan(account *levin.Account, cp RescanCheckpoint) {
+	p.watcher.RemoveAccount(account.Address)
+	go p.rescan(account, cp)
+}
+
+func (p *ScannerXMR) rescan(account *levin.Account, cp RescanCheckpoint) {
+	w := levin.NewWatcher()
+	w.AddAccount(account)
+
+	// уже найденные выходы — чтобы увидеть их траты
+	if unspent, err := p.db.GetUnspentOutputs(p.chainName); err == nil {
+		for _, o := range unspent {
+			if o.Address == account.Address {
+				w.TrackOutput(o)
+			}
+		}
+	}
+
+	for cp.Hash == "" && !p.destroy {
+		hash, err := p.blockHashAt(cp.Height)
+		if err != nil {
+			p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s: block hash at %d: %s", account.Address, cp.Height, err), LevelError)
+			time.Sleep(rescanRetryDelay)
+			continue
+		}
+		cp.Hash = hash
+	}
+
+	p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s from height %d", account.Address, cp.Height+1), LevelInfo)
+
+	// счётчики выходов перед пачкой: из базы, если основной скан проходил эту
+	// высоту, иначе у демона. Не вышло — пачка без индексов.
+	var indexer *levin.OutputIndexer
+	index := func(blocks []*levin.Block) levin.GlobalIndices {
+		if indexer == nil {
+			c, err := p.outputCounters(cp.Height, cp.Hash)
+			if err != nil {
+				p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s: output counters at %d: %s", account.Address, cp.Height, err), LevelError)
+				return nil
+			}
+			indexer = levin.NewOutputIndexer(*c)
+		}
+		indices, err := indexer.IndexBlocks(blocks)
+		if err != nil {
+			p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s: output index: %s", account.Address, err), LevelError)
+			indexer = nil
+		}
+		return indices
+	}
+
+	var (
+		source    BlockSource
+		fromStore bool
+		saved     = cp.Height
+		started   = time.Now()
+		first     = cp.Height
+		found     int
+	)
+	if p.blockStore != "" {
+		if s, err := newDumpBlockSource(p.blockStore, cp.Height, cp.Hash); err == nil {
+			source, fromStore = s, true
+		}
+	}
+	defer func() {
+		if source != nil {
+			source.Close()
+		}
+	}()
+
+	for !p.destroy {
+		if p.handoff(w, account, cp) {
+			cp.Done = true
+			p.saveCheckpoint(cp)
+			p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s done at height %d: %d outputs, balance %s XMR, %v", account.Address, cp.Height, found, p.watcher.Balance(account.Address), time.Since(started).Round(time.Second)), LevelSuccess)
+			return
+		}
+
+		if source == nil {
+			s, err := newLevinBlockSource(p.nodelist.GetRandomNode(), p.peer_id+1, cp.Height, cp.Hash, p.prune)
+			if err != nil {
+				p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s: connect: %s", account.Address, err), LevelError)
+				time.Sleep(rescanRetryDelay)
+				continue
+			}
+			source = s
+		}
+
+		// дальше основного скана не идём, иначе блоки отсканируются дважды
+		blocks, err := source.Next(int(min(rescanBatch, p.scannedHeight()-cp.Height)))
+		if err != nil {
+			p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s: %s", account.Address, err), LevelError)
+			source.Close()
+			source, fromStore = nil, false
+			time.Sleep(rescanRetryDelay)
+			continue
+		}
+		if len(blocks) == 0 {
+			if fromStore {
+				source.Close()
+				source, fromStore = nil, false // дампы кончились, дальше из сети
+			} else {
+				time.Sleep(rescanRetryDelay) // ждём, пока основной скан уйдёт вперёд
+			}
+			continue
+		}
+
+		owned, spent := w.ScanIndexedBlocks(blocks, index(blocks))
+		if len(owned) > 0 {
+			found += len(owned)
+			for _, out := range owned {
+				p.n.NotifyWithLevel(fmt.Sprintf("Rescan: incoming %s XMR to %s; tx %x:%d; height %d", out.Amount, out.Address, out.TxHash, out.OutputIndex, out.BlockHeight), LevelWarning)
+			}
+			if err := p.db.ProcessOwnedOutputs(p.chainName, owned); err != nil {
+				p.n.NotifyWithLevel(fmt.Sprintf("ProcessOwnedOutputs error: %s", err), LevelError)
+			}
+			p.reconcile(owned)
+		}
+		if len(spent) > 0 {
+			if err := p.db.ProcessSpentOutputs(p.chainName, spent); err != nil {
+				p.n.NotifyWithLevel(fmt.Sprintf("ProcessSpentOutputs error: %s", err), LevelError)
+			}
+		}
+
+		last := blocks[len(blocks)-1]
+		cp.Height, cp.Hash = last.BlockHeight, last.GetBlockId()
+
+		if cp.Height-saved >= rescanCheckpointEvery {
+			saved = cp.Height
+			p.saveCheckpoint(cp)
+
+			target := p.scannedHeight()
+			progress := float64(cp.Height-first) / float64(max(target-first, 1)) * 100
+			p.n.NotifyWithLevel(fmt.Sprintf("Rescan %s: %d/%d (%.1f%%), %d outputs", account.Address, cp.Height, target, progress, found), LevelInfo)
+		}
+	}
+}
+
+// handoff: rescan догнал основной скан — переносим кошелёк в p.watcher.
+// Под scanMu основной скан стоит, так что блоков между ними не пропадёт.
+func (p *ScannerXMR) handoff(w *levin.Watcher, account *levin.Account, cp RescanCheckpoint) bool {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+
+	if cp.Height < p.tip {
+		return false
+	}
+	for _, o := range w.UnspentOutputs(account.Address) {
+		p.watcher.TrackOutput(o)
+	}
+	p.watcher.AddAccount(account)
+	return true
+}
+
+func (p *ScannerXMR) scannedHeight() uint64 {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+	return p.tip
+}
+
+func (p *ScannerXMR) saveCheckpoint(cp RescanCheckpoint) {
+	if err := p.db.SaveRescanCheckpoint(p.chainName, cp); err != nil {
+		p.n.NotifyWithLevel(fmt.Sprintf("SaveRescanCheckpoint error: %s", err), LevelError)
+	}
+}
+
+// blockHashAt: хэш блока по высоте с xmrchain.net, как при Connect
+func (p *ScannerXMR) blockHashAt(height uint64) (string, error) {
+	if height == 0 {
+		return levin.MainnetGenesisTx, nil
+	}
+	html, err := p.GetXMRChain(int32(height))
+	if err != nil {
+		return "", err
+	}
+	pattern := fmt.Sprintf(`Block hash \(height\): ([a-f0-9]{64}) \(%d\)`, height)
+	re := regexp.MustCompile(`(?i)<h4.*?>` + pattern + `</h4>`)
+	matches := re.FindStringSubmatch(*html)
+	if len(matches) < 2 {
+		return "", fmt.Errorf("block hash not found")
+	}
+	return matches[1], nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -25,9 +26,22 @@ type ScannerXMR struct {
 	destroy   bool
 	uptime    time.Time
 	blocks    *Pool
-
-	n  Notifier
-	db DBWrapper
+	prune     bool // запрашивать блоки без подписей
+
+	n          Notifier
+	db         DBWrapper
+	watcher    *levin.Watcher
+	reconciler *levin.Reconciler
+
+	scanMu     sync.Mutex             // основной скан блоков; rescan берёт его при передаче кошелька
+	tip        uint64                 // последний отсканированный блок
+	blockStore string                 // каталог с дампами для rescan
+	indexer    *levin.OutputIndexer   // nil — счётчики выходов неизвестны, берутся перед следующей пачкой
+	daemon     *levin.DaemonRPC       // nil — счётчики выходов только из базы
+	ring       *levin.LocalRingSource // nil — локальный индекс колец не ведётся
+	fees       *levin.BlockFeeTracker
+	coins      *levin.CoinSelector
+	broadcast  *levin.Broadcaster // nil — отправка транзакций не настроена
 
 	peerVersion int32
 	serviceInfo string
@@ -124,13 +138,90 @@ func NewScannerXMR(nl *Nodelist, startHeight int32, hash string, n Notifier, d D
 		db:              d,
 		chainName:       c,
 		peer_id:         uint64(time.Now().Unix()),
+		prune:           true,
+		watcher:         levin.NewWatcher(),
+		reconciler:      levin.NewReconciler(),
+		fees:            levin.NewBlockFeeTracker(),
+		tip:             uint64(startHeight),
 	}
+	scanner.coins = levin.NewCoinSelector(scanner.watcher)
 	scanner.GenerateSequence()
 	scanner.lashBlockHashArr[scanner.lastBlockHeight] = scanner.lastBlockHash
 	scanner.lashBlockHashArr[0] = levin.MainnetGenesisTx
 	return scanner
 }
 
+// SetPrune: для поиска депозитов подписи не нужны, pruned блоки примерно вдвое меньше
+func (p *ScannerXMR) SetPrune(prune bool) {
+	p.prune = prune
+}
+
+// Watcher: через него можно добавлять кошельки на лету
+func (p *ScannerXMR) Watcher() *levin.Watcher {
+	return p.watcher
+}
+
+// SetOutputCounters: счётчики выходов на стартовой высоте, с ними выходам проставляется GlobalIndex
+func (p *ScannerXMR) SetOutputCounters(c levin.OutputCounters) {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+	p.indexer = levin.NewOutputIndexer(c)
+}
+
+// SetDaemon: monerod RPC, у которого берутся счётчики выходов, если в базе их нет
+// на нужной высоте — на старте и после рассинхронизации
+func (p *ScannerXMR) SetDaemon(rpc *levin.DaemonRPC) {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+	p.daemon = rpc
+}
+
+// OutputIndexer: nil, пока счётчики не заданы
+func (p *ScannerXMR) OutputIndexer() *levin.OutputIndexer {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+	return p.indexer
+}
+
+// EnableRingIndex: вести в памяти индекс выходов для колец, начиная со следующего блока.
+// Глобальные индексы нужны из OutputIndexer, так что без счётчиков индекс пуст.
+func (p *ScannerXMR) EnableRingIndex() *levin.LocalRingSource {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+	if p.ring == nil {
+		p.ring = levin.NewLocalRingSource()
+	}
+	return p.ring
+}
+
+// Reconciler: счета, которые сопоставляются с входящими выходами
+func (p *ScannerXMR) Reconciler() *levin.Reconciler {
+	return p.reconciler
+}
+
+// FeeSource: динамическая комиссия по наградам и весам отсканированных блоков
+func (p *ScannerXMR) FeeSource() levin.FeeSource {
+	return p.fees
+}
+
+// CoinSelector: выбор входов из непотраченных выходов watcher с блокировкой до траты
+func (p *ScannerXMR) CoinSelector() *levin.CoinSelector {
+	return p.coins
+}
+
+// SetBroadcaster: отправленные транзакции подтверждаются по пулу пиров и сканируемым блокам
+func (p *ScannerXMR) SetBroadcaster(b *levin.Broadcaster) {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+	p.broadcast = b
+}
+
+func (p *ScannerXMR) Broadcaster() *levin.Broadcaster {
+	p.scanMu.Lock()
+	defer p.scanMu.Unlock()
+	return p.broadcast
+}
+
 func (p *ScannerXMR) Close() {
 	p.destroy = true
 }
@@ -314,27 +405,103 @@ func (p *ScannerXMR) handleMessage(header *levin.Header, raw *levin.PortableStor
 
 	processblocks := func(header *levin.Header, raw *levin.PortableStorage) error {
 		_ = header
+		var blocks []*levin.Block
 		for _, entry := range raw.Entries {
 			if entry.Name == "blocks" {
 				for _, blk := range entry.Entries() {
-					block := levin.NewBlock()
-					for _, ibl := range blk.Entries() {
-						if ibl.Name == "block" {
-							block.SetBlockData([]byte(ibl.String()))
-						} else {
-							for _, itx := range ibl.Entries() {
-								block.InsertTx([]byte(itx.String()))
-							}
-						}
+					block := levin.NewBlockFromEntries(blk.Entries())
+					if err := block.FullfillBlockHeader(); err != nil {
+						p.n.NotifyWithLevel(fmt.Sprintf("Block parse error: %s", err), LevelError)
+						continue
 					}
-					// p.n.NotifyWithLevel(fmt.Sprintf("block len: %d", len(block.block)), LevelSuccess)
 					for _, tx := range block.TXs {
-						p.n.NotifyWithLevel(fmt.Sprintf(" - tx len: %d", len(tx.Raw)), LevelSuccess)
+						tx.ParseTx()
+						tx.ParseRctSig()
 					}
-					p.n.NotifyWithLevel("=====", LevelSuccess)
+					blocks = append(blocks, block)
+				}
+			}
+		}
+
+		p.scanMu.Lock()
+		defer p.scanMu.Unlock()
+
+		var indices levin.GlobalIndices
+		if len(blocks) > 0 {
+			indices = p.indexBlocks(blocks)
+		}
+
+		// вся пачка сканируется пулом воркеров, дальше разбор по блокам
+		allOwned, allSpent := p.watcher.ScanIndexedBlocks(blocks, indices)
+		for _, block := range blocks {
+			var (
+				owned []levin.OwnedOutput
+				spent []levin.SpentOutput
+			)
+			for _, out := range allOwned {
+				if out.BlockHeight == block.BlockHeight {
+					owned = append(owned, out)
+				}
+			}
+			for _, s := range allSpent {
+				if s.BlockHeight == block.BlockHeight {
+					spent = append(spent, s)
+				}
+			}
+
+			if len(owned) > 0 {
+				for _, out := range owned {
+					p.n.NotifyWithLevel(fmt.Sprintf("Incoming %s XMR to %s; tx %x:%d; height %d", out.Amount, out.Address, out.TxHash, out.OutputIndex, out.BlockHeight), LevelWarning)
+				}
+				if err := p.db.ProcessOwnedOutputs(p.chainName, owned); err != nil {
+					p.n.NotifyWithLevel(fmt.Sprintf("ProcessOwnedOutputs error: %s", err), LevelError)
+				}
+				p.reconcile(owned)
+			}
+			if len(spent) > 0 {
+				for _, s := range spent {
+					p.n.NotifyWithLevel(fmt.Sprintf("Outgoing %s XMR from %s; tx %x:%d spent in %x; balance %s", s.Output.Amount, s.Output.Address, s.Output.TxHash, s.Output.OutputIndex, s.TxHash, p.watcher.Balance(s.Output.Address)), LevelWarning)
+				}
+				if err := p.db.ProcessSpentOutputs(p.chainName, spent); err != nil {
+					p.n.NotifyWithLevel(fmt.Sprintf("ProcessSpentOutputs error: %s", err), LevelError)
+				}
+			}
+			p.advance(block)
+			p.settle(block)
+			p.fees.Observe(block)
+			if p.broadcast != nil {
+				p.broadcastUpdates(p.broadcast.ObserveBlock(block))
+			}
+			p.tip = max(p.tip, block.BlockHeight)
+			// p.n.NotifyWithLevel(fmt.Sprintf("block len: %d", len(block.block)), LevelSuccess)
+			for _, tx := range block.TXs {
+				p.n.NotifyWithLevel(fmt.Sprintf(" - tx len: %d", len(tx.Raw)), LevelSuccess)
+			}
+			p.n.NotifyWithLevel("=====", LevelSuccess)
+		}
+		return nil
+	}
+
+	// транзакции пула от пира: подтверждают распространение наших и выдают двойные траты
+	processtxs := func(header *levin.Header, raw *levin.PortableStorage) error {
+		_ = header
+		b := p.Broadcaster()
+		if b == nil || raw == nil {
+			return nil
+		}
+		var txs []*levin.Transaction
+		for _, entry := range raw.Entries {
+			if entry.Name == "txs" {
+				for _, blob := range entry.Entries() {
+					tx := &levin.Transaction{Raw: []byte(blob.String())}
+					tx.ParseTx()
+					tx.ParseRctSig()
+					tx.CalcHash()
+					txs = append(txs, tx)
 				}
 			}
 		}
+		p.broadcastUpdates(b.ObservePool(txs...))
 		return nil
 	}
 
@@ -347,6 +514,8 @@ func (p *ScannerXMR) handleMessage(header *levin.Header, raw *levin.PortableStor
 		return processqueue(header, raw)
 	case levin.NotifyResponseGetObjects:
 		return processblocks(header, raw)
+	case levin.NotifyNewTransaction:
+		return processtxs(header, raw)
 	default:
 		p.n.NotifyWithLevel(fmt.Sprintf("Unhandeled message::%d", header.Command), LevelGray)
 		p.showHeader(header)
@@ -355,8 +524,155 @@ func (p *ScannerXMR) handleMessage(header *levin.Header, raw *levin.PortableStor
 }
 
 /*--- Loop Methods ---*/
+// indexBlocks раздаёт глобальные индексы выходам пачки. Если счётчиков нет или
+// пачка их не продолжает (пропущенный блок, реорганизация), они берутся заново
+// на высоте перед пачкой; не вышло — пачка без индексов, попытка на следующей.
+// Вызывается под scanMu.
+func (p *ScannerXMR) indexBlocks(blocks []*levin.Block) levin.GlobalIndices {
+	if p.indexer != nil {
+		indices, err := p.indexer.IndexBlocks(blocks)
+		if err == nil {
+			p.indexed(blocks, indices)
+			return indices
+		}
+		p.n.NotifyWithLevel(fmt.Sprintf("Output index error: %s, resyncing counters", err), LevelError)
+		p.indexer = nil
+	}
+
+	first := blocks[0]
+	if first.BlockHeight == 0 {
+		return nil
+	}
+	c, err := p.outputCounters(first.BlockHeight-1, hex.EncodeToString(first.PreviousBlockHash[:]))
+	if err != nil {
+		p.n.NotifyWithLevel(fmt.Sprintf("Output counters at %d: %s", first.BlockHeight-1, err), LevelError)
+		return nil
+	}
+	indexer := levin.NewOutputIndexer(*c)
+	indices, err := indexer.IndexBlocks(blocks)
+	if err != nil {
+		p.n.NotifyWithLevel(fmt.Sprintf("Output index error after resync: %s", err), LevelError)
+		return nil
+	}
+	p.indexer = indexer
+	p.n.NotifyWithLevel(fmt.Sprintf("Output counters synced at height %d: %d RingCT outputs", c.Height, c.Counts[0]), LevelInfo)
+	p.indexed(blocks, indices)
+	return indices
+}
+
+func (p *ScannerXMR) indexed(blocks []*levin.Block, indices levin.GlobalIndices) {
+	if err := p.db.SaveOutputCounters(p.chainName, p.indexer.Counters()); err != nil {
+		p.n.NotifyWithLevel(fmt.Sprintf("SaveOutputCounters error: %s", err), LevelError)
+	}
+	if p.ring != nil {
+		if err := p.ring.AddBlocks(blocks, indices); err != nil {
+			p.n.NotifyWithLevel(fmt.Sprintf("Ring index error: %s", err), LevelError)
+		}
+	}
+}
+
+// outputCounters: счётчики после блока height с хэшем hash — из базы, иначе у демона
+func (p *ScannerXMR) outputCounters(height uint64, hash string) (*levin.OutputCounters, error) {
+	if c, err := p.db.GetOutputCounters(p.chainName, height); err == nil && c != nil && c.Hash == hash {
+		return c, nil
+	}
+	if p.daemon == nil {
+		return nil, fmt.Errorf("not in database and no daemon RPC to seed them")
+	}
+	c, err := p.daemon.OutputCounters(height)
+	if err != nil {
+		return nil, err
+	}
+	if c.Hash != hash {
+		return nil, fmt.Errorf("daemon has block %s at height %d, expected %s", c.Hash, height, hash)
+	}
+	return c, nil
+}
+
+// reconcile привязывает выходы к открытым счетам. Зачисление — в settle, когда
+// выходы разблокируются и наберут подтверждения.
+func (p *ScannerXMR) reconcile(owned []levin.OwnedOutput) {
+	updates, _ := p.reconciler.Match(owned)
+	for _, u := range updates {
+		p.n.NotifyWithLevel(fmt.Sprintf("Invoice %s: %s XMR seen at height %d, waiting for unlock; pending %s XMR", u.Invoice.ID, u.Output.Amount, u.Output.BlockHeight, u.Invoice.Pending), LevelInfo)
+	}
+	p.invoiceUpdates(updates)
+}
+
+// settle: зачисление разблокированных выходов после нового блока
+func (p *ScannerXMR) settle(block *levin.Block) {
+	updates := p.reconciler.Advance(block.BlockHeight, block.Timestamp)
+	for _, u := range updates {
+		switch u.Invoice.Status {
+		case levin.InvoicePartial:
+			p.n.NotifyWithLevel(fmt.Sprintf("Invoice %s underpaid: received %s of %s XMR, shortfall %s", u.Invoice.ID, u.Invoice.Received, u.Invoice.Amount, u.Shortfall), LevelWarning)
+		case levin.InvoiceOverpaid:
+			p.n.NotifyWithLevel(fmt.Sprintf("Invoice %s overpaid: received %s of %s XMR, excess %s", u.Invoice.ID, u.Invoice.Received, u.Invoice.Amount, u.Excess), LevelWarning)
+		default:
+			p.n.NotifyWithLevel(fmt.Sprintf("Invoice %s paid: %s XMR", u.Invoice.ID, u.Invoice.Received), LevelSuccess)
+		}
+	}
+	p.invoiceUpdates(updates)
+}
+
+func (p *ScannerXMR) invoiceUpdates(updates []levin.InvoiceUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+	if err := p.db.ProcessInvoiceUpdates(p.chainName, updates); err != nil {
+		p.n.NotifyWithLevel(fmt.Sprintf("ProcessInvoiceUpdates error: %s", err), LevelError)
+	}
+}
+
+// advance: подтверждения и разблокировка выходов после нового блока
+func (p *ScannerXMR) advance(block *levin.Block) {
+	transitions := p.watcher.Advance(block.BlockHeight, block.Timestamp)
+	if len(transitions) == 0 {
+		return
+	}
+	for _, t := range transitions {
+		if t.From == t.To && t.Confirmations > 1 {
+			continue // рост подтверждений — только в базу
+		}
+		level := LevelInfo
+		if t.To == levin.OutputUnlocked {
+			level = LevelSuccess
+		}
+		p.n.NotifyWithLevel(fmt.Sprintf("Output %x:%d of %s %s, confirmations %d, %s XMR", t.Output.TxHash, t.Output.OutputIndex, t.Output.Address, t.To, t.Confirmations, t.Output.Amount), level)
+	}
+	if err := p.db.ProcessOutputTransitions(p.chainName, transitions); err != nil {
+		p.n.NotifyWithLevel(fmt.Sprintf("ProcessOutputTransitions error: %s", err), LevelError)
+	}
+}
+
+// broadcastUpdates: смена состояния отправленных транзакций
+func (p *ScannerXMR) broadcastUpdates(updates []levin.BroadcastUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+	for _, u := range updates {
+		level := LevelInfo
+		switch u.To {
+		case levin.BroadcastConfirmed:
+			level = LevelSuccess
+		case levin.BroadcastFinalized:
+			level = LevelSuccess
+			if u.From == levin.BroadcastDoubleSpent {
+				level = LevelError
+			}
+		case levin.BroadcastDoubleSpent, levin.BroadcastFailed:
+			level = LevelError
+		}
+		p.n.NotifyWithLevel(fmt.Sprintf("Tx %x %s -> %s, attempts %d %s", u.Tx.Hash, u.From, u.To, u.Tx.Attempts, u.Tx.Err), level)
+	}
+	if err := p.db.ProcessBroadcastUpdates(p.chainName, updates); err != nil {
+		p.n.NotifyWithLevel(fmt.Sprintf("ProcessBroadcastUpdates error: %s", err), LevelError)
+	}
+}
+
 func (p *ScannerXMR) MainLoop() { //3
 	go p.GetBlockDataLoop()
+	go p.BroadcastLoop()
 	// go p.WriteBlockToDBLoop()
 	go p.KeepConnectionLoop()
 	go p.ReadStreamLoop()
@@ -376,6 +692,16 @@ func (p *ScannerXMR) KeepConnectionLoop() {
 	}
 }
 
+// BroadcastLoop: переотправка и опрос статуса отправленных транзакций
+func (p *ScannerXMR) BroadcastLoop() {
+	for !p.destroy {
+		if b := p.Broadcaster(); b != nil {
+			p.broadcastUpdates(b.Retry(time.Now()))
+		}
+		time.Sleep(time.Second * 30)
+	}
+}
+
 func (p *ScannerXMR) ReadStreamLoop() {
 	for !p.destroy {
 		if p.connected {
@@ -479,6 +805,10 @@ func (p *ScannerXMR) GetBlockDataLoop() {
 					Name:         "blocks",
 					Serializable: levin.BoostBlock(blocks),
 				},
+				{
+					Name:         "prune",
+					Serializable: levin.BoostBool(p.prune),
+				},
 			},
 		}).Bytes()
 